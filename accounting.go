@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetStrictAccounting makes the pipeline check at the end of every run that
+// no object went missing: for every stage, the objects it read must have been
+// passed on, dropped or failed, and the objects a stage passed on must have
+// been read by the next one, unless either is a raw stage. Failed objects
+// include the dead-lettered ones.
+//
+// Violations are reported to onViolation, or cause a panic if it is nil.
+// Aborted runs aren't checked, since they abandon the objects in flight, and
+// neither should runs overlapping with other runs of the pipeline be, as they
+// share the counters.
+func (p *Pipeline) SetStrictAccounting(onViolation func(error)) {
+	p.checkMutable()
+	p.accounting = &accountingConfig{onViolation: onViolation}
+}
+
+type accountingConfig struct {
+	onViolation func(error)
+}
+
+// startAccounting returns the function checking the accounting of a run, or
+// nil if the pipeline isn't strict.
+func (p *Pipeline) startAccounting() func() {
+	cfg := p.accounting
+	if cfg == nil {
+		return nil
+	}
+	before := p.Stats()
+	return func() {
+		stages := p.stageList()
+		err := checkAccounting(stages, statsOf(stages).Sub(before))
+		if err == nil {
+			return
+		}
+		if cfg.onViolation == nil {
+			panic(err)
+		}
+		cfg.onViolation(err)
+	}
+}
+
+func checkAccounting(stages []*stage, stats Stats) error {
+	var violations []string
+	for i, s := range stats.Stages {
+		if stages[i].raw != nil {
+			continue
+		}
+		if s.In != s.Out+s.Dropped+s.Errors {
+			violations = append(violations, fmt.Sprintf("%s read %d objects but passed on %d, dropped %d and failed %d",
+				s.Name, s.In, s.Out, s.Dropped, s.Errors))
+		}
+		if i > 0 && stages[i-1].raw == nil {
+			if prev := stats.Stages[i-1]; prev.Out != s.In {
+				violations = append(violations, fmt.Sprintf("%s passed on %d objects but %s read %d",
+					prev.Name, prev.Out, s.Name, s.In))
+			}
+		}
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("pipeline: accounting violated: %s", strings.Join(violations, "; "))
+}
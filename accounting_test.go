@@ -0,0 +1,32 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_SetStrictAccounting() {
+	p := pipeline.New()
+	// panic if any object went missing
+	p.SetStrictAccounting(nil)
+	p.AddStage(squareStage)
+	p.AddSystematicSampleStage(0.5)
+	p.AddStage(printStage)
+
+	in := make(chan interface{}, 4)
+	for i := 1; i <= 4; i++ {
+		in <- i
+	}
+	close(in)
+	<-p.Run(in)
+
+	for _, s := range p.Stats().Stages {
+		fmt.Println(s.Name, s.In, "=", s.Out, "+", s.Dropped, "+", s.Errors)
+	}
+
+	// Output: 4
+	// 16
+	// stage0 4 = 4 + 0 + 0
+	// stage1 4 = 2 + 2 + 0
+	// stage2 2 = 2 + 0 + 0
+}
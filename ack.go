@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"sync"
+)
+
+// Acknowledger lets the source of an object learn whether the pipeline
+// processed it, for at-least-once delivery: queues, Kafka or SQS sources only
+// mark a message consumed once it is acknowledged, and redeliver it when it is
+// negatively acknowledged.
+//
+// Sources set the Acker of the envelopes they send into the pipeline. The
+// pipeline then calls Ack when the envelope comes out of the last stage or is
+// dropped by a stage returning nil, and Nack when a stage fails on it (after
+// the dead-letter function, if any). Objects abandoned by an aborted run are
+// neither acknowledged nor negatively acknowledged, and are left for the
+// source to redeliver.
+//
+// EnvelopeFn stages that replace an envelope with a new one must carry its
+// Acker over.
+type Acknowledger interface {
+	Ack()
+	Nack(err error)
+}
+
+// NewAcknowledger returns an Acknowledger calling ack or nack, at most once
+// in total. Either may be nil.
+func NewAcknowledger(ack func(), nack func(err error)) Acknowledger {
+	return &funcAcknowledger{ack: ack, nack: nack}
+}
+
+type funcAcknowledger struct {
+	once sync.Once
+	ack  func()
+	nack func(err error)
+}
+
+func (a *funcAcknowledger) Ack() {
+	a.once.Do(func() {
+		if a.ack != nil {
+			a.ack()
+		}
+	})
+}
+
+func (a *funcAcknowledger) Nack(err error) {
+	a.once.Do(func() {
+		if a.nack != nil {
+			a.nack(err)
+		}
+	})
+}
+
+// Settle acknowledges the envelope of an object a raw stage drops, or rejects
+// it if err is not nil, as the pipeline does for the objects the other stages
+// drop or fail, and recycles it: the stage must not use the object afterwards.
+// Objects without envelopes are left as they are.
+func Settle(obj interface{}, err error) {
+	endTrace(obj)
+	settle(obj, err)
+}
+
+// settle acknowledges an object that left the pipeline, successfully if err
+// is nil, and recycles its envelope.
+func settle(obj interface{}, err error) {
+	env, ok := obj.(*Envelope)
+	if !ok {
+		return
+	}
+	if env.Acker != nil {
+		if err != nil {
+			env.Acker.Nack(err)
+		} else {
+			env.Acker.Ack()
+		}
+	}
+	releaseEnvelope(env)
+}
@@ -0,0 +1,34 @@
+package pipeline_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExampleAcknowledger() {
+	p := pipeline.New()
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		if inObj == "poison" {
+			return nil, errors.New("cannot parse")
+		}
+		return inObj, nil
+	}, 1)
+
+	ch := make(chan interface{}, 2)
+	for _, msg := range []string{"poison", "message"} {
+		msg := msg
+		ch <- &pipeline.Envelope{
+			Payload: msg,
+			Acker: pipeline.NewAcknowledger(
+				func() { fmt.Println("ack", msg) },
+				func(err error) { fmt.Println("nack", msg, err) },
+			),
+		}
+	}
+	close(ch)
+	<-p.Run(ch)
+
+	// Output: nack poison cannot parse
+	// ack message
+}
@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ActivityOptions configure RunActivity.
+type ActivityOptions struct {
+	// Heartbeat, if set, is called with the current Stats every
+	// HeartbeatInterval while the run is in progress.
+	Heartbeat         func(Stats)
+	HeartbeatInterval time.Duration
+
+	// MaxErrorRate fails the run when the share of objects that any stage
+	// failed to process exceeds it. Zero disables the check.
+	MaxErrorRate float64
+}
+
+// ActivityError is returned by RunActivity when a run fails. Retryable tells
+// whether running it again may succeed: runs that were aborted, e.g. on a
+// timeout, can be retried, while runs with too many failed objects, failing
+// fast on one or exceeding their limits would fail the same way again.
+type ActivityError struct {
+	Retryable bool
+	Report    *Report
+	Err       error
+}
+
+func (e *ActivityError) Error() string {
+	return fmt.Sprintf("pipeline: run failed: %v", e.Err)
+}
+
+// RunActivity runs the pipeline to completion the way orchestrators expect
+// their units of work to behave: it blocks until the run is done, reports
+// progress through heartbeats, and returns the run's Report along with an
+// *ActivityError if the run failed.
+//
+// For instance, a Temporal activity can be written as follows, the heartbeat
+// details letting the workflow know how far a run got:
+//
+//	func (a *Activities) Import(ctx context.Context, path string) (*pipeline.Report, error) {
+//		report, err := a.pipeline.RunActivity(ctx, readLines(path), pipeline.ActivityOptions{
+//			Heartbeat: func(s pipeline.Stats) {
+//				activity.RecordHeartbeat(ctx, s)
+//			},
+//			HeartbeatInterval: 10 * time.Second,
+//			MaxErrorRate:      0.01,
+//		})
+//		if err, ok := err.(*pipeline.ActivityError); ok && !err.Retryable {
+//			return report, temporal.NewNonRetryableApplicationError(err.Error(), "PipelineFailed", err)
+//		}
+//		return report, err
+//	}
+//
+// Tasks run as processes, e.g. with the BashOperator of Airflow, can write the
+// Report to a file for downstream tasks and exit with a non-zero status on
+// error.
+func (p *Pipeline) RunActivity(ctx context.Context, inChan <-chan interface{}, opts ActivityOptions) (report *Report, err error) {
+	doneChan := p.runContext(ctx, inChan, func(r *Report) {
+		report = r
+	}).done
+
+	if opts.Heartbeat != nil && opts.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(opts.HeartbeatInterval)
+		defer ticker.Stop()
+	heartbeats:
+		for {
+			select {
+			case <-ticker.C:
+				opts.Heartbeat(p.Stats())
+			case <-doneChan:
+				break heartbeats
+			}
+		}
+	}
+	<-doneChan
+
+	if ctx.Err() != nil {
+		return report, &ActivityError{Retryable: true, Report: report, Err: ctx.Err()}
+	}
+	if report.Status == "aborted" {
+		// the run exceeded its limits, see SetRunLimits, or failed on an
+		// object, see FailFast: neither is worth retrying as is
+		return report, &ActivityError{Report: report, Err: errors.New(report.Error)}
+	}
+	if opts.MaxErrorRate > 0 {
+		for _, s := range report.Stages {
+			if s.In > 0 && float64(s.Errors)/float64(s.In) > opts.MaxErrorRate {
+				err = fmt.Errorf("%s failed %d of %d objects", s.Name, s.Errors, s.In)
+				return report, &ActivityError{Report: report, Err: err}
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,33 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_RunActivity() {
+	p := pipeline.New()
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		if inObj.(int) < 0 {
+			return nil, errors.New("negative value")
+		}
+		return inObj, nil
+	}, 1, pipeline.WithName("validate"))
+
+	in := make(chan interface{}, 4)
+	for _, i := range []int{1, -2, 3, 4} {
+		in <- i
+	}
+	close(in)
+
+	report, err := p.RunActivity(context.Background(), in, pipeline.ActivityOptions{MaxErrorRate: 0.1})
+	fmt.Println(report.Stages[0].Out, err)
+	if err, ok := err.(*pipeline.ActivityError); ok {
+		fmt.Println("retryable:", err.Retryable)
+	}
+
+	// Output: 3 pipeline: run failed: validate failed 1 of 4 objects
+	// retryable: false
+}
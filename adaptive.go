@@ -0,0 +1,182 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// AdaptiveConcurrency configures the adaptive concurrency limit of a stage,
+// see WithAdaptiveConcurrency.
+type AdaptiveConcurrency struct {
+	// TargetLatency, if set, makes the limit additive-increase
+	// multiplicative-decrease (AIMD): it grows by one per limit calls
+	// completed within TargetLatency and shrinks by Backoff on every slower
+	// or failed call. Otherwise the limit follows the gradient of the
+	// latency: it shrinks as the recent latency rises above the long-term
+	// one and grows while they are close, without any target to tune.
+	TargetLatency time.Duration
+	Backoff       float64 // the multiplicative decrease, 0.9 if zero
+
+	// InitialLimit is the limit to start with, MinLimit if zero. MinLimit
+	// and MaxLimit bound the limit; zero means 1 and no bound other than the
+	// fan size respectively.
+	InitialLimit int
+	MinLimit     int
+	MaxLimit     int
+}
+
+// WithAdaptiveConcurrency is a StageOption limiting the number of concurrent
+// calls of the ProcessFn of a stage, across all the runs of the pipeline, to
+// a limit adjusted on the latency of the calls, so that a fragile dependency
+// behind the stage, such as a database or an API, isn't overloaded when it
+// slows down. Goroutines beyond the limit wait for a call to complete. Retries
+// count as separate calls. Raw stages are unaffected.
+func WithAdaptiveConcurrency(ac AdaptiveConcurrency) StageOption {
+	return func(s *stage) {
+		s.adaptive = newAdaptiveLimit(ac)
+	}
+}
+
+// ConcurrencyLimit returns the current concurrency limit of a stage added with
+// WithAdaptiveConcurrency.
+func (p *Pipeline) ConcurrencyLimit(stageName string) (int, error) {
+	s, err := p.stageByName(stageName)
+	if err != nil {
+		return 0, err
+	}
+	if s.adaptive == nil {
+		return 0, fmt.Errorf("pipeline: stage %s has no adaptive concurrency", stageName)
+	}
+	return s.adaptive.current(), nil
+}
+
+// Smoothing of the gradient limit, after Netflix's concurrency-limits.
+const (
+	shortLatencyWeight = 0.1  // of a new call in the recent latency
+	longLatencyWeight  = 0.01 // of a new call in the long-term latency
+	latencyTolerance   = 1.5  // recent to long-term latency ratio tolerated
+	limitSmoothing     = 0.2  // weight of a new limit
+)
+
+// adaptiveLimit is the concurrency limit of a stage.
+type adaptiveLimit struct {
+	AdaptiveConcurrency
+
+	mu       sync.Mutex
+	limit    float64
+	inFlight int
+	released chan struct{} // closed when a call completes
+
+	// gradient state, in nanoseconds
+	shortLatency float64
+	longLatency  float64
+}
+
+func newAdaptiveLimit(ac AdaptiveConcurrency) *adaptiveLimit {
+	if ac.Backoff <= 0 || ac.Backoff >= 1 {
+		ac.Backoff = 0.9
+	}
+	if ac.MinLimit < 1 {
+		ac.MinLimit = 1
+	}
+	if ac.InitialLimit < ac.MinLimit {
+		ac.InitialLimit = ac.MinLimit
+	}
+	return &adaptiveLimit{AdaptiveConcurrency: ac, limit: float64(ac.InitialLimit), released: make(chan struct{})}
+}
+
+func (a *adaptiveLimit) current() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.limit)
+}
+
+// wrap makes fn wait for the limit to allow another call, and adjusts the
+// limit on the latency of the call.
+func (a *adaptiveLimit) wrap(fn ProcessFnErr, done <-chan struct{}) ProcessFnErr {
+	return func(inObj interface{}) (interface{}, error) {
+		if !a.acquire(done) {
+			return nil, errAborted
+		}
+		start := time.Now()
+		outObj, err := fn(inObj)
+		a.release(time.Since(start), err)
+		return outObj, err
+	}
+}
+
+func (a *adaptiveLimit) acquire(done <-chan struct{}) bool {
+	for {
+		a.mu.Lock()
+		if a.inFlight < int(a.limit) {
+			a.inFlight++
+			a.mu.Unlock()
+			return true
+		}
+		released := a.released
+		a.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-done:
+			return false
+		}
+	}
+}
+
+func (a *adaptiveLimit) release(latency time.Duration, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// the limit only grows while it is what holds the stage back
+	saturated := 2*a.inFlight >= int(a.limit)
+	a.inFlight--
+	prev := a.limit
+	if a.TargetLatency > 0 {
+		a.aimd(latency, err)
+	} else {
+		a.gradient(latency)
+	}
+	if a.limit > prev && !saturated {
+		a.limit = prev
+	}
+	if a.limit < float64(a.MinLimit) {
+		a.limit = float64(a.MinLimit)
+	}
+	if a.MaxLimit > 0 && a.limit > float64(a.MaxLimit) {
+		a.limit = float64(a.MaxLimit)
+	}
+	close(a.released)
+	a.released = make(chan struct{})
+}
+
+func (a *adaptiveLimit) aimd(latency time.Duration, err error) {
+	if err != nil || latency > a.TargetLatency {
+		a.limit *= a.Backoff
+	} else {
+		a.limit += 1 / a.limit
+	}
+}
+
+func (a *adaptiveLimit) gradient(latency time.Duration) {
+	sample := float64(latency)
+	if a.longLatency == 0 {
+		a.shortLatency, a.longLatency = sample, sample
+		return
+	}
+	a.shortLatency += shortLatencyWeight * (sample - a.shortLatency)
+	a.longLatency += longLatencyWeight * (sample - a.longLatency)
+	if a.longLatency > 2*a.shortLatency {
+		// the latency dropped for good, let the long-term one catch up
+		a.longLatency *= 0.95
+	}
+
+	gradient := math.Max(0.5, math.Min(1, latencyTolerance*a.longLatency/a.shortLatency))
+	newLimit := a.limit * gradient
+	if gradient == 1 {
+		// leave room for the calls to queue in the dependency
+		newLimit += math.Sqrt(a.limit)
+	}
+	a.limit = a.limit*(1-limitSmoothing) + newLimit*limitSmoothing
+}
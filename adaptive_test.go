@@ -0,0 +1,31 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExampleWithAdaptiveConcurrency() {
+	p := pipeline.New()
+	// a database call that is always slower than the target latency
+	p.AddStageWithFanOut(func(inObj interface{}) interface{} {
+		time.Sleep(2 * time.Millisecond)
+		return inObj
+	}, 8, pipeline.WithName("db"), pipeline.WithAdaptiveConcurrency(pipeline.AdaptiveConcurrency{
+		TargetLatency: time.Millisecond,
+		InitialLimit:  8,
+	}))
+
+	in := make(chan interface{}, 30)
+	for i := 0; i < 30; i++ {
+		in <- i
+	}
+	close(in)
+	<-p.Run(in)
+
+	// the limit backed off to its minimum
+	fmt.Println(p.ConcurrencyLimit("db"))
+
+	// Output: 1 <nil>
+}
@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// IOScaling configures the autoscaling of an IO-bound stage, such as a stage
+// writing to a database or calling an API, whose fan size is best driven by
+// the load it puts on the dependency rather than by how many objects are
+// waiting: more goroutines only help until the dependency saturates.
+type IOScaling struct {
+	// Sizer returns the size in bytes of an object going into the stage.
+	Sizer func(obj interface{}) int
+
+	// TargetBytesPerSec is the throughput to hold. The stage is scaled up
+	// while it is below it and its goroutines are busy, and scaled down
+	// while it is above it. Zero scales on utilization only.
+	TargetBytesPerSec float64
+
+	// Utilization, if set, reports the utilization of the dependency
+	// between 0 and 1, e.g. the CPU of the database. The stage is scaled
+	// down while it is above TargetUtilization, whatever the throughput.
+	Utilization       func() float64
+	TargetUtilization float64
+
+	// MinFanSize and MaxFanSize bound the fan size; zero means 1 and no
+	// bound respectively.
+	MinFanSize uint64
+	MaxFanSize uint64
+}
+
+// WithIOScaling is a StageOption tagging a stage as IO-bound, to be scaled by
+// Pipeline.StartAutoscaling according to scaling. Raw stages are unaffected.
+func WithIOScaling(scaling IOScaling) StageOption {
+	return func(s *stage) {
+		s.scaler = &ioScaler{IOScaling: scaling}
+	}
+}
+
+// Thresholds of the autoscaling of IO-bound stages.
+const (
+	scalingTolerance = 0.1 // relative distance to the target ignored
+	busyThreshold    = 0.8 // share of time the goroutines must be processing to scale up
+)
+
+// ioScaler measures the throughput of an IO-bound stage.
+type ioScaler struct {
+	bytes uint64 // updated atomically
+
+	IOScaling
+	prevBytes uint64
+	prevNanos uint64
+}
+
+// count counts the bytes of the objects processed successfully by fn.
+func (sc *ioScaler) count(fn ProcessFnErr) ProcessFnErr {
+	return func(inObj interface{}) (interface{}, error) {
+		outObj, err := fn(inObj)
+		if err == nil {
+			atomic.AddUint64(&sc.bytes, uint64(sc.Sizer(inObj)))
+		}
+		return outObj, err
+	}
+}
+
+// StartAutoscaling adjusts the fan size of the stages added with
+// WithIOScaling every interval, by one goroutine at a time, until the
+// returned function is called. The fan size changes apply to all the runs of
+// the pipeline, see SetFanOut. At most one autoscaler may run per pipeline.
+func (p *Pipeline) StartAutoscaling(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		timer := clockOrSystem(p.clock).NewTimer(interval)
+		defer timer.Stop()
+
+		for _, s := range p.stageList() {
+			if s.scaler != nil {
+				s.scaler.prevBytes = atomic.LoadUint64(&s.scaler.bytes)
+				s.scaler.prevNanos = atomic.LoadUint64(&s.counters.nanos)
+			}
+		}
+		for {
+			select {
+			case <-timer.C():
+				for _, s := range p.stageList() {
+					if s.scaler != nil && s.raw == nil && s.weighted == nil {
+						s.scaler.scale(s, interval, p.events)
+					}
+				}
+				// armed once the fan sizes changed, so that a fake clock
+				// sees the timer only when the scaling is done
+				timer.Reset(interval)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		select {
+		case <-done:
+		default:
+			close(done)
+		}
+		<-stopped
+	}
+}
+
+func (sc *ioScaler) scale(s *stage, elapsed time.Duration, events *EventBus) {
+	bytes := atomic.LoadUint64(&sc.bytes)
+	nanos := atomic.LoadUint64(&s.counters.nanos)
+	rate := float64(bytes-sc.prevBytes) / elapsed.Seconds()
+	prevFanSize := s.control.getFanSize()
+	fanSize := prevFanSize
+	busy := float64(nanos-sc.prevNanos) / float64(elapsed) / float64(fanSize)
+	sc.prevBytes, sc.prevNanos = bytes, nanos
+
+	overloaded := sc.Utilization != nil && sc.Utilization() > sc.TargetUtilization
+	target := sc.TargetBytesPerSec
+	switch {
+	case overloaded, target > 0 && rate > target*(1+scalingTolerance):
+		fanSize--
+	case target > 0 && rate < target*(1-scalingTolerance) && busy >= busyThreshold,
+		target == 0 && sc.Utilization != nil && busy >= busyThreshold:
+		fanSize++
+	default:
+		return
+	}
+
+	min := sc.MinFanSize
+	if min < 1 {
+		min = 1
+	}
+	if fanSize < min || (sc.MaxFanSize > 0 && fanSize > sc.MaxFanSize) {
+		return
+	}
+	s.control.setFanSize(fanSize)
+	events.Publish(Event{Type: EventAutoscaled, Stage: s.name,
+		Message: fmt.Sprintf("fan size %d -> %d at %.0fB/s, %.0f%% busy", prevFanSize, fanSize, rate, 100*busy)})
+}
+
+func (sc *ioScaler) String() string {
+	return fmt.Sprintf("target=%gB/s target_utilization=%g min=%d max=%d",
+		sc.TargetBytesPerSec, sc.TargetUtilization, sc.MinFanSize, sc.MaxFanSize)
+}
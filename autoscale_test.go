@@ -0,0 +1,55 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/pipelinetest"
+	"time"
+)
+
+func ExamplePipeline_StartAutoscaling() {
+	clock := pipelinetest.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	quit := make(chan struct{})
+	p := pipeline.New()
+	p.SetClock(clock)
+	// each goroutine writes 100KB/s
+	p.AddStageWithFanOut(func(inObj interface{}) interface{} {
+		select {
+		case <-clock.NewTimer(10 * time.Millisecond).C():
+		case <-quit:
+		}
+		return inObj
+	}, 1, pipeline.WithName("upload"), pipeline.WithIOScaling(pipeline.IOScaling{
+		Sizer: func(obj interface{}) int {
+			return len(obj.([]byte))
+		},
+		TargetBytesPerSec: 400000,
+		MaxFanSize:        4,
+	}))
+	fanSize := func() int {
+		return int(p.Definition().Stages[0].FanSize)
+	}
+
+	in := make(chan interface{}, 100)
+	for i := 0; i < 100; i++ {
+		in <- make([]byte, 1000)
+	}
+	close(in)
+
+	stop := p.StartAutoscaling(51 * time.Millisecond)
+	done := p.Run(in)
+	for i := 0; i < 200; i++ {
+		// every goroutine is uploading and the autoscaler is waiting
+		for n := 0; n != fanSize(); {
+			n = fanSize()
+			clock.WaitTimers(n + 1)
+		}
+		clock.Advance(time.Millisecond)
+	}
+	stop()
+	fmt.Println(fanSize())
+	close(quit)
+	<-done
+
+	// Output: 4
+}
@@ -0,0 +1,140 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BatchSink is a Sink writing objects in batches, e.g. to a service with a
+// bulk API. It groups the objects written concurrently, so it should be
+// added with a fan size of at least the size of the batches. A batch is
+// written once full or once its first object waited for the flush interval,
+// and Write returns once the batch of its object was written, with the error
+// of the object.
+type BatchSink struct {
+	size     int
+	interval time.Duration
+	batcher  batcher
+
+	// Clock, if set, measures the flush interval instead of the system clock.
+	Clock Clock
+}
+
+// NewBatchSink creates a BatchSink writing batches of up to size objects with
+// write, waiting for up to interval for a batch to fill up. If write returns
+// BatchErrors, each object fails with its own error, and otherwise every
+// object of the batch fails with the error.
+func NewBatchSink(size int, interval time.Duration, write func(objs []interface{}) error) *BatchSink {
+	return &BatchSink{size: size, interval: interval, batcher: batcher{commit: write}}
+}
+
+// Write implements Sink.
+func (s *BatchSink) Write(obj interface{}) error {
+	return s.batcher.add(obj, s.size, s.interval, clockOrSystem(s.Clock))
+}
+
+// Flush implements Flushable, writing the batch filling up right away.
+func (s *BatchSink) Flush(ctx context.Context) error {
+	return s.batcher.flush(ctx)
+}
+
+// BatchErrors holds the errors of the objects of a batch, in order, nil for
+// the objects written successfully.
+type BatchErrors []error
+
+func (e BatchErrors) Error() string {
+	var failed int
+	var first error
+	for _, err := range e {
+		if err != nil {
+			if failed++; first == nil {
+				first = err
+			}
+		}
+	}
+	return fmt.Sprintf("pipeline: %d of %d objects failed: %v", failed, len(e), first)
+}
+
+// batcher groups the objects written concurrently to a sink into batches,
+// committed once full or after an interval. Writers wait for the commit of
+// their batch and get their error.
+type batcher struct {
+	commit func(objs []interface{}) error
+
+	mu    sync.Mutex
+	batch *pendingBatch // filling up
+}
+
+type pendingBatch struct {
+	objs  []interface{}
+	timer Timer
+	done  chan struct{}
+	err   error
+}
+
+// add adds obj to the batch filling up, committing it if obj fills it, and
+// returns once the batch is committed.
+func (b *batcher) add(obj interface{}, size int, interval time.Duration, clock Clock) error {
+	b.mu.Lock()
+	batch := b.batch
+	if batch == nil {
+		batch = &pendingBatch{done: make(chan struct{})}
+		b.batch = batch
+		batch.timer = clock.AfterFunc(interval, func() {
+			if b.take(batch) {
+				b.run(batch)
+			}
+		})
+	}
+	i := len(batch.objs)
+	batch.objs = append(batch.objs, obj)
+	full := len(batch.objs) >= size
+	b.mu.Unlock()
+
+	if full && b.take(batch) {
+		b.run(batch)
+	}
+	<-batch.done
+	if errs, ok := batch.err.(BatchErrors); ok && len(errs) == len(batch.objs) {
+		return errs[i]
+	}
+	return batch.err
+}
+
+// take removes batch from the batcher if it is still filling up, in which
+// case the caller must run it.
+func (b *batcher) take(batch *pendingBatch) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.batch != batch {
+		return false
+	}
+	b.batch = nil
+	batch.timer.Stop()
+	return true
+}
+
+// run commits a batch and releases its writers.
+func (b *batcher) run(batch *pendingBatch) {
+	batch.err = b.commit(batch.objs)
+	close(batch.done)
+}
+
+// flush commits the batch filling up right away, waiting until ctx is done.
+func (b *batcher) flush(ctx context.Context) error {
+	b.mu.Lock()
+	batch := b.batch
+	b.mu.Unlock()
+	if batch == nil || !b.take(batch) {
+		return nil
+	}
+	go b.run(batch)
+	select {
+	case <-batch.done:
+		return batch.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,38 @@
+package pipeline_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExampleNewBatchSink() {
+	// a bulk API rejecting the negative numbers of a batch
+	sink := pipeline.NewBatchSink(3, 10*time.Millisecond, func(objs []interface{}) error {
+		fmt.Println("writing", len(objs), "objects")
+		errs := make(pipeline.BatchErrors, len(objs))
+		for i, obj := range objs {
+			if obj.(int) < 0 {
+				errs[i] = errors.New("negative")
+			}
+		}
+		return errs
+	})
+
+	p := pipeline.New()
+	p.AddSink(sink, 3)
+	p.SetDeadLetter(func(err *pipeline.ItemError) {
+		fmt.Println("dead-lettered", err.Obj, err.Err)
+	})
+
+	ch := make(chan interface{}, 3)
+	ch <- 1
+	ch <- -2
+	ch <- 3
+	close(ch)
+	<-p.Run(ch)
+
+	// Output: writing 3 objects
+	// dead-lettered -2 negative
+}
@@ -0,0 +1,45 @@
+package pipeline_test
+
+import (
+	"github.com/hyfather/pipeline"
+	"testing"
+)
+
+// benchmarkRun sends b.N small objects through a pipeline of three stages.
+func benchmarkRun(b *testing.B, p *pipeline.Pipeline) {
+	identity := func(inObj interface{}) interface{} {
+		return inObj
+	}
+	for i := 0; i < 3; i++ {
+		p.AddStage(identity)
+	}
+
+	in := make(chan interface{}, 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	done := p.Run(in)
+	for i := 0; i < b.N; i++ {
+		in <- true
+	}
+	close(in)
+	<-done
+}
+
+func BenchmarkRun(b *testing.B) {
+	p := pipeline.New()
+	benchmarkRun(b, &p)
+}
+
+func BenchmarkRunEnvelopes(b *testing.B) {
+	p := pipeline.New()
+	p.EnableEnvelopes()
+	benchmarkRun(b, &p)
+}
+
+func BenchmarkRunMiddleware(b *testing.B) {
+	p := pipeline.New()
+	p.Use(func(next pipeline.ProcessFn) pipeline.ProcessFn {
+		return next
+	})
+	benchmarkRun(b, &p)
+}
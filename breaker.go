@@ -0,0 +1,90 @@
+package pipeline
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is the error of the objects rejected by an open circuit
+// breaker that has no fallback.
+var ErrCircuitOpen = errors.New("pipeline: circuit breaker is open")
+
+// WithCircuitBreaker is a StageOption protecting a stage that calls a flaky
+// dependency. After threshold consecutive failures the breaker trips open and
+// the stage fails fast with ErrCircuitOpen, or sheds the objects to fallback
+// if it isn't nil. Once cooldown has elapsed a single object is let through as
+// a probe: its success closes the breaker, its failure opens it again.
+//
+// A failure is an object that exhausted its retries when WithRetry is also
+// used. The breaker is shared by all the runs of the pipeline.
+func WithCircuitBreaker(threshold int, cooldown time.Duration, fallback ProcessFn) StageOption {
+	return func(s *stage) {
+		s.breaker = &circuitBreaker{threshold: threshold, cooldown: cooldown, fallback: fallback}
+	}
+}
+
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	fallback  ProcessFn
+
+	mu       sync.Mutex
+	failures int
+	openedAt time.Time
+	open     bool
+	probing  bool
+}
+
+// wrap protects fn, measuring the cooldown on clock.
+func (b *circuitBreaker) wrap(fn ProcessFnErr, clock Clock) ProcessFnErr {
+	return func(inObj interface{}) (interface{}, error) {
+		probe, allowed := b.allow(clock.Now())
+		if !allowed {
+			if b.fallback != nil {
+				return b.fallback(inObj), nil
+			}
+			return nil, ErrCircuitOpen
+		}
+
+		outObj, err := fn(inObj)
+		b.record(probe, err == nil, clock.Now())
+		return outObj, err
+	}
+}
+
+// allow tells whether a call may go through and whether it is the probe of a
+// half-open breaker at the time now.
+func (b *circuitBreaker) allow(now time.Time) (probe, allowed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.open {
+		return false, true
+	}
+	if b.probing || now.Sub(b.openedAt) < b.cooldown {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+func (b *circuitBreaker) record(probe, ok bool, now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+	if ok {
+		if probe || !b.open {
+			b.open, b.failures = false, 0
+		}
+		return
+	}
+
+	b.failures++
+	if probe || (!b.open && b.failures >= b.threshold) {
+		b.open, b.openedAt = true, now
+	}
+}
@@ -0,0 +1,36 @@
+package pipeline_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExampleWithCircuitBreaker() {
+	enrich := func(inObj interface{}) (interface{}, error) {
+		return nil, errors.New("timeout")
+	}
+	cached := func(inObj interface{}) interface{} {
+		return fmt.Sprint(inObj, " (cached)")
+	}
+
+	p := pipeline.New()
+	p.AddStageErr(enrich, 1, pipeline.WithCircuitBreaker(2, time.Minute, cached))
+	p.AddStage(printStage)
+	p.SetDeadLetter(func(err *pipeline.ItemError) {
+		fmt.Println(err.Obj, err)
+	})
+
+	ch := make(chan interface{}, 4)
+	for i := 1; i <= 4; i++ {
+		ch <- i
+	}
+	close(ch)
+	<-p.Run(ch)
+
+	// Output: 1 pipeline: stage0 failed: timeout
+	// 2 pipeline: stage0 failed: timeout
+	// 3 (cached)
+	// 4 (cached)
+}
@@ -0,0 +1,231 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var funcs = struct {
+	sync.RWMutex
+	byName map[string]ProcessFnErr
+}{byName: map[string]ProcessFnErr{}}
+
+// RegisterFunc makes a ProcessFn available by name to the pipelines built
+// with Build, replacing any function previously registered with that name.
+func RegisterFunc(name string, fn ProcessFn) {
+	RegisterFuncErr(name, func(inObj interface{}) (interface{}, error) {
+		return fn(inObj), nil
+	})
+}
+
+// RegisterFuncErr is RegisterFunc for a function that can fail.
+func RegisterFuncErr(name string, fn ProcessFnErr) {
+	funcs.Lock()
+	defer funcs.Unlock()
+	funcs.byName[name] = fn
+}
+
+// LookupFunc returns the function registered with the given name.
+func LookupFunc(name string) (fn ProcessFnErr, ok bool) {
+	funcs.RLock()
+	defer funcs.RUnlock()
+	fn, ok = funcs.byName[name]
+	return
+}
+
+// Funcs returns the names of the registered functions, sorted.
+func Funcs() (names []string) {
+	funcs.RLock()
+	defer funcs.RUnlock()
+	for name := range funcs.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// Build builds a pipeline from a definition whose stages name their function
+// with Func, as registered with RegisterFunc, so that the topology of a
+// pipeline can change without recompiling it: the order of the stages, their
+// fan sizes and their options.
+//
+// The definition must be valid, see Definition.Validate. Only the process
+// stages can be built, with the "retry", "circuit_breaker" (without fallback),
+// "limiter" and "distribution" options in the format of Definition; the
+// limiters must be registered. The pipeline options can be "envelopes",
+// "synchronous", "max_in_flight", "priorities", the size of the priority
+// buffers, and "error_policy". Other options are errors.
+func Build(def Definition) (p Pipeline, err error) {
+	p = New()
+	if err = def.Validate(); err != nil {
+		return
+	}
+	for _, name := range sortedKeys(def.Options) {
+		value := def.Options[name]
+		switch name {
+		case "envelopes":
+			if value == "true" {
+				p.EnableEnvelopes()
+			}
+		case "synchronous":
+			p.SetSynchronous(value == "true")
+		case "max_in_flight":
+			var n int
+			if n, err = strconv.Atoi(value); err != nil {
+				return p, fmt.Errorf("pipeline: invalid max_in_flight %q", value)
+			}
+			p.SetMaxInFlight(n)
+		case "priorities":
+			var n int
+			if n, err = strconv.Atoi(value); err != nil {
+				return p, fmt.Errorf("pipeline: invalid priorities %q", value)
+			}
+			p.EnablePriorities(n)
+		case "error_policy":
+			var policy ErrorPolicy
+			if policy, err = ParseErrorPolicy(value); err != nil {
+				return p, fmt.Errorf("pipeline: invalid error_policy %q", value)
+			}
+			p.SetErrorPolicy(policy)
+		default:
+			return p, fmt.Errorf("pipeline: option %q can't be built", name)
+		}
+	}
+
+	for _, sd := range def.Stages {
+		if sd.Kind != "process" {
+			return p, fmt.Errorf("pipeline: stage %s: %s stages can't be built", sd.Name, sd.Kind)
+		}
+		fn, ok := LookupFunc(sd.Func)
+		if !ok {
+			return p, fmt.Errorf("pipeline: stage %s: no function named %q", sd.Name, sd.Func)
+		}
+
+		opts := []StageOption{WithName(sd.Name), withFunc(sd.Func)}
+		for _, option := range sortedKeys(sd.Options) {
+			opt, err := buildStageOption(option, sd.Options[option])
+			if err != nil {
+				return p, fmt.Errorf("pipeline: stage %s: %v", sd.Name, err)
+			}
+			opts = append(opts, opt)
+		}
+		p.AddStageErr(fn, sd.FanSize, opts...)
+	}
+	return p, nil
+}
+
+// BuildJSON builds a pipeline from a JSON definition, see Build. YAML
+// definitions can be converted to JSON first, e.g. with sigs.k8s.io/yaml.
+func BuildJSON(data []byte) (Pipeline, error) {
+	var def Definition
+	if err := json.Unmarshal(data, &def); err != nil {
+		return New(), err
+	}
+	return Build(def)
+}
+
+func withFunc(name string) StageOption {
+	return func(s *stage) {
+		s.funcName = name
+	}
+}
+
+func buildStageOption(name, value string) (StageOption, error) {
+	params, err := parseParams(value)
+	switch name {
+	case "retry":
+		var attempts int
+		var b Backoff
+		if err == nil {
+			attempts, err = intParam(params, "attempts", 1)
+		}
+		if err == nil {
+			b.Initial, err = durationParam(params, "initial")
+		}
+		if err == nil {
+			b.Max, err = durationParam(params, "max")
+		}
+		if err == nil {
+			b.Multiplier, err = floatParam(params, "multiplier")
+		}
+		if err == nil {
+			b.Jitter, err = floatParam(params, "jitter")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid retry %q: %v", value, err)
+		}
+		return WithRetry(attempts, b), nil
+	case "circuit_breaker":
+		var threshold int
+		var cooldown time.Duration
+		if err == nil {
+			threshold, err = intParam(params, "threshold", 1)
+		}
+		if err == nil {
+			cooldown, err = durationParam(params, "cooldown")
+		}
+		if err == nil && params["fallback"] == "true" {
+			err = fmt.Errorf("fallbacks can't be built")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid circuit_breaker %q: %v", value, err)
+		}
+		return WithCircuitBreaker(threshold, cooldown, nil), nil
+	case "limiter":
+		// "name" or "name (limit)" as written by Definition
+		fields := strings.Fields(value)
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("empty limiter")
+		}
+		if _, ok := LookupLimiter(fields[0]); !ok {
+			return nil, fmt.Errorf("no limiter named %q", fields[0])
+		}
+		return WithLimiter(fields[0]), nil
+	case "distribution":
+		d, err := ParseDistribution(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid distribution %q", value)
+		}
+		return WithDistribution(d), nil
+	}
+	return nil, fmt.Errorf("option %q can't be built", name)
+}
+
+// parseParams parses space separated key=value parameters.
+func parseParams(s string) (map[string]string, error) {
+	params := map[string]string{}
+	for _, field := range strings.Fields(s) {
+		i := strings.IndexByte(field, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("%q isn't key=value", field)
+		}
+		params[field[:i]] = field[i+1:]
+	}
+	return params, nil
+}
+
+func intParam(params map[string]string, key string, def int) (int, error) {
+	if v, ok := params[key]; ok {
+		return strconv.Atoi(v)
+	}
+	return def, nil
+}
+
+func durationParam(params map[string]string, key string) (time.Duration, error) {
+	if v, ok := params[key]; ok {
+		return time.ParseDuration(v)
+	}
+	return 0, nil
+}
+
+func floatParam(params map[string]string, key string) (float64, error) {
+	if v, ok := params[key]; ok {
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, nil
+}
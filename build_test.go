@@ -0,0 +1,43 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"strings"
+)
+
+func ExampleBuildJSON() {
+	pipeline.RegisterFunc("upper", func(inObj interface{}) interface{} {
+		return strings.ToUpper(inObj.(string))
+	})
+	pipeline.RegisterFunc("print", printStage)
+
+	p, err := pipeline.BuildJSON([]byte(`{
+		"Stages": [
+			{"Name": "normalize", "Func": "upper", "Kind": "process", "FanSize": 4,
+			 "Options": {"retry": "attempts=3 initial=10ms"}},
+			{"Name": "output", "Func": "print", "Kind": "process", "FanSize": 1}
+		],
+		"Options": {"max_in_flight": "100"}
+	}`))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Print(p.Definition())
+
+	ch := make(chan interface{}, 1)
+	ch <- "hello"
+	close(ch)
+	<-p.Run(ch)
+
+	_, err = pipeline.BuildJSON([]byte(`{"Stages": [{"Name": "x", "Func": "lower", "Kind": "process", "FanSize": 1}]}`))
+	fmt.Println(err)
+
+	// Output: 1. normalize (process, fan size 4)
+	//      retry: attempts=3 initial=10ms max=0s multiplier=0 jitter=0
+	// 2. output (process, fan size 1)
+	// max_in_flight: 100
+	// HELLO
+	// pipeline: stage x: no function named "lower"
+}
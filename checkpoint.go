@@ -0,0 +1,287 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hyfather/pipeline/segment"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CheckpointStore persists the positions reached by the sources of a pipeline,
+// per partition (e.g. a Kafka partition or an input file).
+type CheckpointStore interface {
+	Save(name string, positions map[string]int64) error
+	Load(name string) (positions map[string]int64, err error)
+}
+
+// Checkpointer tracks the positions of the objects sent into a pipeline so
+// that a crashed process can resume where it left off rather than reprocess
+// everything. Since fan-out stages complete objects out of order, the position
+// of a partition only advances up to the first object that is still in flight.
+//
+// Sources call Track for every object they read, in increasing position order
+// within a partition, and set the returned Acknowledger on its Envelope. An
+// object counts as processed once it is acknowledged, or negatively
+// acknowledged since the pipeline only does so after handing it to the
+// dead-letter function.
+type Checkpointer struct {
+	name  string
+	store CheckpointStore
+
+	events *EventBus
+
+	mu         sync.Mutex
+	partitions map[string]*partitionTracker
+	saved      map[string]int64
+}
+
+type partitionTracker struct {
+	pending  []*trackedPosition // in tracking order
+	position int64
+	advanced bool
+}
+
+type trackedPosition struct {
+	offset int64
+	done   bool
+}
+
+// NewCheckpointer creates a Checkpointer saving its positions under name in
+// store, and loads the positions saved previously.
+func NewCheckpointer(name string, store CheckpointStore) (*Checkpointer, error) {
+	saved, err := store.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	c := &Checkpointer{name: name, store: store, partitions: map[string]*partitionTracker{}, saved: saved}
+	for partition, position := range saved {
+		c.partitions[partition] = &partitionTracker{position: position, advanced: true}
+	}
+	return c, nil
+}
+
+// Position returns the position of the last object of a partition that was
+// processed along with all the objects before it. Sources resume reading
+// right after it.
+func (c *Checkpointer) Position(partition string) (offset int64, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.partitions[partition]
+	if !ok || !t.advanced {
+		return 0, false
+	}
+	return t.position, true
+}
+
+// Track registers an object read at the given position of a partition and
+// returns the Acknowledger to set on its envelope. The object's own
+// acknowledgement, if any, is passed as next and is notified as well.
+func (c *Checkpointer) Track(partition string, offset int64, next Acknowledger) Acknowledger {
+	c.mu.Lock()
+	t, ok := c.partitions[partition]
+	if !ok {
+		t = &partitionTracker{}
+		c.partitions[partition] = t
+	}
+	tp := &trackedPosition{offset: offset}
+	t.pending = append(t.pending, tp)
+	c.mu.Unlock()
+
+	complete := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		tp.done = true
+		for len(t.pending) > 0 && t.pending[0].done {
+			t.position, t.advanced = t.pending[0].offset, true
+			t.pending[0] = nil
+			t.pending = t.pending[1:]
+		}
+	}
+	return NewAcknowledger(func() {
+		complete()
+		if next != nil {
+			next.Ack()
+		}
+	}, func(err error) {
+		complete()
+		if next != nil {
+			next.Nack(err)
+		}
+	})
+}
+
+// Flush saves the current positions if they changed since the last save.
+func (c *Checkpointer) Flush() error {
+	c.mu.Lock()
+	positions := map[string]int64{}
+	changed := false
+	for partition, t := range c.partitions {
+		if !t.advanced {
+			continue
+		}
+		positions[partition] = t.position
+		if saved, ok := c.saved[partition]; !ok || saved != t.position {
+			changed = true
+		}
+	}
+	c.mu.Unlock()
+	if !changed {
+		return nil
+	}
+
+	if err := c.store.Save(c.name, positions); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.saved = positions
+	c.mu.Unlock()
+	c.events.Publish(Event{Type: EventCheckpoint, Object: positions, Message: c.name})
+	return nil
+}
+
+// PublishTo makes the Checkpointer publish an EventCheckpoint on bus every
+// time it saves its positions, e.g. on the EventBus of the pipeline whose
+// positions it tracks. Job does so.
+func (c *Checkpointer) PublishTo(bus *EventBus) {
+	c.events = bus
+}
+
+// Start flushes the positions every interval in the background, until the
+// returned function is called, which flushes them one last time. Errors are
+// passed to onErr if not nil.
+func (c *Checkpointer) Start(interval time.Duration, onErr func(error)) (stop func() error) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.Flush(); err != nil && onErr != nil {
+					onErr(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() (err error) {
+		once.Do(func() {
+			close(done)
+			<-stopped
+			err = c.Flush()
+		})
+		return
+	}
+}
+
+// FileCheckpointStore is a CheckpointStore keeping one segment file per name
+// in a directory, replaced atomically on every save.
+type FileCheckpointStore struct {
+	dir string
+
+	// Keys, if set, encrypts the checkpoints at rest.
+	Keys segment.KeyProvider
+}
+
+// NewFileCheckpointStore creates a FileCheckpointStore in dir, creating the
+// directory if needed.
+func NewFileCheckpointStore(dir string) (*FileCheckpointStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileCheckpointStore{dir: dir}, nil
+}
+
+// Save writes the positions with the default codec.
+func (s *FileCheckpointStore) Save(name string, positions map[string]int64) error {
+	codec := DefaultCodec()
+	data, err := codec.Marshal(positions)
+	if err != nil {
+		return err
+	}
+	if s.Keys != nil {
+		if data, err = segment.Seal(s.Keys, data); err != nil {
+			return err
+		}
+	}
+
+	var buf bytes.Buffer
+	w, err := segment.NewWriter(&buf, segment.Header{Kind: "checkpoint", Codec: codec.Name(), Encrypted: s.Keys != nil})
+	if err != nil {
+		return err
+	}
+	if err = w.Append(data); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(s.dir, name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(buf.Bytes()); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(name))
+}
+
+// Load reads the positions saved under name. It returns no positions and no
+// error if nothing was saved yet.
+func (s *FileCheckpointStore) Load(name string) (map[string]int64, error) {
+	f, err := os.Open(s.path(name))
+	if os.IsNotExist(err) {
+		return map[string]int64{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := segment.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	data, err := r.Next()
+	if err == io.EOF {
+		return map[string]int64{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if r.Header().Encrypted {
+		if s.Keys == nil {
+			return nil, fmt.Errorf("pipeline: checkpoint %q is encrypted and no keys are set", name)
+		}
+		if data, err = segment.Open(s.Keys, data); err != nil {
+			return nil, err
+		}
+	}
+
+	codec, ok := LookupCodec(r.Header().Codec)
+	if !ok {
+		return nil, fmt.Errorf("pipeline: unknown codec %q", r.Header().Codec)
+	}
+	positions := map[string]int64{}
+	err = codec.Unmarshal(data, &positions)
+	return positions, err
+}
+
+func (s *FileCheckpointStore) path(name string) string {
+	return filepath.Join(s.dir, name+".ckpt")
+}
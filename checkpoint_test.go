@@ -0,0 +1,35 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"io/ioutil"
+	"os"
+)
+
+func ExampleCheckpointer() {
+	dir, _ := ioutil.TempDir("", "checkpoints")
+	defer os.RemoveAll(dir)
+	store, _ := pipeline.NewFileCheckpointStore(dir)
+
+	checkpoints, _ := pipeline.NewCheckpointer("import", store)
+	acks := []pipeline.Acknowledger{}
+	for offset := int64(100); offset < 104; offset++ {
+		acks = append(acks, checkpoints.Track("partition-0", offset, nil))
+	}
+
+	// objects complete out of order: 102 can't be committed before 101
+	acks[0].Ack()
+	acks[2].Ack()
+	checkpoints.Flush()
+
+	// after a restart, reading resumes right after the committed position
+	restarted, _ := pipeline.NewCheckpointer("import", store)
+	fmt.Println(restarted.Position("partition-0"))
+
+	acks[1].Ack()
+	fmt.Println(checkpoints.Position("partition-0"))
+
+	// Output: 100 true
+	// 102 true
+}
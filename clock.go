@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"time"
+)
+
+// Clock tells the time and makes the timers of the features measuring time,
+// such as the window and delay stages, the batching sinks, the retries of the
+// stages, Throttle, Debounce or the MaxDuration of RunLimits. Tests can
+// replace the system clock with a fake one, such as pipelinetest.FakeClock,
+// to run time-based pipelines instantly and deterministically.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	// AfterFunc calls f in its own goroutine once d elapsed, unless the
+	// returned Timer is stopped first.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a time.Timer made by a Clock.
+type Timer interface {
+	C() <-chan time.Time // nil for the timers made by AfterFunc
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// SystemClock returns the Clock of the system, used unless another one is set.
+func SystemClock() Clock {
+	return systemClock{}
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) Timer {
+	return systemTimer{time.NewTimer(d)}
+}
+
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return systemTimer{time.AfterFunc(d, f)}
+}
+
+type systemTimer struct {
+	t *time.Timer
+}
+
+func (t systemTimer) C() <-chan time.Time {
+	return t.t.C
+}
+
+func (t systemTimer) Stop() bool {
+	return t.t.Stop()
+}
+
+func (t systemTimer) Reset(d time.Duration) bool {
+	return t.t.Reset(d)
+}
+
+// SetClock sets the Clock of the pipeline: of the ingestion time of its
+// envelopes, of its window and delay stages, of the retries, circuit breakers
+// and Retry-After holds of its stages, of the processing times in Stats, of the
+// MaxDuration of its RunLimits, of its events, and of Replay, StartWatchdog,
+// StartAutoscaling and the DrainTimeout of the Jobs running it. It applies to the runs and background
+// tasks started afterwards. The sources, sinks, Throttle and Debounce take
+// their clock separately.
+func (p *Pipeline) SetClock(clock Clock) {
+	p.checkMutable()
+	p.clock = clock
+	if p.events != nil {
+		p.events.setClock(clock)
+	}
+}
+
+// clockOrSystem returns clock, or the system one if it is nil.
+func clockOrSystem(clock Clock) Clock {
+	if clock == nil {
+		return SystemClock()
+	}
+	return clock
+}
+
+// sleep waits for d on clock, unless done is closed first, in which case it
+// returns false.
+func sleep(clock Clock, d time.Duration, done <-chan struct{}) bool {
+	if d <= 0 {
+		return true
+	}
+	t := clock.NewTimer(d)
+	select {
+	case <-t.C():
+		return true
+	case <-done:
+		t.Stop()
+		return false
+	}
+}
+
+// resetTimer safely resets a timer that may or may not have fired already.
+func resetTimer(t Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C():
+		default:
+		}
+	}
+	t.Reset(d)
+}
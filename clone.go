@@ -0,0 +1,67 @@
+package pipeline
+
+// Build returns a copy of the pipeline that can be run but not changed, so
+// that the stages and settings of its runs are the ones it was built with:
+//
+//	p := pipeline.New()
+//	p.AddStage(parse)
+//	p.AddStageWithFanOut(store, 8)
+//	built := p.Build()
+//	<-built.Run(in)
+//
+// Adding stages to the built pipeline or changing its settings, e.g. by
+// calling AddStage or Use after Run, panics rather than silently not
+// affecting the runs in progress. The runtime controls still apply, such as
+// SetFanOut, PauseStage or ReplaceStage. Like Clone, Build leaves p as it is,
+// and can be called again to build variants of p.
+func (p *Pipeline) Build() Pipeline {
+	b := p.Clone()
+	b.built = true
+	return b
+}
+
+// Clone returns a copy of the pipeline with the same stages and settings,
+// which can then be changed independently, e.g. to make variants of a
+// pipeline or a changeable copy of a built one. The copy has no runs, no
+// Recorder and an EventBus of its own, and its stages have counters, fan sizes
+// and autoscaling measurements of their own, starting from the current fan
+// sizes of p. The functions of the stages and the state of their options,
+// such as circuit breakers, limiters or the Stage of lifecycle stages, are
+// shared with p.
+func (p *Pipeline) Clone() Pipeline {
+	stagesMu.RLock()
+	runsMu.Lock()
+	c := *p
+	runsMu.Unlock()
+	stagesMu.RUnlock()
+	c.built = false
+	c.runs, c.lastRunID = nil, 0
+	c.events, c.recorder = nil, nil
+	c.middleware = append([]Middleware(nil), p.middleware...)
+	if p.sideOutputs != nil {
+		c.sideOutputs = make(map[string]*Pipeline, len(p.sideOutputs))
+		for name, sub := range p.sideOutputs {
+			c.sideOutputs[name] = sub
+		}
+	}
+
+	stages := c.stages
+	c.stages = make([]*stage, len(stages))
+	for i, s := range stages {
+		cs := *s
+		cs.counters = new(counters)
+		cs.control = newStageControl(s.control.getFanSize())
+		if s.scaler != nil {
+			cs.scaler = &ioScaler{IOScaling: s.scaler.IOScaling}
+		}
+		c.stages[i] = &cs
+	}
+	return c
+}
+
+// checkMutable panics if the pipeline was built, see Build.
+func (p *Pipeline) checkMutable() {
+	if p.built {
+		panic("pipeline: a built pipeline can't be changed, Clone it instead")
+	}
+}
@@ -0,0 +1,46 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_Build() {
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	built := p.Build()
+
+	// a variant printing the squares, p and built are left as they are
+	variant := built.Clone()
+	variant.AddStage(printStage)
+	<-variant.Run(pipeline.FromFunc(sequence(3)))
+
+	defer func() {
+		fmt.Println(recover())
+	}()
+	built.AddStage(printStage)
+
+	// Output: 1
+	// 4
+	// 9
+	// pipeline: a built pipeline can't be changed, Clone it instead
+}
+
+func ExamplePipeline_Clone() {
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	p.Events().Subscribe(func(e pipeline.Event) {
+		fmt.Println("run of p finished")
+	}, pipeline.EventRunFinished)
+
+	// the runs of the clone don't show in the stats and events of p
+	c := p.Clone()
+	<-c.Run(pipeline.FromFunc(sequence(3)))
+	fmt.Println(p.Stats().Stages[0].In, c.Stats().Stages[0].In)
+	<-p.Run(pipeline.FromFunc(sequence(2)))
+	fmt.Println(p.Stats().Stages[0].In, c.Stats().Stages[0].In)
+
+	// Output: 0 3
+	// run of p finished
+	// 2 3
+}
@@ -0,0 +1,109 @@
+// Command pipeline-top renders the live per-stage throughput, objects in
+// flight and latency of a running pipeline in the terminal, for operators
+// without a metrics stack. It polls the expvar endpoint of a process that
+// published its pipeline with Pipeline.PublishExpvar.
+//
+// Usage:
+//
+//	pipeline-top [-url http://localhost:8080/debug/vars] [-interval 1s] name
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const barWidth = 40
+
+func main() {
+	url := flag.String("url", "http://localhost:8080/debug/vars", "expvar endpoint of the process")
+	interval := flag.Duration("interval", time.Second, "refresh interval")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pipeline-top [flags] name")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	name := flag.Arg(0)
+
+	client := &http.Client{Timeout: *interval}
+	prev, err := fetch(client, *url, name)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	prevTime := time.Now()
+
+	for range time.Tick(*interval) {
+		cur, err := fetch(client, *url, name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			continue
+		}
+		now := time.Now()
+		render(name, cur, cur.Sub(prev), now.Sub(prevTime))
+		prev, prevTime = cur, now
+	}
+}
+
+func fetch(client *http.Client, url, name string) (stats pipeline.Stats, err error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var vars map[string]json.RawMessage
+	if err = json.NewDecoder(resp.Body).Decode(&vars); err != nil {
+		return
+	}
+	raw, ok := vars[name]
+	if !ok {
+		return stats, fmt.Errorf("no variable %q at %s", name, url)
+	}
+	err = json.Unmarshal(raw, &stats)
+	return
+}
+
+func render(name string, cur, delta pipeline.Stats, elapsed time.Duration) {
+	var maxRate float64
+	rates := make([]float64, len(delta.Stages))
+	for i, s := range delta.Stages {
+		rates[i] = float64(s.Out) / elapsed.Seconds()
+		if rates[i] > maxRate {
+			maxRate = rates[i]
+		}
+	}
+
+	// clear the screen and move the cursor home
+	fmt.Print("\033[H\033[2J")
+	fmt.Printf("pipeline-top - %s - %s\n\n", name, time.Now().Format("15:04:05"))
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tIN/s\tOUT/s\tIN FLIGHT\tERRORS\tLATENCY\tTHROUGHPUT")
+	for i, s := range delta.Stages {
+		bar := 0
+		if maxRate > 0 {
+			bar = int(rates[i] / maxRate * barWidth)
+		}
+		fmt.Fprintf(tw, "%s\t%.1f\t%.1f\t%d\t%d\t%s\t%s\n",
+			s.Name,
+			float64(s.In)/elapsed.Seconds(),
+			rates[i],
+			cur.Stages[i].InFlight(),
+			cur.Stages[i].Errors,
+			s.AvgLatency(),
+			strings.Repeat("█", bar))
+	}
+	tw.Flush()
+}
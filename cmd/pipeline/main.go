@@ -0,0 +1,83 @@
+// Command pipeline works with pipeline definitions serialized with
+// Pipeline.MarshalJSON.
+//
+// Usage:
+//
+//	pipeline validate -f definition.json
+//
+// validate checks that the definition describes a pipeline that can be built,
+// see Definition.Validate, and prints its topology without processing any
+// data. The functions the stages name must be registered in the command, see
+// pipeline.RegisterFunc, and the unknown ones are reported. The exit status is
+// 0 if the definition is valid, 1 if it isn't and 2 on usage errors.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "validate":
+		validate(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: pipeline validate -f definition.json")
+	os.Exit(2)
+}
+
+func validate(args []string) {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	file := flags.String("f", "", "pipeline definition to validate")
+	flags.Parse(args)
+	if *file == "" || flags.NArg() > 0 {
+		usage()
+	}
+
+	data, err := ioutil.ReadFile(*file)
+	if err != nil {
+		fail(err)
+	}
+	var def pipeline.Definition
+	if err := json.Unmarshal(data, &def); err != nil {
+		fail(fmt.Errorf("%s: %v", *file, err))
+	}
+	fmt.Print(def)
+	if err := def.Validate(); err != nil {
+		fail(err)
+	}
+	if unknown := unknownFuncs(def); len(unknown) > 0 {
+		fail(fmt.Errorf("unknown functions: %s", strings.Join(unknown, "; ")))
+	}
+}
+
+// unknownFuncs describes the stages naming functions that aren't registered.
+func unknownFuncs(def pipeline.Definition) (unknown []string) {
+	for _, s := range def.Stages {
+		if s.Func == "" {
+			continue
+		}
+		if _, ok := pipeline.LookupFunc(s.Func); !ok {
+			unknown = append(unknown, fmt.Sprintf("stage %q has unknown function %q", s.Name, s.Func))
+		}
+	}
+	return
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "pipeline:", err)
+	os.Exit(1)
+}
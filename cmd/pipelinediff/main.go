@@ -0,0 +1,50 @@
+// Command pipelinediff compares two pipeline definitions serialized with
+// Pipeline.MarshalJSON and reports the added, removed and changed stages and
+// options, for change reviews and deployment gates.
+//
+// Usage:
+//
+//	pipelinediff old.json new.json
+//
+// The exit status is 0 if the definitions are equivalent, 1 if they differ
+// and 2 on error.
+package main
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"io/ioutil"
+	"os"
+)
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintln(os.Stderr, "usage: pipelinediff old.json new.json")
+		os.Exit(2)
+	}
+
+	old, err := ioutil.ReadFile(os.Args[1])
+	if err != nil {
+		fail(err)
+	}
+	new, err := ioutil.ReadFile(os.Args[2])
+	if err != nil {
+		fail(err)
+	}
+
+	changes, err := pipeline.DiffJSON(old, new)
+	if err != nil {
+		fail(err)
+	}
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	if len(changes) > 0 {
+		os.Exit(1)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "pipelinediff:", err)
+	os.Exit(2)
+}
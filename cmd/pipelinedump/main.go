@@ -0,0 +1,82 @@
+// Command pipelinedump inspects the segment files written by the disk-backed
+// features of the pipeline package, validating their checksums and printing
+// their records.
+//
+// Usage:
+//
+//	pipelinedump [-q] file...
+//
+// With -q only the headers and a summary are printed. The exit status is 1 if
+// any of the files is corrupt or truncated.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/hyfather/pipeline/segment"
+	"io"
+	"os"
+	"strconv"
+	"unicode/utf8"
+)
+
+func main() {
+	quiet := flag.Bool("q", false, "only print headers and summaries")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: pipelinedump [-q] file...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	status := 0
+	for _, name := range flag.Args() {
+		if err := dump(name, *quiet); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			status = 1
+		}
+	}
+	os.Exit(status)
+}
+
+func dump(name string, quiet bool) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := segment.NewReader(f)
+	if err != nil {
+		return err
+	}
+	h := r.Header()
+	fmt.Printf("%s: version=%d kind=%s codec=%s encrypted=%t\n", name, h.Version, h.Kind, h.Codec, h.Encrypted)
+
+	var count int
+	for ; ; count++ {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("record %d: %v", count, err)
+		}
+		if !quiet {
+			fmt.Printf("#%d %d bytes %s\n", count, len(record), printable(record))
+		}
+	}
+	fmt.Printf("%s: %d records, ok\n", name, count)
+	return nil
+}
+
+// printable renders text records as is and binary ones quoted.
+func printable(record []byte) string {
+	if utf8.Valid(record) {
+		return string(record)
+	}
+	return strconv.Quote(string(record))
+}
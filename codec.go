@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Codec serializes objects for the features of this package that persist or
+// transport them. Codecs are registered by name with RegisterCodec, and the
+// default one is chosen once with SetDefaultCodec so that every such feature
+// honors it.
+//
+// JSON ("json") and gob ("gob") codecs are built in. Other formats are a few
+// lines away; protobuf for instance:
+//
+//	type protoCodec struct{}
+//
+//	func (protoCodec) Name() string { return "proto" }
+//	func (protoCodec) Marshal(v interface{}) ([]byte, error) { return proto.Marshal(v.(proto.Message)) }
+//	func (protoCodec) Unmarshal(b []byte, v interface{}) error { return proto.Unmarshal(b, v.(proto.Message)) }
+//
+//	pipeline.RegisterCodec(protoCodec{})
+type Codec interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+var codecs = struct {
+	sync.RWMutex
+	byName map[string]Codec
+	def    Codec
+}{
+	byName: map[string]Codec{
+		"json": jsonCodec{},
+		"gob":  gobCodec{},
+	},
+	def: jsonCodec{},
+}
+
+// RegisterCodec makes a codec available by its name, replacing any codec that
+// was previously registered with the same name.
+func RegisterCodec(c Codec) {
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.byName[c.Name()] = c
+}
+
+// LookupCodec returns the codec registered with the given name.
+func LookupCodec(name string) (c Codec, ok bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	c, ok = codecs.byName[name]
+	return
+}
+
+// SetDefaultCodec selects the registered codec used when none is explicitly
+// given. The default is "json".
+func SetDefaultCodec(name string) error {
+	c, ok := LookupCodec(name)
+	if !ok {
+		return fmt.Errorf("pipeline: unknown codec %q", name)
+	}
+	codecs.Lock()
+	defer codecs.Unlock()
+	codecs.def = c
+	return nil
+}
+
+// DefaultCodec returns the codec selected with SetDefaultCodec.
+func DefaultCodec() Codec {
+	codecs.RLock()
+	defer codecs.RUnlock()
+	return codecs.def
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string                               { return "json" }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// gobCodec requires the concrete types stored in interface values to be
+// registered with gob.Register.
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
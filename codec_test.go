@@ -0,0 +1,22 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExampleSetDefaultCodec() {
+	if err := pipeline.SetDefaultCodec("gob"); err != nil {
+		panic(err)
+	}
+	defer pipeline.SetDefaultCodec("json")
+
+	codec := pipeline.DefaultCodec()
+	data, _ := codec.Marshal([]int{1, 2, 3})
+
+	var ints []int
+	codec.Unmarshal(data, &ints)
+	fmt.Println(codec.Name(), ints)
+
+	// Output: gob [1 2 3]
+}
@@ -0,0 +1,129 @@
+package pipeline
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Compressor compresses and decompresses byte slices for AddCompressStage and
+// AddDecompressStage. Implementations must be safe for concurrent use, and
+// should reuse their encoders and decoders across calls since stages call
+// them for every object. Gzip is built in, see NewGzipCompressor. Zstandard
+// is a few lines away with github.com/klauspost/compress/zstd, whose
+// encoders and decoders are safe for concurrent use:
+//
+//	type zstdCompressor struct {
+//		enc *zstd.Encoder
+//		dec *zstd.Decoder
+//	}
+//
+//	func (c zstdCompressor) Compress(dst, src []byte) ([]byte, error)   { return c.enc.EncodeAll(src, dst), nil }
+//	func (c zstdCompressor) Decompress(dst, src []byte) ([]byte, error) { return c.dec.DecodeAll(src, dst) }
+type Compressor interface {
+	// Compress appends the compressed src to dst.
+	Compress(dst, src []byte) ([]byte, error)
+	// Decompress appends the decompressed src to dst.
+	Decompress(dst, src []byte) ([]byte, error)
+}
+
+// AddCompressStage adds a stage compressing []byte (or string) objects with
+// c into new []byte objects. See AddStageWithFanOut for the meaning of
+// fanSize and opts.
+func (p *Pipeline) AddCompressStage(c Compressor, fanSize uint64, opts ...StageOption) {
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		switch in := inObj.(type) {
+		case []byte:
+			return c.Compress(nil, in)
+		case string:
+			return c.Compress(nil, []byte(in))
+		}
+		return nil, fmt.Errorf("pipeline: can't compress %T", inObj)
+	}, fanSize, append([]StageOption{WithTypes(nil, bytesType)}, opts...)...)
+}
+
+// AddDecompressStage adds a stage decompressing []byte objects with c into
+// new []byte objects. Objects that aren't valid, or exceed the size c
+// decompresses up to, fail and are sent to the dead-letter function. See AddStageWithFanOut for the meaning of fanSize and
+// opts.
+func (p *Pipeline) AddDecompressStage(c Compressor, fanSize uint64, opts ...StageOption) {
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		in, ok := inObj.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("pipeline: can't decompress %T", inObj)
+		}
+		return c.Decompress(nil, in)
+	}, fanSize, append([]StageOption{WithTypes(bytesType, bytesType)}, opts...)...)
+}
+
+// defaultMaxDecompressed is the size of the decompressed data Decompress
+// fails beyond, unless set otherwise.
+const defaultMaxDecompressed = 64 << 20
+
+// gzipCompressor pools its writers and readers, so that the goroutines of a
+// stage each end up reusing one.
+type gzipCompressor struct {
+	level   int
+	max     int64
+	writers sync.Pool // of *gzip.Writer
+	readers sync.Pool // of *gzip.Reader
+}
+
+// NewGzipCompressor returns a Compressor producing gzip data compressed at the
+// given level, such as gzip.BestSpeed or gzip.DefaultCompression. Decompress
+// fails on data decompressing to more than maxSize bytes, so that a small
+// object can't exhaust the memory; zero means 64MB.
+func NewGzipCompressor(level int, maxSize int64) (Compressor, error) {
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		return nil, err
+	}
+	if maxSize <= 0 {
+		maxSize = defaultMaxDecompressed
+	}
+	return &gzipCompressor{level: level, max: maxSize}, nil
+}
+
+func (c *gzipCompressor) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w, ok := c.writers.Get().(*gzip.Writer)
+	if ok {
+		w.Reset(buf)
+	} else {
+		w, _ = gzip.NewWriterLevel(buf, c.level)
+	}
+	defer c.writers.Put(w)
+
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *gzipCompressor) Decompress(dst, src []byte) ([]byte, error) {
+	var err error
+	r, ok := c.readers.Get().(*gzip.Reader)
+	if ok {
+		err = r.Reset(bytes.NewReader(src))
+	} else {
+		r, err = gzip.NewReader(bytes.NewReader(src))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer c.readers.Put(r)
+
+	buf := bytes.NewBuffer(dst)
+	n, err := io.Copy(buf, io.LimitReader(r, c.max+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > c.max {
+		return nil, fmt.Errorf("pipeline: decompressed data exceeds %d bytes", c.max)
+	}
+	return buf.Bytes(), nil
+}
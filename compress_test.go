@@ -0,0 +1,45 @@
+package pipeline_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"strings"
+)
+
+func ExamplePipeline_AddCompressStage() {
+	gz, _ := pipeline.NewGzipCompressor(gzip.BestSpeed, 0)
+
+	p := pipeline.New()
+	p.AddCompressStage(gz, 4)
+	p.AddStage(func(inObj interface{}) interface{} {
+		fmt.Println("compressed below 100 bytes:", len(inObj.([]byte)) < 100)
+		return inObj
+	})
+	p.AddDecompressStage(gz, 4)
+	p.AddStage(func(inObj interface{}) interface{} {
+		fmt.Println("decompressed", len(inObj.([]byte)), "bytes")
+		return nil
+	})
+
+	ch := make(chan interface{}, 1)
+	ch <- strings.Repeat("all work and no play makes jack a dull boy ", 100)
+	close(ch)
+	<-p.Run(ch)
+
+	// Output: compressed below 100 bytes: true
+	// decompressed 4300 bytes
+}
+
+func ExampleNewGzipCompressor() {
+	gz, _ := pipeline.NewGzipCompressor(gzip.BestSpeed, 1000)
+
+	// a few bytes decompressing to 1MB
+	bomb, _ := gz.Compress(nil, make([]byte, 1<<20))
+	fmt.Println(len(bomb) < 2000)
+	_, err := gz.Decompress(nil, bomb)
+	fmt.Println(err)
+
+	// Output: true
+	// pipeline: decompressed data exceeds 1000 bytes
+}
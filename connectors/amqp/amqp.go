@@ -0,0 +1,195 @@
+// Package amqp connects pipelines to RabbitMQ and other AMQP 0.9.1 brokers:
+// a Source consuming a queue with manual acknowledgements driven by the
+// completion of the objects in the pipeline, and a Sink publishing to an
+// exchange in confirm mode.
+//
+// The package doesn't depend on an AMQP client. The Consumer and Publisher
+// interfaces are small enough to wrap any client, e.g. for
+// github.com/rabbitmq/amqp091-go, with a channel consuming with autoAck off:
+//
+//	func (c consumer) Next(ctx context.Context) (amqp.Delivery, error) {
+//		select {
+//		case d, ok := <-c.deliveries:
+//			if !ok {
+//				return amqp.Delivery{}, errors.New("channel closed")
+//			}
+//			return amqp.Delivery{Exchange: d.Exchange, RoutingKey: d.RoutingKey, Body: d.Body,
+//				ContentType: d.ContentType, Headers: d.Headers,
+//				Ack:  func() error { return d.Ack(false) },
+//				Nack: func(requeue bool) error { return d.Nack(false, requeue) }}, nil
+//		case <-ctx.Done():
+//			return amqp.Delivery{}, ctx.Err()
+//		}
+//	}
+package amqp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"sync"
+)
+
+// Delivery is a message delivered by the broker.
+type Delivery struct {
+	Exchange    string
+	RoutingKey  string
+	ContentType string
+	Headers     map[string]interface{}
+	Body        []byte
+
+	Ack  func() error
+	Nack func(requeue bool) error
+}
+
+// Consumer is the part of an AMQP channel consuming a queue used by Source.
+type Consumer interface {
+	// Next returns the next delivery of the queue.
+	Next(ctx context.Context) (Delivery, error)
+}
+
+// Source is a pipeline.Source consuming a queue. Every delivery is sent into
+// the pipeline in a pipeline.Envelope carrying the Delivery as payload and its
+// routing key as Key. It is acknowledged once it is processed, and negatively
+// acknowledged when the pipeline failed to process it, see
+// pipeline.Acknowledger, so that the broker dead-letters it or, with Requeue,
+// redelivers it.
+//
+// The number of deliveries in flight is bounded by the prefetch count (QoS)
+// of the channel.
+type Source struct {
+	consumer Consumer
+
+	// Requeue makes the deliveries that failed go back to the queue rather
+	// than to its dead-letter exchange.
+	Requeue bool
+
+	// OnAckError, if set, is called when acknowledging a delivery fails.
+	OnAckError func(d Delivery, err error)
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewSource creates a Source consuming with consumer.
+func NewSource(consumer Consumer) *Source {
+	return &Source{consumer: consumer}
+}
+
+// Open implements pipeline.Source. Deliveries are consumed until ctx is done
+// or consuming fails, see Err.
+func (s *Source) Open(ctx context.Context) (<-chan interface{}, error) {
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+		for {
+			d, err := s.consumer.Next(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					s.mu.Lock()
+					s.err = err
+					s.mu.Unlock()
+				}
+				return
+			}
+
+			env := &pipeline.Envelope{Payload: d, Key: d.RoutingKey}
+			env.Acker = pipeline.NewAcknowledger(func() {
+				if d.Ack != nil {
+					s.acked(d, d.Ack())
+				}
+			}, func(error) {
+				if d.Nack != nil {
+					s.acked(d, d.Nack(s.Requeue))
+				}
+			})
+			select {
+			case ch <- env:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (s *Source) acked(d Delivery, err error) {
+	if err != nil && s.OnAckError != nil {
+		s.OnAckError(d, err)
+	}
+}
+
+// Err returns the error that stopped consuming, nil if ctx did. It must be
+// called once the channel is closed.
+func (s *Source) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Publishing is a message to publish.
+type Publishing struct {
+	ContentType string
+	Headers     map[string]interface{}
+	Body        []byte
+}
+
+// ErrNacked is returned by Publishers when the broker negatively confirmed a
+// publishing.
+var ErrNacked = errors.New("amqp: publishing nacked by the broker")
+
+// Publisher is the part of an AMQP channel in confirm mode used by Sink.
+// Publish must wait for the confirmation of the broker and fail, e.g. with
+// ErrNacked, if it is negative, so that the objects that the Sink passes on
+// are safely stored.
+type Publisher interface {
+	Publish(ctx context.Context, exchange, routingKey string, msg Publishing) error
+}
+
+// Sink is a pipeline.Sink publishing objects to an exchange. Publishings
+// that aren't confirmed fail the objects, which can then be retried with
+// pipeline.WithRetry or dead-lettered.
+type Sink struct {
+	publisher Publisher
+	exchange  string
+
+	// RoutingKey returns the routing key of an object, the routing key of
+	// the sink if nil.
+	RoutingKey func(obj interface{}) string
+	routingKey string
+
+	// Encode turns an object into a publishing. By default []byte and
+	// string objects are published as is, other objects fail.
+	Encode func(obj interface{}) (Publishing, error)
+}
+
+// NewSink creates a Sink publishing to exchange with routingKey.
+func NewSink(publisher Publisher, exchange, routingKey string) *Sink {
+	return &Sink{publisher: publisher, exchange: exchange, routingKey: routingKey}
+}
+
+// Write implements pipeline.Sink.
+func (s *Sink) Write(obj interface{}) error {
+	var msg Publishing
+	switch o := obj.(type) {
+	case []byte:
+		msg.Body = o
+	case string:
+		msg.Body = []byte(o)
+	}
+	if s.Encode != nil {
+		var err error
+		if msg, err = s.Encode(obj); err != nil {
+			return err
+		}
+	} else if msg.Body == nil {
+		return fmt.Errorf("amqp: can't publish %T", obj)
+	}
+
+	routingKey := s.routingKey
+	if s.RoutingKey != nil {
+		routingKey = s.RoutingKey(obj)
+	}
+	return s.publisher.Publish(context.Background(), s.exchange, routingKey, msg)
+}
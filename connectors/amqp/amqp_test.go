@@ -0,0 +1,82 @@
+package amqp_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/connectors/amqp"
+	"strings"
+	"sync"
+)
+
+// fakeConsumer serves a fixed list of deliveries and records how they were
+// settled.
+type fakeConsumer struct {
+	bodies []string
+
+	mu      sync.Mutex
+	settled map[string]string
+}
+
+func (c *fakeConsumer) settle(body, outcome string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.settled[body] = outcome
+	return nil
+}
+
+func (c *fakeConsumer) Next(ctx context.Context) (amqp.Delivery, error) {
+	if len(c.bodies) == 0 {
+		<-ctx.Done()
+		return amqp.Delivery{}, ctx.Err()
+	}
+	body := c.bodies[0]
+	c.bodies = c.bodies[1:]
+	return amqp.Delivery{
+		RoutingKey: "invoices",
+		Body:       []byte(body),
+		Ack: func() error {
+			return c.settle(body, "ack")
+		},
+		Nack: func(requeue bool) error {
+			return c.settle(body, fmt.Sprintf("nack (requeue: %t)", requeue))
+		},
+	}, nil
+}
+
+// confirmingPublisher confirms every publishing but the empty ones.
+type confirmingPublisher struct{}
+
+func (confirmingPublisher) Publish(ctx context.Context, exchange, routingKey string, msg amqp.Publishing) error {
+	if len(msg.Body) == 0 {
+		return amqp.ErrNacked
+	}
+	fmt.Printf("publish %s/%s: %s\n", exchange, routingKey, msg.Body)
+	return nil
+}
+
+func Example() {
+	ctx, cancel := context.WithCancel(context.Background())
+	consumer := &fakeConsumer{bodies: []string{"paid", ""}, settled: map[string]string{}}
+	src := amqp.NewSource(consumer)
+
+	p := pipeline.New()
+	p.AddStage(func(inObj interface{}) interface{} {
+		d := inObj.(amqp.Delivery)
+		if len(d.Body) == 0 {
+			defer cancel()
+		}
+		return strings.ToUpper(string(d.Body))
+	})
+	p.AddSink(amqp.NewSink(confirmingPublisher{}, "billing", "invoices.normalized"), 1)
+
+	in, _ := src.Open(ctx)
+	<-p.Run(in)
+
+	fmt.Printf("%q: %s\n", "paid", consumer.settled["paid"])
+	fmt.Printf("%q: %s\n", "", consumer.settled[""])
+
+	// Output: publish billing/invoices.normalized: PAID
+	// "paid": ack
+	// "": nack (requeue: false)
+}
@@ -0,0 +1,265 @@
+// Package kafka connects pipelines to Kafka topics with at-least-once
+// delivery: the offsets of the messages are only committed once the pipeline
+// processed them, and the objects published by the Sink only complete once
+// the brokers acknowledged them.
+//
+// The package doesn't depend on a Kafka client library. The Consumer and
+// Producer interfaces are small enough to wrap any client, e.g. the Reader
+// and Writer of github.com/segmentio/kafka-go:
+//
+//	type consumer struct{ r *kafkago.Reader }
+//
+//	func (c consumer) Fetch(ctx context.Context) (kafka.Message, error) {
+//		m, err := c.r.FetchMessage(ctx)
+//		return kafka.Message{Topic: m.Topic, Partition: m.Partition, Offset: m.Offset,
+//			Key: m.Key, Value: m.Value, Time: m.Time}, err
+//	}
+//
+//	func (c consumer) Commit(ctx context.Context, topic string, partition int, next int64) error {
+//		return c.r.CommitMessages(ctx, kafkago.Message{Topic: topic, Partition: partition, Offset: next - 1})
+//	}
+//
+// with the Writer's WriteErrors turned into pipeline.BatchErrors by Produce.
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a Kafka message.
+type Message struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+	Time      time.Time
+}
+
+// Consumer is the part of a Kafka consumer group client used by Source.
+type Consumer interface {
+	// Fetch returns the next message of the partitions assigned to the
+	// consumer, without committing it.
+	Fetch(ctx context.Context) (Message, error)
+	// Commit commits the offset of the next message to consume from a
+	// partition, i.e. one past the last one processed.
+	Commit(ctx context.Context, topic string, partition int, next int64) error
+}
+
+// Source is a pipeline.Source consuming Kafka messages. Every message is sent
+// into the pipeline in a pipeline.Envelope carrying the Message as payload,
+// its key as Key and its topic, partition and offset as the "kafka.topic",
+// "kafka.partition" and "kafka.offset" attributes.
+//
+// Offsets are tracked by a pipeline.Checkpointer and only committed up to the
+// first message of a partition that wasn't acknowledged yet, see
+// pipeline.Acknowledger, when the Checkpointer is flushed. Pass it to a Job,
+// which flushes it periodically and at the end of the run:
+//
+//	src, _ := kafka.NewSource(consumer, "orders-etl")
+//	job := &pipeline.Job{
+//		Source:             src,
+//		Checkpointer:       src.Checkpointer(),
+//		CheckpointInterval: 5 * time.Second,
+//		...
+//	}
+type Source struct {
+	consumer     Consumer
+	checkpointer *pipeline.Checkpointer
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewSource creates a Source for a consumer of the given consumer group.
+func NewSource(consumer Consumer, group string) (*Source, error) {
+	checkpointer, err := pipeline.NewCheckpointer(group, &offsetStore{consumer: consumer})
+	if err != nil {
+		return nil, err
+	}
+	return &Source{consumer: consumer, checkpointer: checkpointer}, nil
+}
+
+// Checkpointer returns the Checkpointer committing the offsets.
+func (s *Source) Checkpointer() *pipeline.Checkpointer {
+	return s.checkpointer
+}
+
+// Open implements pipeline.Source. Messages are fetched until ctx is done or
+// fetching fails, see Err.
+func (s *Source) Open(ctx context.Context) (<-chan interface{}, error) {
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+		for {
+			msg, err := s.consumer.Fetch(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					s.mu.Lock()
+					s.err = err
+					s.mu.Unlock()
+				}
+				return
+			}
+
+			env := &pipeline.Envelope{Payload: msg, Key: string(msg.Key)}
+			env.SetAttr("kafka.topic", msg.Topic)
+			env.SetAttr("kafka.partition", strconv.Itoa(msg.Partition))
+			env.SetAttr("kafka.offset", strconv.FormatInt(msg.Offset, 10))
+			env.Acker = s.checkpointer.Track(partitionName(msg.Topic, msg.Partition), msg.Offset, nil)
+			select {
+			case ch <- env:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// Err returns the error that stopped fetching messages, nil if ctx did. It
+// must be called once the channel is closed.
+func (s *Source) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func partitionName(topic string, partition int) string {
+	return topic + "/" + strconv.Itoa(partition)
+}
+
+// offsetStore is a pipeline.CheckpointStore committing the offsets to Kafka,
+// where the consumer group keeps them.
+type offsetStore struct {
+	consumer Consumer
+}
+
+func (s *offsetStore) Save(name string, positions map[string]int64) error {
+	for name, offset := range positions {
+		i := strings.LastIndexByte(name, '/')
+		partition, err := strconv.Atoi(name[i+1:])
+		if i < 0 || err != nil {
+			return fmt.Errorf("kafka: invalid partition %q", name)
+		}
+		if err := s.consumer.Commit(context.Background(), name[:i], partition, offset+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load returns no positions: the consumer group resumes from its committed
+// offsets by itself.
+func (s *offsetStore) Load(name string) (map[string]int64, error) {
+	return nil, nil
+}
+
+// Producer is the part of a Kafka producer client used by Sink.
+type Producer interface {
+	// Produce publishes messages and returns once the brokers acknowledged
+	// them, the delivery report. If only some of them failed, it returns
+	// pipeline.BatchErrors holding the error of each message.
+	Produce(ctx context.Context, msgs []Message) error
+}
+
+// Sink is a pipeline.Sink publishing objects to a topic, in batches. A write
+// returns once the message of its object was acknowledged, so that failed
+// deliveries fail their objects, which then go to the dead-letter function of
+// the pipeline. Batches fill up with the objects written concurrently: the
+// sink should be added with a fan size of at least BatchSize.
+type Sink struct {
+	producer Producer
+	topic    string
+
+	// Key, if set, returns the key of the message of an object, e.g. to
+	// keep the messages of a customer in order on a partition.
+	Key func(obj interface{}) []byte
+
+	// Encode turns an object into the value of a message. By default
+	// Message objects are published as is, to their own topic if they have
+	// one, []byte and string objects as the value, and other objects fail.
+	Encode func(obj interface{}) ([]byte, error)
+
+	// BatchSize is the number of messages published at a time, 100 if
+	// zero, and FlushInterval how long a batch waits to fill up, 10ms if
+	// zero. They must be set before the first write.
+	BatchSize     int
+	FlushInterval time.Duration
+
+	once  sync.Once
+	batch *pipeline.BatchSink
+}
+
+// NewSink creates a Sink publishing to topic with producer.
+func NewSink(producer Producer, topic string) *Sink {
+	return &Sink{producer: producer, topic: topic}
+}
+
+// Write implements pipeline.Sink.
+func (s *Sink) Write(obj interface{}) error {
+	msg, err := s.message(obj)
+	if err != nil {
+		return err
+	}
+	return s.batchSink().Write(msg)
+}
+
+// Flush implements pipeline.Flushable, publishing the batch filling up right
+// away.
+func (s *Sink) Flush(ctx context.Context) error {
+	return s.batchSink().Flush(ctx)
+}
+
+func (s *Sink) batchSink() *pipeline.BatchSink {
+	s.once.Do(func() {
+		size := s.BatchSize
+		if size <= 0 {
+			size = 100
+		}
+		interval := s.FlushInterval
+		if interval <= 0 {
+			interval = 10 * time.Millisecond
+		}
+		s.batch = pipeline.NewBatchSink(size, interval, func(objs []interface{}) error {
+			msgs := make([]Message, len(objs))
+			for i, obj := range objs {
+				msgs[i] = obj.(Message)
+			}
+			return s.producer.Produce(context.Background(), msgs)
+		})
+	})
+	return s.batch
+}
+
+func (s *Sink) message(obj interface{}) (msg Message, err error) {
+	switch o := obj.(type) {
+	case Message:
+		msg = o
+	case []byte:
+		msg.Value = o
+	case string:
+		msg.Value = []byte(o)
+	}
+	if s.Encode != nil {
+		if msg.Value, err = s.Encode(obj); err != nil {
+			return
+		}
+	} else if msg.Value == nil {
+		return msg, fmt.Errorf("kafka: can't publish %T", obj)
+	}
+	if msg.Topic == "" {
+		msg.Topic = s.topic
+	}
+	if s.Key != nil {
+		msg.Key = s.Key(obj)
+	}
+	return
+}
@@ -0,0 +1,100 @@
+package kafka_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/connectors/kafka"
+)
+
+// fakeConsumer serves a fixed list of messages.
+type fakeConsumer struct {
+	messages []kafka.Message
+}
+
+func (c *fakeConsumer) Fetch(ctx context.Context) (kafka.Message, error) {
+	if len(c.messages) == 0 {
+		<-ctx.Done()
+		return kafka.Message{}, ctx.Err()
+	}
+	msg := c.messages[0]
+	c.messages = c.messages[1:]
+	return msg, nil
+}
+
+func (c *fakeConsumer) Commit(ctx context.Context, topic string, partition int, next int64) error {
+	fmt.Printf("commit %s/%d at %d\n", topic, partition, next)
+	return nil
+}
+
+func ExampleSource() {
+	consumer := &fakeConsumer{messages: []kafka.Message{
+		{Topic: "orders", Partition: 0, Offset: 41, Value: []byte("ok")},
+		{Topic: "orders", Partition: 0, Offset: 42, Value: []byte("ok")},
+		{Topic: "orders", Partition: 0, Offset: 43, Value: []byte("ok")},
+	}}
+	src, _ := kafka.NewSource(consumer, "orders-etl")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := pipeline.New()
+	p.AddStage(func(inObj interface{}) interface{} {
+		msg := inObj.(kafka.Message)
+		fmt.Printf("processed %d: %s\n", msg.Offset, msg.Value)
+		if msg.Offset == 43 {
+			cancel()
+		}
+		return inObj
+	})
+
+	in, _ := src.Open(ctx)
+	<-p.Run(in)
+	src.Checkpointer().Flush()
+
+	// Output: processed 41: ok
+	// processed 42: ok
+	// processed 43: ok
+	// commit orders/0 at 44
+}
+
+// fakeProducer rejects the messages without a key.
+type fakeProducer struct{}
+
+func (fakeProducer) Produce(ctx context.Context, msgs []kafka.Message) error {
+	fmt.Println("producing", len(msgs), "messages")
+	errs := make(pipeline.BatchErrors, len(msgs))
+	for i, msg := range msgs {
+		if len(msg.Key) == 0 {
+			errs[i] = errors.New("missing key")
+		}
+	}
+	return errs
+}
+
+func ExampleSink() {
+	sink := kafka.NewSink(fakeProducer{}, "orders-enriched")
+	sink.BatchSize = 3
+	sink.Key = func(obj interface{}) []byte {
+		return []byte(obj.(map[string]string)["customer"])
+	}
+	sink.Encode = func(obj interface{}) ([]byte, error) {
+		return json.Marshal(obj)
+	}
+
+	p := pipeline.New()
+	p.AddSink(sink, 3)
+	p.SetDeadLetter(func(err *pipeline.ItemError) {
+		fmt.Println("dead-lettered", err.Obj, err.Err)
+	})
+
+	ch := make(chan interface{}, 3)
+	ch <- map[string]string{"order": "1", "customer": "ada"}
+	ch <- map[string]string{"order": "2"}
+	ch <- map[string]string{"order": "3", "customer": "grace"}
+	close(ch)
+	<-p.Run(ch)
+
+	// Output: producing 3 messages
+	// dead-lettered map[order:2] missing key
+}
@@ -0,0 +1,161 @@
+// Package nats connects pipelines to NATS subjects: a Source subscribing to a
+// subject and a Sink publishing the outputs of a pipeline to another one.
+// With JetStream, messages are acknowledged once the pipeline processed them,
+// and negatively acknowledged for redelivery when it failed to.
+//
+// The package doesn't depend on the NATS client. The Subscriber and Publisher
+// interfaces are small enough to wrap it, e.g. for a JetStream pull consumer
+// of github.com/nats-io/nats.go/jetstream:
+//
+//	type subscriber struct{ it jetstream.MessagesContext }
+//
+//	func (s subscriber) Next(ctx context.Context) (nats.Msg, error) {
+//		m, err := s.it.Next()
+//		if err != nil {
+//			return nats.Msg{}, err
+//		}
+//		return nats.Msg{Subject: m.Subject(), Data: m.Data(), Header: m.Headers(),
+//			Ack: m.Ack, Nak: m.Nak}, nil
+//	}
+package nats
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"sync"
+)
+
+// Msg is a NATS message.
+type Msg struct {
+	Subject string
+	Data    []byte
+	Header  map[string][]string
+
+	// Ack and Nak acknowledge a JetStream message, positively or
+	// negatively. They are nil for core NATS messages.
+	Ack func() error
+	Nak func() error
+}
+
+// Subscriber is the part of a NATS subscription used by Source.
+type Subscriber interface {
+	// Next returns the next message of the subscription.
+	Next(ctx context.Context) (Msg, error)
+}
+
+// Source is a pipeline.Source receiving the messages of a subscription. Every
+// message is sent into the pipeline in a pipeline.Envelope carrying the Msg as
+// payload and its subject as Key, and acknowledged through the Acker of the
+// envelope, see pipeline.Acknowledger.
+type Source struct {
+	subscriber Subscriber
+
+	// OnAckError, if set, is called when acknowledging a message fails.
+	// JetStream then redelivers the message after its ack wait.
+	OnAckError func(msg Msg, err error)
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewSource creates a Source receiving the messages of subscriber.
+func NewSource(subscriber Subscriber) *Source {
+	return &Source{subscriber: subscriber}
+}
+
+// Open implements pipeline.Source. Messages are received until ctx is done
+// or receiving fails, see Err.
+func (s *Source) Open(ctx context.Context) (<-chan interface{}, error) {
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+		for {
+			msg, err := s.subscriber.Next(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					s.mu.Lock()
+					s.err = err
+					s.mu.Unlock()
+				}
+				return
+			}
+
+			env := &pipeline.Envelope{Payload: msg, Key: msg.Subject}
+			if msg.Ack != nil || msg.Nak != nil {
+				env.Acker = pipeline.NewAcknowledger(func() {
+					s.acked(msg, msg.Ack)
+				}, func(error) {
+					s.acked(msg, msg.Nak)
+				})
+			}
+			select {
+			case ch <- env:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (s *Source) acked(msg Msg, ack func() error) {
+	if ack == nil {
+		return
+	}
+	if err := ack(); err != nil && s.OnAckError != nil {
+		s.OnAckError(msg, err)
+	}
+}
+
+// Err returns the error that stopped receiving messages, nil if ctx did. It
+// must be called once the channel is closed.
+func (s *Source) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Publisher is the part of a NATS connection used by Sink. With JetStream,
+// Publish must wait for the acknowledgement of the stream, so that the
+// objects that the Sink passes on are stored.
+type Publisher interface {
+	Publish(ctx context.Context, subject string, data []byte, header map[string][]string) error
+}
+
+// Sink is a pipeline.Sink publishing objects to a subject.
+type Sink struct {
+	publisher Publisher
+	subject   string
+
+	// Encode turns an object into the data of a message. By default []byte,
+	// string and Msg objects are published as is, other objects fail.
+	Encode func(obj interface{}) ([]byte, error)
+}
+
+// NewSink creates a Sink publishing to subject.
+func NewSink(publisher Publisher, subject string) *Sink {
+	return &Sink{publisher: publisher, subject: subject}
+}
+
+// Write implements pipeline.Sink.
+func (s *Sink) Write(obj interface{}) error {
+	ctx := context.Background()
+	if s.Encode != nil {
+		data, err := s.Encode(obj)
+		if err != nil {
+			return err
+		}
+		return s.publisher.Publish(ctx, s.subject, data, nil)
+	}
+
+	switch o := obj.(type) {
+	case Msg:
+		return s.publisher.Publish(ctx, s.subject, o.Data, o.Header)
+	case []byte:
+		return s.publisher.Publish(ctx, s.subject, o, nil)
+	case string:
+		return s.publisher.Publish(ctx, s.subject, []byte(o), nil)
+	}
+	return fmt.Errorf("nats: can't publish %T", obj)
+}
@@ -0,0 +1,60 @@
+package nats_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/connectors/nats"
+	"strings"
+)
+
+// fakeSubscriber serves a fixed list of JetStream messages.
+type fakeSubscriber struct {
+	messages []string
+}
+
+func (s *fakeSubscriber) Next(ctx context.Context) (nats.Msg, error) {
+	if len(s.messages) == 0 {
+		<-ctx.Done()
+		return nats.Msg{}, ctx.Err()
+	}
+	data := s.messages[0]
+	s.messages = s.messages[1:]
+	return nats.Msg{
+		Subject: "orders.created",
+		Data:    []byte(data),
+		Ack: func() error {
+			fmt.Println("ack", data)
+			return nil
+		},
+		Nak: func() error {
+			fmt.Println("nak", data)
+			return nil
+		},
+	}, nil
+}
+
+type printPublisher struct{}
+
+func (printPublisher) Publish(ctx context.Context, subject string, data []byte, header map[string][]string) error {
+	fmt.Printf("publish %s: %s\n", subject, data)
+	return nil
+}
+
+func Example() {
+	ctx, cancel := context.WithCancel(context.Background())
+	src := nats.NewSource(&fakeSubscriber{messages: []string{"book"}})
+
+	p := pipeline.New()
+	p.AddStage(func(inObj interface{}) interface{} {
+		defer cancel()
+		return strings.ToUpper(string(inObj.(nats.Msg).Data))
+	})
+	p.AddSink(nats.NewSink(printPublisher{}, "orders.normalized"), 1)
+
+	in, _ := src.Open(ctx)
+	<-p.Run(in)
+
+	// Output: publish orders.normalized: BOOK
+	// ack book
+}
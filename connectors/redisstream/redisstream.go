@@ -0,0 +1,189 @@
+// Package redisstream connects pipelines to Redis Streams through consumer
+// groups, for reliable streaming without running Kafka: entries are only
+// acknowledged with XACK once the pipeline processed them, and the entries
+// left pending by crashed consumers are reclaimed with XAUTOCLAIM.
+//
+// The package doesn't depend on a Redis client. The Client interface is small
+// enough to wrap any client, e.g. github.com/redis/go-redis:
+//
+//	type client struct{ rdb *redis.Client }
+//
+//	func (c client) Ack(ctx context.Context, stream, group string, ids ...string) error {
+//		return c.rdb.XAck(ctx, stream, group, ids...).Err()
+//	}
+//
+// and so on for ReadGroup (XREADGROUP) and AutoClaim (XAUTOCLAIM).
+package redisstream
+
+import (
+	"context"
+	"github.com/hyfather/pipeline"
+	"sync"
+	"time"
+)
+
+// Entry is an entry of a stream.
+type Entry struct {
+	Stream string
+	ID     string
+	Values map[string]string
+}
+
+// Client is the part of a Redis client used by Source.
+type Client interface {
+	// ReadGroup reads up to count new entries for a consumer of a group,
+	// blocking for up to block if there is none:
+	// XREADGROUP GROUP group consumer COUNT count BLOCK block STREAMS stream >
+	ReadGroup(ctx context.Context, stream, group, consumer string, count int, block time.Duration) ([]Entry, error)
+
+	// Ack acknowledges entries: XACK stream group id...
+	Ack(ctx context.Context, stream, group string, ids ...string) error
+
+	// AutoClaim claims up to count entries pending for longer than minIdle,
+	// from the start cursor, and returns the cursor to continue from, "0-0"
+	// once all were scanned:
+	// XAUTOCLAIM stream group consumer minIdle start COUNT count
+	AutoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, start string, count int) (entries []Entry, next string, err error)
+}
+
+// Source is a pipeline.Source reading a stream as a consumer of a group.
+// Every entry is sent into the pipeline in a pipeline.Envelope carrying the
+// Entry as payload and its ID as Key. Entries are acknowledged once they are
+// processed, see pipeline.Acknowledger. Failed entries are left pending, to
+// be reclaimed.
+type Source struct {
+	client   Client
+	stream   string
+	group    string
+	consumer string
+
+	// BatchSize is the number of entries read at a time, 100 if zero.
+	BatchSize int
+
+	// Block is how long a read waits for new entries, 5s if zero. It bounds
+	// the time it takes to notice that the context of Open is done.
+	Block time.Duration
+
+	// ReclaimIdle, if set, makes the source reclaim the entries pending for
+	// longer than it, such as the entries of crashed consumers and the
+	// entries that failed, every ReclaimInterval (ReclaimIdle if zero).
+	ReclaimIdle     time.Duration
+	ReclaimInterval time.Duration
+
+	// OnAckError, if set, is called when acknowledging an entry fails.
+	OnAckError func(entry Entry, err error)
+
+	mu       sync.Mutex
+	inFlight map[string]bool
+	err      error
+}
+
+// NewSource creates a Source reading stream as consumer in group. The group
+// must exist, e.g. created with XGROUP CREATE.
+func NewSource(client Client, stream, group, consumer string) *Source {
+	return &Source{client: client, stream: stream, group: group, consumer: consumer, inFlight: map[string]bool{}}
+}
+
+// Open implements pipeline.Source. Entries are read until ctx is done or
+// reading fails, see Err.
+func (s *Source) Open(ctx context.Context) (<-chan interface{}, error) {
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	block := s.Block
+	if block <= 0 {
+		block = 5 * time.Second
+	}
+	reclaimInterval := s.ReclaimInterval
+	if reclaimInterval <= 0 {
+		reclaimInterval = s.ReclaimIdle
+	}
+
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+		var lastReclaim time.Time
+		for {
+			var entries []Entry
+			var err error
+			if s.ReclaimIdle > 0 && time.Since(lastReclaim) >= reclaimInterval {
+				lastReclaim = time.Now()
+				entries, err = s.reclaim(ctx, batchSize)
+			}
+			if err == nil && len(entries) == 0 {
+				entries, err = s.client.ReadGroup(ctx, s.stream, s.group, s.consumer, batchSize, block)
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					s.mu.Lock()
+					s.err = err
+					s.mu.Unlock()
+				}
+				return
+			}
+
+			for _, entry := range entries {
+				select {
+				case ch <- s.envelope(entry):
+				case <-ctx.Done():
+					return
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// reclaim claims the entries idle for too long, skipping the ones still in
+// flight in this source.
+func (s *Source) reclaim(ctx context.Context, count int) (claimed []Entry, err error) {
+	for start := "0-0"; ; {
+		var entries []Entry
+		entries, start, err = s.client.AutoClaim(ctx, s.stream, s.group, s.consumer, s.ReclaimIdle, start, count)
+		if err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		for _, entry := range entries {
+			if !s.inFlight[entry.ID] {
+				claimed = append(claimed, entry)
+			}
+		}
+		s.mu.Unlock()
+		if start == "0-0" || start == "" || len(claimed) >= count {
+			return
+		}
+	}
+}
+
+func (s *Source) envelope(entry Entry) *pipeline.Envelope {
+	s.mu.Lock()
+	s.inFlight[entry.ID] = true
+	s.mu.Unlock()
+	done := func() {
+		s.mu.Lock()
+		delete(s.inFlight, entry.ID)
+		s.mu.Unlock()
+	}
+
+	return &pipeline.Envelope{Payload: entry, Key: entry.ID, Acker: pipeline.NewAcknowledger(func() {
+		defer done()
+		if err := s.client.Ack(context.Background(), s.stream, s.group, entry.ID); err != nil && s.OnAckError != nil {
+			s.OnAckError(entry, err)
+		}
+	}, func(error) {
+		done()
+	})}
+}
+
+// Err returns the error that stopped reading entries, nil if ctx did. It
+// must be called once the channel is closed.
+func (s *Source) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
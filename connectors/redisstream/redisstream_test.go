@@ -0,0 +1,67 @@
+package redisstream_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/connectors/redisstream"
+	"time"
+)
+
+// fakeClient serves new entries once, and an entry left pending by a crashed
+// consumer.
+type fakeClient struct {
+	entries []redisstream.Entry
+	pending []redisstream.Entry
+}
+
+func (c *fakeClient) ReadGroup(ctx context.Context, stream, group, consumer string, count int, block time.Duration) ([]redisstream.Entry, error) {
+	entries := c.entries
+	c.entries = nil
+	if len(entries) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return entries, nil
+}
+
+func (c *fakeClient) Ack(ctx context.Context, stream, group string, ids ...string) error {
+	fmt.Println("XACK", stream, group, ids)
+	return nil
+}
+
+func (c *fakeClient) AutoClaim(ctx context.Context, stream, group, consumer string, minIdle time.Duration, start string, count int) ([]redisstream.Entry, string, error) {
+	pending := c.pending
+	c.pending = nil
+	return pending, "0-0", nil
+}
+
+func ExampleSource() {
+	client := &fakeClient{
+		pending: []redisstream.Entry{{ID: "1-0", Values: map[string]string{"n": "1"}}},
+		entries: []redisstream.Entry{
+			{ID: "2-0", Values: map[string]string{"n": "2"}},
+			{ID: "3-0", Values: map[string]string{"n": "-3"}},
+		},
+	}
+	src := redisstream.NewSource(client, "events", "etl", "worker-1")
+	src.ReclaimIdle = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := pipeline.New()
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		entry := inObj.(redisstream.Entry)
+		if entry.ID == "3-0" {
+			defer cancel()
+			return nil, errors.New("negative")
+		}
+		return inObj, nil
+	}, 1)
+
+	in, _ := src.Open(ctx)
+	<-p.Run(in)
+
+	// Output: XACK events etl [1-0]
+	// XACK events etl [2-0]
+}
@@ -0,0 +1,213 @@
+// Package sqs connects pipelines to Amazon SQS queues: messages are received
+// in batches, their visibility timeout is extended for as long as they are in
+// flight through the pipeline, and they are only deleted once processed.
+//
+// The package doesn't depend on the AWS SDK. The Client interface is small
+// enough to wrap any SDK, e.g. github.com/aws/aws-sdk-go-v2/service/sqs:
+//
+//	type client struct{ api *sqs.Client }
+//
+//	func (c client) Delete(ctx context.Context, queueURL, receiptHandle string) error {
+//		_, err := c.api.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+//			QueueUrl: &queueURL, ReceiptHandle: &receiptHandle})
+//		return err
+//	}
+//
+// and so on for Receive (ReceiveMessage) and ChangeVisibility
+// (ChangeMessageVisibility).
+package sqs
+
+import (
+	"context"
+	"github.com/hyfather/pipeline"
+	"sync"
+	"time"
+)
+
+// Message is a message received from a queue.
+type Message struct {
+	ID            string
+	ReceiptHandle string
+	Body          string
+	Attributes    map[string]string
+}
+
+// Client is the part of an SQS client used by Source.
+type Client interface {
+	// Receive receives up to max messages, hidden from other consumers
+	// for visibilityTimeout, long polling for up to wait if there is none.
+	Receive(ctx context.Context, queueURL string, max int, wait, visibilityTimeout time.Duration) ([]Message, error)
+
+	// ChangeVisibility hides a received message for timeout from now on.
+	ChangeVisibility(ctx context.Context, queueURL, receiptHandle string, timeout time.Duration) error
+
+	// Delete deletes a received message.
+	Delete(ctx context.Context, queueURL, receiptHandle string) error
+}
+
+// Source is a pipeline.Source receiving the messages of a queue. Every
+// message is sent into the pipeline in a pipeline.Envelope carrying the
+// Message as payload and its ID as Key. While a message is in flight, its
+// visibility timeout is extended every ExtendInterval so that it isn't
+// redelivered to another consumer. It is deleted once it is processed, see
+// pipeline.Acknowledger. A message that failed is no longer extended, and is
+// redelivered once its visibility timeout expires, or moved to the
+// dead-letter queue of the queue by its redrive policy.
+type Source struct {
+	client   Client
+	queueURL string
+
+	// MaxMessages is the number of messages received at a time, 10 (the
+	// most SQS allows) if zero.
+	MaxMessages int
+
+	// WaitTime is how long a receive long polls for messages, 20s if zero.
+	// It bounds the time it takes to notice that the context of Open is
+	// done.
+	WaitTime time.Duration
+
+	// VisibilityTimeout is how long the messages in flight are hidden from
+	// other consumers past their last extension, 30s if zero.
+	VisibilityTimeout time.Duration
+
+	// ExtendInterval is how often the visibility timeout of the messages in
+	// flight is extended, half the VisibilityTimeout if zero.
+	ExtendInterval time.Duration
+
+	// OnError, if set, is called when extending the visibility timeout of a
+	// message or deleting it fails.
+	OnError func(msg Message, err error)
+
+	mu       sync.Mutex
+	inFlight map[string]Message // by receipt handle
+	err      error
+}
+
+// NewSource creates a Source receiving the messages of the queue at queueURL.
+func NewSource(client Client, queueURL string) *Source {
+	return &Source{client: client, queueURL: queueURL, inFlight: map[string]Message{}}
+}
+
+// Open implements pipeline.Source. Messages are received until ctx is done or
+// receiving fails, see Err. The visibility timeouts are extended until then
+// and for as long as messages are in flight afterwards.
+func (s *Source) Open(ctx context.Context) (<-chan interface{}, error) {
+	maxMessages := s.MaxMessages
+	if maxMessages <= 0 {
+		maxMessages = 10
+	}
+	wait := s.WaitTime
+	if wait <= 0 {
+		wait = 20 * time.Second
+	}
+	visibility := s.VisibilityTimeout
+	if visibility <= 0 {
+		visibility = 30 * time.Second
+	}
+	interval := s.ExtendInterval
+	if interval <= 0 {
+		interval = visibility / 2
+	}
+
+	ch := make(chan interface{})
+	received := make(chan struct{})
+	go s.extend(interval, visibility, received)
+	go func() {
+		defer close(ch)
+		defer close(received)
+		for {
+			msgs, err := s.client.Receive(ctx, s.queueURL, maxMessages, wait, visibility)
+			if err != nil {
+				if ctx.Err() == nil {
+					s.mu.Lock()
+					s.err = err
+					s.mu.Unlock()
+				}
+				return
+			}
+
+			for i, msg := range msgs {
+				select {
+				case ch <- s.envelope(msg):
+				case <-ctx.Done():
+					// the messages not sent are redelivered once
+					// their visibility timeout expires
+					for _, msg := range msgs[i:] {
+						s.release(msg)
+					}
+					return
+				}
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// extend extends the visibility timeout of the messages in flight every
+// interval, until no more messages are received nor in flight.
+func (s *Source) extend(interval, visibility time.Duration, received <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		select {
+		case <-received:
+			s.mu.Lock()
+			n := len(s.inFlight)
+			s.mu.Unlock()
+			if n == 0 {
+				return
+			}
+		default:
+		}
+
+		s.mu.Lock()
+		msgs := make([]Message, 0, len(s.inFlight))
+		for _, msg := range s.inFlight {
+			msgs = append(msgs, msg)
+		}
+		s.mu.Unlock()
+		for _, msg := range msgs {
+			if err := s.client.ChangeVisibility(context.Background(), s.queueURL, msg.ReceiptHandle, visibility); err != nil {
+				s.failed(msg, err)
+			}
+		}
+	}
+}
+
+func (s *Source) envelope(msg Message) *pipeline.Envelope {
+	s.mu.Lock()
+	s.inFlight[msg.ReceiptHandle] = msg
+	s.mu.Unlock()
+
+	return &pipeline.Envelope{Payload: msg, Key: msg.ID, Acker: pipeline.NewAcknowledger(func() {
+		s.release(msg)
+		if err := s.client.Delete(context.Background(), s.queueURL, msg.ReceiptHandle); err != nil {
+			s.failed(msg, err)
+		}
+	}, func(error) {
+		s.release(msg)
+	})}
+}
+
+func (s *Source) release(msg Message) {
+	s.mu.Lock()
+	delete(s.inFlight, msg.ReceiptHandle)
+	s.mu.Unlock()
+}
+
+func (s *Source) failed(msg Message, err error) {
+	if s.OnError != nil {
+		s.OnError(msg, err)
+	}
+}
+
+// Err returns the error that stopped receiving messages, nil if ctx did. It
+// must be called once the channel is closed.
+func (s *Source) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
@@ -0,0 +1,78 @@
+package sqs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/connectors/sqs"
+	"sync"
+	"time"
+)
+
+// fakeClient serves a single batch of messages and records what happens to
+// them.
+type fakeClient struct {
+	mu       sync.Mutex
+	batch    []sqs.Message
+	extended map[string]bool
+	deleted  []string
+}
+
+func (c *fakeClient) Receive(ctx context.Context, queueURL string, max int, wait, visibilityTimeout time.Duration) ([]sqs.Message, error) {
+	c.mu.Lock()
+	batch := c.batch
+	c.batch = nil
+	c.mu.Unlock()
+	if len(batch) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return batch, nil
+}
+
+func (c *fakeClient) ChangeVisibility(ctx context.Context, queueURL, receiptHandle string, timeout time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.extended[receiptHandle] = true
+	return nil
+}
+
+func (c *fakeClient) Delete(ctx context.Context, queueURL, receiptHandle string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted = append(c.deleted, receiptHandle)
+	return nil
+}
+
+func Example() {
+	client := &fakeClient{extended: map[string]bool{}, batch: []sqs.Message{
+		{ID: "1", ReceiptHandle: "rh-1", Body: "resize photo.jpg"},
+		{ID: "2", ReceiptHandle: "rh-2", Body: "resize broken.jpg"},
+	}}
+	src := sqs.NewSource(client, "https://sqs.eu-west-1.amazonaws.com/123456789012/thumbnails")
+	src.VisibilityTimeout = 20 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := pipeline.New()
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		msg := inObj.(sqs.Message)
+		if msg.ID == "2" {
+			defer cancel()
+			return nil, errors.New("corrupt image")
+		}
+		time.Sleep(50 * time.Millisecond) // outlives the visibility timeout
+		return msg.Body, nil
+	}, 1)
+
+	in, _ := src.Open(ctx)
+	<-p.Run(in)
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	fmt.Println("extended:", client.extended["rh-1"])
+	fmt.Println("deleted:", client.deleted)
+
+	// Output: extended: true
+	// deleted: [rh-1]
+}
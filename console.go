@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// consoleSampleTimeout bounds how long the sample command waits for objects.
+const consoleSampleTimeout = 5 * time.Second
+
+const consoleHelp = `commands:
+  stages                  list the stages with their fan size and counters
+  sample STAGE [N]        print the next N (default 1) objects out of a stage
+  fanout STAGE N          change the fan size of a stage
+  pause STAGE             stop a stage from taking new objects
+  resume STAGE            resume a paused stage
+  help                    print this help
+  quit                    close the session
+`
+
+// ListenConsole serves a debug console for the pipeline on a unix socket,
+// which can be used with e.g. `nc -U path` or `socat - UNIX-CONNECT:path`. The
+// console is a line-based runtime operations shell: it lists the stages,
+// samples live objects, adjusts fan-out and pauses stages. Type "help" for the
+// list of commands.
+//
+// The console serves in the background until the returned Closer is closed,
+// which also removes the socket file.
+func (p *Pipeline) ListenConsole(socketPath string) (io.Closer, error) {
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return nil, err
+	}
+	go p.ServeConsole(l)
+	return l, nil
+}
+
+// ServeConsole serves the console described in ListenConsole on connections
+// accepted from l, until l is closed.
+func (p *Pipeline) ServeConsole(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go p.serveConsoleConn(conn)
+	}
+}
+
+func (p *Pipeline) serveConsoleConn(conn net.Conn) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		args := strings.Fields(scanner.Text())
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "quit" || args[0] == "exit" {
+			return
+		}
+		if err := p.consoleCommand(conn, args); err != nil {
+			fmt.Fprintln(conn, "error:", err)
+		}
+	}
+}
+
+func (p *Pipeline) consoleCommand(w io.Writer, args []string) error {
+	switch args[0] {
+	case "help":
+		fmt.Fprint(w, consoleHelp)
+		return nil
+
+	case "stages":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "STAGE\tFAN\tPAUSED\tIN\tOUT\tDROPPED\tERRORS")
+		stages := p.stageList()
+		for i, st := range statsOf(stages).Stages {
+			s := stages[i]
+			fan := "raw"
+			if s.raw == nil {
+				fan = strconv.FormatUint(s.control.getFanSize(), 10)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%t\t%d\t%d\t%d\t%d\n",
+				st.Name, fan, s.control.isPaused(), st.In, st.Out, st.Dropped, st.Errors)
+		}
+		return tw.Flush()
+
+	case "sample":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: sample STAGE [N]")
+		}
+		n := 1
+		if len(args) > 2 {
+			var err error
+			if n, err = strconv.Atoi(args[2]); err != nil || n < 1 {
+				return fmt.Errorf("invalid count %q", args[2])
+			}
+		}
+		tap, untap, err := p.Tap(args[1], n)
+		if err != nil {
+			return err
+		}
+		defer untap()
+
+		timeout := time.After(consoleSampleTimeout)
+		for i := 0; i < n; i++ {
+			select {
+			case obj := <-tap:
+				fmt.Fprintf(w, "%#v\n", obj)
+			case <-timeout:
+				return fmt.Errorf("no object in %s", consoleSampleTimeout)
+			}
+		}
+		return nil
+
+	case "fanout":
+		if len(args) != 3 {
+			return fmt.Errorf("usage: fanout STAGE N")
+		}
+		n, err := strconv.ParseUint(args[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid fan size %q", args[2])
+		}
+		return consoleOK(w, p.SetFanOut(args[1], n))
+
+	case "pause", "resume":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: %s STAGE", args[0])
+		}
+		if args[0] == "pause" {
+			return consoleOK(w, p.PauseStage(args[1]))
+		}
+		return consoleOK(w, p.ResumeStage(args[1]))
+	}
+	return fmt.Errorf("unknown command %q, try help", args[0])
+}
+
+func consoleOK(w io.Writer, err error) error {
+	if err == nil {
+		fmt.Fprintln(w, "ok")
+	}
+	return err
+}
@@ -0,0 +1,35 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+func ExamplePipeline_ListenConsole() {
+	dir, _ := ioutil.TempDir("", "console")
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "pipeline.sock")
+
+	p := pipeline.New()
+	p.AddStageWithFanOut(squareStage, 2)
+	console, err := p.ListenConsole(socket)
+	if err != nil {
+		panic(err)
+	}
+	defer console.Close()
+
+	conn, _ := net.Dial("unix", socket)
+	defer conn.Close()
+	fmt.Fprint(conn, "fanout stage0 4\npause stage0\nstages\nquit\n")
+	io.Copy(os.Stdout, conn)
+
+	// Output: ok
+	// ok
+	// STAGE   FAN  PAUSED  IN  OUT  DROPPED  ERRORS
+	// stage0  4    true    0   0    0        0
+}
@@ -0,0 +1,321 @@
+package pipeline
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// stageControl is the runtime control of a stage, shared by the instances of
+// the stage in all the runs of the pipeline. It allows changing the fan size,
+// pausing the stage and tapping its output while it runs.
+type stageControl struct {
+	// holdUntil, paused and taps are read atomically on every object
+	holdUntil int64 // unix nanoseconds, see RetryAfterError
+	paused    int32
+	taps      int32
+
+	mu        sync.Mutex
+	fanSize   uint64
+	resume    chan struct{} // closed when the stage is resumed
+	changed   chan struct{} // closed when the fan size changes or the stage is resumed
+	instances map[*stageInstance]struct{}
+	tapChans  map[chan interface{}]struct{}
+}
+
+// stageInstance is a stage running within a single run of the pipeline.
+type stageInstance struct {
+	cfg        *stageConfig
+	inChan     <-chan interface{}
+	outChan    chan interface{}
+	dispatcher *dispatcher // nil unless the objects are dispatched to lanes
+
+	// mu is held for reading by the goroutines while they pass an object on,
+	// and for writing to change the function or outChan of a running
+	// instance, see ReplaceStage and InsertStage
+	mu     sync.RWMutex
+	closed bool // once outChan is closed, guarded by mu
+
+	// guarded by the mutex of the stageControl
+	quits    []chan struct{} // one per goroutine
+	running  int
+	draining bool
+}
+
+func newStageControl(fanSize uint64) *stageControl {
+	return &stageControl{
+		fanSize:   fanSize,
+		changed:   make(chan struct{}),
+		instances: map[*stageInstance]struct{}{},
+		tapChans:  map[chan interface{}]struct{}{},
+	}
+}
+
+func (ctl *stageControl) getFanSize() uint64 {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	return ctl.fanSize
+}
+
+// start registers a new instance and starts its goroutines. An instance
+// without any goroutine closes its outChan right away.
+func (ctl *stageControl) start(inst *stageInstance) {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+
+	if ctl.fanSize == 0 {
+		go func() {
+			if inst.cfg.onDone != nil {
+				inst.cfg.onDone()
+			}
+			close(inst.outChan)
+		}()
+		return
+	}
+	if inst.cfg.pool != nil {
+		go inst.dispatch(inst.cfg.pool)
+		return
+	}
+	ctl.instances[inst] = struct{}{}
+	inst.resize(ctl.fanSize)
+}
+
+// state returns the fan size of the stage, whether it is paused and a channel
+// closed when either changes, for the stages running on a WorkerPool.
+func (ctl *stageControl) state() (fanSize uint64, paused bool, changed <-chan struct{}) {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	return ctl.fanSize, atomic.LoadInt32(&ctl.paused) == 1, ctl.changed
+}
+
+// notifyChanged must be called with the mutex held.
+func (ctl *stageControl) notifyChanged() {
+	close(ctl.changed)
+	ctl.changed = make(chan struct{})
+}
+
+// setFanSize changes the number of goroutines of the stage, in the running
+// instances as well as in the ones started later.
+func (ctl *stageControl) setFanSize(fanSize uint64) {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+
+	ctl.fanSize = fanSize
+	for inst := range ctl.instances {
+		inst.resize(fanSize)
+	}
+	ctl.notifyChanged()
+}
+
+// resize starts or stops goroutines. It must be called with the mutex of the
+// stageControl held.
+func (inst *stageInstance) resize(fanSize uint64) {
+	for uint64(len(inst.quits)) < fanSize && !inst.draining {
+		quit := make(chan struct{})
+		inst.quits = append(inst.quits, quit)
+		inst.running++
+		go inst.work(quit)
+	}
+	for uint64(len(inst.quits)) > fanSize {
+		last := len(inst.quits) - 1
+		close(inst.quits[last])
+		inst.quits = inst.quits[:last]
+	}
+}
+
+// workerDone is called by every goroutine of an instance when it returns. The
+// last one closes the outChan of the instance.
+func (ctl *stageControl) workerDone(inst *stageInstance, inputClosed bool) {
+	ctl.mu.Lock()
+	inst.running--
+	if inputClosed {
+		inst.draining = true
+	}
+	last := inst.running == 0
+	if last {
+		delete(ctl.instances, inst)
+	}
+	ctl.mu.Unlock()
+
+	if last {
+		inst.mu.Lock()
+		inst.closed = true
+		inst.mu.Unlock()
+		if inst.cfg.onDone != nil {
+			inst.cfg.onDone()
+		}
+		close(inst.outChan)
+	}
+}
+
+// running returns the instances of the stage in progress, except the ones on
+// a WorkerPool.
+func (ctl *stageControl) running() []*stageInstance {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	instances := make([]*stageInstance, 0, len(ctl.instances))
+	for inst := range ctl.instances {
+		instances = append(instances, inst)
+	}
+	return instances
+}
+
+func (ctl *stageControl) pause() {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	if atomic.LoadInt32(&ctl.paused) == 0 {
+		ctl.resume = make(chan struct{})
+		atomic.StoreInt32(&ctl.paused, 1)
+	}
+}
+
+func (ctl *stageControl) unpause() {
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	if atomic.LoadInt32(&ctl.paused) == 1 {
+		atomic.StoreInt32(&ctl.paused, 0)
+		close(ctl.resume)
+		ctl.notifyChanged()
+	}
+}
+
+// waitResumed blocks while the stage is paused. It returns false if quit or
+// done was closed in the meantime.
+func (ctl *stageControl) waitResumed(quit chan struct{}, done <-chan struct{}) bool {
+	if atomic.LoadInt32(&ctl.paused) == 0 {
+		return true
+	}
+
+	ctl.mu.Lock()
+	resume := ctl.resume
+	paused := atomic.LoadInt32(&ctl.paused) == 1
+	ctl.mu.Unlock()
+	if !paused {
+		return true
+	}
+
+	select {
+	case <-resume:
+		return true
+	case <-quit:
+		return false
+	case <-done:
+		return false
+	}
+}
+
+func (ctl *stageControl) isPaused() bool {
+	return atomic.LoadInt32(&ctl.paused) == 1
+}
+
+// tapped copies an object to the taps of the stage, without ever blocking.
+func (ctl *stageControl) tapped(obj interface{}) {
+	if atomic.LoadInt32(&ctl.taps) == 0 {
+		return
+	}
+
+	ctl.mu.Lock()
+	defer ctl.mu.Unlock()
+	for ch := range ctl.tapChans {
+		select {
+		case ch <- payload(obj):
+		default:
+		}
+	}
+}
+
+func (ctl *stageControl) addTap(buffer int) (ch chan interface{}, untap func()) {
+	ch = make(chan interface{}, buffer)
+
+	ctl.mu.Lock()
+	ctl.tapChans[ch] = struct{}{}
+	atomic.AddInt32(&ctl.taps, 1)
+	ctl.mu.Unlock()
+
+	var once sync.Once
+	untap = func() {
+		once.Do(func() {
+			ctl.mu.Lock()
+			defer ctl.mu.Unlock()
+			delete(ctl.tapChans, ch)
+			atomic.AddInt32(&ctl.taps, -1)
+			close(ch)
+		})
+	}
+	return
+}
+
+// SetFanOut changes the fan size of a stage, including in the runs that are
+// already in progress: goroutines are started or stopped accordingly. The fan
+// size of raw and weighted stages can't be changed and it can't be set below 1.
+func (p *Pipeline) SetFanOut(stageName string, fanSize uint64) error {
+	s, err := p.controlledStage(stageName)
+	if err != nil {
+		return err
+	}
+	if fanSize < 1 {
+		return fmt.Errorf("pipeline: invalid fan size %d", fanSize)
+	}
+	if s.weighted != nil {
+		return fmt.Errorf("pipeline: the fan size of weighted stage %s can't be changed", stageName)
+	}
+	s.control.setFanSize(fanSize)
+	return nil
+}
+
+// PauseStage stops the goroutines of a stage from taking new objects, in all
+// the runs of the pipeline, until ResumeStage is called. Objects back up in
+// the previous stages in the meantime.
+func (p *Pipeline) PauseStage(stageName string) error {
+	s, err := p.controlledStage(stageName)
+	if err != nil {
+		return err
+	}
+	s.control.pause()
+	return nil
+}
+
+// ResumeStage resumes a stage paused with PauseStage.
+func (p *Pipeline) ResumeStage(stageName string) error {
+	s, err := p.controlledStage(stageName)
+	if err != nil {
+		return err
+	}
+	s.control.unpause()
+	return nil
+}
+
+// Tap returns a channel receiving a copy of the objects coming out of a stage,
+// for sampling live traffic. Objects are dropped from the tap rather than
+// slowing the stage down when its buffer is full. The untap function must be
+// called to stop tapping; it closes the channel.
+func (p *Pipeline) Tap(stageName string, buffer int) (tap <-chan interface{}, untap func(), err error) {
+	s, err := p.controlledStage(stageName)
+	if err != nil {
+		return nil, nil, err
+	}
+	tap, untap = s.control.addTap(buffer)
+	return
+}
+
+// stageByName returns the stage with the given name.
+func (p *Pipeline) stageByName(name string) (*stage, error) {
+	for _, s := range p.stageList() {
+		if s.name == name {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("pipeline: no stage named %q", name)
+}
+
+// controlledStage returns the stage with the given name if it isn't raw.
+func (p *Pipeline) controlledStage(name string) (*stage, error) {
+	s, err := p.stageByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if s.raw != nil {
+		return nil, fmt.Errorf("pipeline: %s is a raw stage", name)
+	}
+	return s, nil
+}
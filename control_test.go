@@ -0,0 +1,28 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_Tap() {
+	p := pipeline.New()
+	p.AddStage(squareStage)
+
+	tap, untap, _ := p.Tap("stage0", 10)
+	ch := make(chan interface{}, 3)
+	ch <- 1
+	ch <- 2
+	ch <- 3
+	close(ch)
+	<-p.Run(ch)
+
+	untap()
+	for obj := range tap {
+		fmt.Println(obj)
+	}
+
+	// Output: 1
+	// 4
+	// 9
+}
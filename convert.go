@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// AsStageFn turns a pipeline into a StageFn, so that it can be used as a stage
+// of another pipeline or mixed with hand-written channel functions in the
+// style of https://blog.golang.org/pipelines. Every call of the StageFn runs
+// the pipeline: objects come out of the returned channel instead of being
+// drained, and the channel is closed once the run is done.
+//
+// Objects coming out of the pipeline are not acknowledged, since they are
+// still being processed downstream. Should the run be aborted, e.g. by its
+// RunLimits, the objects that can't be passed on anymore are nacked. When the
+// StageFn is a raw stage of another pipeline, the run is aborted along with
+// the run of the other pipeline.
+func AsStageFn(p *Pipeline) StageFn {
+	return func(inChan <-chan interface{}) (outChan chan interface{}) {
+		run, out := p.start(RawStageContext(inChan), inChan, nil)
+
+		outChan = make(chan interface{})
+		go func() {
+			defer close(outChan)
+			for obj := range out {
+				// the envelope may be recycled downstream once passed on
+				run.observe(obj)
+				select {
+				case outChan <- obj:
+					run.leave()
+				case <-run.done:
+					// nobody may be reading anymore
+					run.leave()
+					settle(obj, run.ctx.Err())
+				}
+			}
+			run.finish()
+		}()
+		return
+	}
+}
+
+// rawStageContexts are the contexts of the runs the raw stages are started
+// for, by input channel, while they are being started.
+var rawStageContexts sync.Map
+
+// withRawStageContext makes the raw stage read ctx with RawStageContext.
+func withRawStageContext(ctx context.Context, raw StageFn) StageFn {
+	return func(inChan <-chan interface{}) chan interface{} {
+		rawStageContexts.Store(inChan, ctx)
+		defer rawStageContexts.Delete(inChan)
+		return raw(inChan)
+	}
+}
+
+// RawStageContext returns the context of the run a raw stage reading inChan
+// is started for, or the background context outside of a pipeline. It is done
+// once the run is aborted, after which the stage must stop sending, as nobody
+// may be reading anymore. The stage function must call it before returning,
+// not from the goroutines it starts.
+func RawStageContext(inChan <-chan interface{}) context.Context {
+	if ctx, ok := rawStageContexts.Load(inChan); ok {
+		return ctx.(context.Context)
+	}
+	return context.Background()
+}
+
+// FromStageFns makes a pipeline out of channel functions, each becoming a raw
+// stage of the pipeline. It replaces the slice literals of the former
+// Pipeline type, a []StageFn: pipeline.Pipeline{a, b} becomes
+// pipeline.FromStageFns([]pipeline.StageFn{a, b}).
+func FromStageFns(fns []StageFn) Pipeline {
+	p := New()
+	for _, fn := range fns {
+		p.AddRawStage(fn)
+	}
+	return p
+}
@@ -0,0 +1,95 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+// double is a hand-written channel function.
+func double(inChan <-chan interface{}) chan interface{} {
+	outChan := make(chan interface{})
+	go func() {
+		defer close(outChan)
+		for obj := range inChan {
+			outChan <- obj.(int) * 2
+		}
+	}()
+	return outChan
+}
+
+func ExampleAsStageFn() {
+	squares := pipeline.New()
+	squares.AddStage(squareStage)
+
+	// a pipeline of a channel function and of another pipeline
+	p := pipeline.FromStageFns([]pipeline.StageFn{double, pipeline.AsStageFn(&squares)})
+	p.AddStage(printStage)
+
+	in := make(chan interface{}, 2)
+	in <- 1
+	in <- 2
+	close(in)
+	<-p.Run(in)
+
+	// Output: 4
+	// 16
+}
+
+func ExampleAsStageFn_envelopes() {
+	inner := pipeline.New()
+	inner.EnableEnvelopes()
+	inner.SetLoadShedding(pipeline.LoadShedding{MaxLatency: time.Hour})
+	inner.AddStage(squareStage)
+
+	// the envelopes of the inner pipeline are settled by the outer one
+	p := pipeline.New()
+	p.EnableEnvelopes()
+	p.AddRawStage(pipeline.AsStageFn(&inner))
+	p.AddStage(printStage)
+
+	in := make(chan interface{}, 3)
+	for i := 1; i <= 3; i++ {
+		in <- i
+	}
+	close(in)
+	<-p.Run(in)
+
+	// Output: 1
+	// 4
+	// 9
+}
+
+func ExampleAsStageFn_abort() {
+	started, aborted := make(chan struct{}), make(chan error, 1)
+	inner := pipeline.New()
+	inner.AddStageCtx(func(ctx context.Context, inObj interface{}) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		aborted <- ctx.Err()
+		return nil, ctx.Err()
+	}, 1)
+
+	p := pipeline.New()
+	p.AddRawStage(pipeline.AsStageFn(&inner))
+	p.AddStage(printStage)
+
+	// aborting the run aborts the run of the inner pipeline too
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan interface{}, 1)
+	in <- 1
+	done := p.RunContext(ctx, in)
+	<-started
+	cancel()
+	select {
+	case err := <-aborted:
+		fmt.Println(err)
+	case <-time.After(time.Second):
+		fmt.Println("the inner run is still going")
+	}
+	close(in)
+	<-done
+
+	// Output: context canceled
+}
@@ -0,0 +1,234 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule tells when a periodic job runs next.
+type Schedule interface {
+	// Next returns the first time after t the job runs at.
+	Next(t time.Time) time.Time
+}
+
+// Every is a Schedule running every interval.
+type Every time.Duration
+
+// Next implements Schedule.
+func (e Every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+// cronSchedule is a Schedule parsed by ParseCron, with one bit per allowed
+// value of each field.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	anyDom, anyDow                bool
+}
+
+// cronFields are the bounds of the fields of a cron expression, in order.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCron parses a cron expression: the standard five fields (minute, hour,
+// day of month, month and day of week, 0 or 7 being Sunday) supporting *,
+// lists, ranges and steps such as "*/15 9-17 * * 1-5", one of the @hourly,
+// @daily, @weekly, @monthly and @yearly descriptors, or "@every 5m". As in
+// cron, a time matches either day field when both are restricted. Times are
+// in the location of the time given to Next.
+func ParseCron(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("pipeline: invalid cron interval %q", spec)
+		}
+		return Every(d), nil
+	}
+	if expr, ok := cronDescriptors[spec]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("pipeline: cron expression %q must have %d fields", spec, len(cronFields))
+	}
+	var bits [5]uint64
+	for i, field := range fields {
+		b, err := parseCronField(field, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline: cron %s: %v", cronFields[i].name, err)
+		}
+		bits[i] = b
+	}
+	s := &cronSchedule{
+		minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4],
+		anyDom: fields[2] == "*", anyDow: fields[4] == "*",
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // Sunday
+	}
+	return s, nil
+}
+
+func parseCronField(field string, min, max int) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			rng = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value in %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value in %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return
+}
+
+// Next implements Schedule.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// the days matching an expression repeat at least every few years
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.anyDom || s.anyDow {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Tick is emitted by a TickerSource.
+type Tick struct {
+	Time time.Time // when the tick was scheduled
+	Seq  uint64    // starting at 0
+}
+
+// TickerSource is a Source emitting a Tick at the times of a Schedule, to
+// model periodic jobs, such as polling an API or scanning a table, as
+// pipelines:
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+//	defer stop()
+//	src, _ := pipeline.NewCronSource("*/5 * * * *")
+//	in, _ := src.Open(ctx)
+//	<-p.Run(in) // returns once ctx is done and the last tick is processed
+//
+// The channel is closed once ctx is done, so that the pipeline drains and
+// the run completes normally. Ticks that come while the previous one hasn't
+// been taken by the pipeline yet are skipped, as with time.Ticker.
+type TickerSource struct {
+	Schedule Schedule
+
+	// Clock, if set, schedules the ticks instead of the system clock.
+	Clock Clock
+}
+
+// NewTickerSource creates a TickerSource ticking every interval.
+func NewTickerSource(interval time.Duration) *TickerSource {
+	return &TickerSource{Schedule: Every(interval)}
+}
+
+// NewCronSource creates a TickerSource ticking on a cron schedule, see
+// ParseCron.
+func NewCronSource(spec string) (*TickerSource, error) {
+	schedule, err := ParseCron(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &TickerSource{Schedule: schedule}, nil
+}
+
+// Open implements Source.
+func (s *TickerSource) Open(ctx context.Context) (<-chan interface{}, error) {
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+		clock := clockOrSystem(s.Clock)
+		var seq uint64
+		next := s.Schedule.Next(clock.Now())
+		for !next.IsZero() {
+			timer := clock.NewTimer(next.Sub(clock.Now()))
+			select {
+			case <-timer.C():
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+
+			select {
+			case ch <- Tick{Time: next, Seq: seq}:
+				seq++
+			case <-ctx.Done():
+				return
+			}
+			// skip the ticks missed while sending
+			now := clock.Now()
+			for next = s.Schedule.Next(next); !next.IsZero() && next.Before(now); {
+				next = s.Schedule.Next(next)
+			}
+		}
+	}()
+	return ch, nil
+}
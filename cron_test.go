@@ -0,0 +1,46 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExampleParseCron() {
+	schedule, _ := pipeline.ParseCron("*/15 9-17 * * 1-5")
+	t := time.Date(2024, 3, 8, 17, 50, 0, 0, time.UTC) // a Friday
+	for i := 0; i < 3; i++ {
+		t = schedule.Next(t)
+		fmt.Println(t.Format("Mon Jan 2 15:04"))
+	}
+
+	// Output: Mon Mar 11 09:00
+	// Mon Mar 11 09:15
+	// Mon Mar 11 09:30
+}
+
+func ExampleTickerSource() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := pipeline.New()
+	p.AddStage(func(inObj interface{}) interface{} {
+		tick := inObj.(pipeline.Tick)
+		if tick.Seq >= 3 {
+			return nil
+		}
+		if tick.Seq == 2 {
+			cancel()
+		}
+		return fmt.Sprint("poll ", tick.Seq)
+	})
+	p.AddStage(printStage)
+
+	in, _ := pipeline.NewTickerSource(10 * time.Millisecond).Open(ctx)
+	<-p.Run(in)
+
+	// Output: poll 0
+	// poll 1
+	// poll 2
+}
@@ -0,0 +1,152 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+// CSVSink is a Sink writing objects as CSV rows, to a single writer or to a
+// series of files rotated by size or age, see Rotation. Every file starts
+// with the header row, if any. It is safe for concurrent use.
+//
+// Rows are buffered: CSVSink is Flushable, so Pipeline.Flush writes them out,
+// and Close flushes them and closes the current file.
+type CSVSink struct {
+	header []string
+	fields func(obj interface{}) ([]string, error)
+
+	Rotation
+
+	mu      sync.Mutex
+	files   rotatingFiles
+	row     bytes.Buffer
+	encoder *csv.Writer // into row
+	columns map[reflect.Type][]int
+}
+
+// NewCSVSink creates a CSVSink writing rows to w, without rotation.
+//
+// fields maps an object to the values of its row. If it is nil, objects must
+// be structs, or pointers to structs, whose exported fields make up the row,
+// formatted with fmt.Sprint. A field's column is named by its `csv` tag if it
+// has one, "-" leaving the field out, and by the field name otherwise. If
+// header is nil as well, the header is made of the column names of the first
+// object. With a fields function, a nil header writes no header row.
+func NewCSVSink(w io.Writer, header []string, fields func(obj interface{}) ([]string, error)) *CSVSink {
+	return newCSVSink(rotatingFiles{w: w}, header, fields)
+}
+
+// NewCSVFileSink creates a CSVSink writing to files named after pattern, a
+// fmt format given the sequence number of the file, starting at 0: e.g.
+// "out/orders-%04d.csv". Files are created as the sink rotates, skipping the
+// sequence numbers of the existing ones rather than overwriting them. See
+// NewCSVSink for header and fields.
+func NewCSVFileSink(pattern string, header []string, fields func(obj interface{}) ([]string, error)) *CSVSink {
+	return newCSVSink(rotatingFiles{pattern: pattern}, header, fields)
+}
+
+func newCSVSink(files rotatingFiles, header []string, fields func(obj interface{}) ([]string, error)) *CSVSink {
+	s := &CSVSink{header: header, fields: fields, files: files, columns: map[reflect.Type][]int{}}
+	s.encoder = csv.NewWriter(&s.row)
+	s.files.header = func() ([]byte, error) {
+		if s.header == nil {
+			return nil, nil
+		}
+		return s.encode(s.header)
+	}
+	return s
+}
+
+// Write implements Sink.
+func (s *CSVSink) Write(obj interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var values []string
+	var err error
+	if s.fields != nil {
+		values, err = s.fields(obj)
+	} else {
+		values, err = s.structFields(obj)
+	}
+	if err != nil {
+		return err
+	}
+	row, err := s.encode(values)
+	if err != nil {
+		return err
+	}
+	return s.files.write(row, s.Rotation)
+}
+
+// encode returns the CSV encoding of a row.
+func (s *CSVSink) encode(values []string) ([]byte, error) {
+	s.row.Reset()
+	s.encoder.Write(values)
+	s.encoder.Flush()
+	if err := s.encoder.Error(); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), s.row.Bytes()...), nil
+}
+
+// structFields returns the values of the exported fields of a struct, and
+// sets the header from them if there is none yet.
+func (s *CSVSink) structFields(obj interface{}) ([]string, error) {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("pipeline: can't write %T as a CSV row", obj)
+	}
+
+	t := v.Type()
+	columns, ok := s.columns[t]
+	if !ok {
+		var names []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := f.Tag.Get("csv")
+			if f.PkgPath != "" || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			columns = append(columns, i)
+			names = append(names, name)
+		}
+		s.columns[t] = columns
+		if s.header == nil {
+			s.header = names
+		}
+	}
+
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		values[i] = fmt.Sprint(v.Field(c).Interface())
+	}
+	return values, nil
+}
+
+// Flush implements Flushable, writing out the buffered rows.
+func (s *CSVSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.files.flush(s.Rotation)
+}
+
+// Close flushes the buffered rows and closes the current file. The writer
+// given to NewCSVSink is not closed, and writing to it after Close repeats
+// the header. A file sink writing after Close starts a new file.
+func (s *CSVSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.files.close(s.Rotation)
+}
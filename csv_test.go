@@ -0,0 +1,58 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+type order struct {
+	ID       int     `csv:"id"`
+	Customer string  `csv:"customer"`
+	Total    float64 `csv:"total"`
+	note     string
+}
+
+func ExampleNewCSVSink() {
+	sink := pipeline.NewCSVSink(os.Stdout, nil, nil)
+	sink.Write(order{ID: 1, Customer: "Ada", Total: 12.5})
+	sink.Write(&order{ID: 2, Customer: "Grace, Hopper", Total: 7})
+	sink.Close()
+
+	// Output: id,customer,total
+	// 1,Ada,12.5
+	// 2,"Grace, Hopper",7
+}
+
+func ExampleNewCSVFileSink() {
+	dir, _ := ioutil.TempDir("", "csv")
+	defer os.RemoveAll(dir)
+
+	sink := pipeline.NewCSVFileSink(filepath.Join(dir, "squares-%02d.csv"), []string{"n", "square"},
+		func(obj interface{}) ([]string, error) {
+			n := obj.(int)
+			return []string{fmt.Sprint(n), fmt.Sprint(n * n)}, nil
+		})
+	sink.MaxSize = 20 // bytes
+	for n := 1; n <= 4; n++ {
+		sink.Write(n)
+	}
+	sink.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.csv"))
+	for _, file := range files {
+		data, _ := ioutil.ReadFile(file)
+		fmt.Printf("%s:\n%s", filepath.Base(file), data)
+	}
+
+	// Output: squares-00.csv:
+	// n,square
+	// 1,1
+	// 2,4
+	// squares-01.csv:
+	// n,square
+	// 3,9
+	// 4,16
+}
@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DedupeStore remembers the keys of the objects seen by a dedupe stage. It
+// may be backed by a shared service, such as Redis, to deduplicate across
+// processes. Implementations must be safe for concurrent use.
+type DedupeStore interface {
+	// Seen reports whether key was seen before, and records it as seen.
+	Seen(key string) (bool, error)
+}
+
+// AddDedupeStage adds a stage dropping the objects whose key, as returned by
+// the key function, was already seen according to store. Store errors fail
+// the objects, which are then sent to the dead-letter function. See
+// AddStageWithFanOut for the meaning of fanSize and opts.
+func (p *Pipeline) AddDedupeStage(key func(obj interface{}) string, store DedupeStore, fanSize uint64, opts ...StageOption) {
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		seen, err := store.Seen(key(inObj))
+		if seen || err != nil {
+			return nil, err
+		}
+		return inObj, nil
+	}, fanSize, opts...)
+}
+
+// LRUDedupeStore is an in-memory DedupeStore remembering the most recently
+// seen keys.
+type LRUDedupeStore struct {
+	capacity int
+	window   time.Duration
+
+	mu    sync.Mutex
+	order *list.List // of *lruEntry, most recently seen first
+	keys  map[string]*list.Element
+}
+
+type lruEntry struct {
+	key  string
+	seen time.Time
+}
+
+// NewLRUDedupeStore creates a store remembering up to capacity keys, for at
+// most window. Keys beyond the capacity are forgotten, least recently seen
+// first. A capacity or a window of zero is unbounded.
+func NewLRUDedupeStore(capacity int, window time.Duration) *LRUDedupeStore {
+	return &LRUDedupeStore{
+		capacity: capacity,
+		window:   window,
+		order:    list.New(),
+		keys:     map[string]*list.Element{},
+	}
+}
+
+// Seen implements DedupeStore. It never fails.
+func (s *LRUDedupeStore) Seen(key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if s.window > 0 {
+		// forget the keys that fell out of the window, oldest first
+		for e := s.order.Back(); e != nil && now.Sub(e.Value.(*lruEntry).seen) >= s.window; e = s.order.Back() {
+			s.remove(e)
+		}
+	}
+
+	if e, ok := s.keys[key]; ok {
+		e.Value.(*lruEntry).seen = now
+		s.order.MoveToFront(e)
+		return true, nil
+	}
+
+	s.keys[key] = s.order.PushFront(&lruEntry{key: key, seen: now})
+	if s.capacity > 0 && s.order.Len() > s.capacity {
+		s.remove(s.order.Back())
+	}
+	return false, nil
+}
+
+// Len returns the number of keys remembered.
+func (s *LRUDedupeStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *LRUDedupeStore) remove(e *list.Element) {
+	s.order.Remove(e)
+	delete(s.keys, e.Value.(*lruEntry).key)
+}
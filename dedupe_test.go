@@ -0,0 +1,33 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"strings"
+)
+
+func ExamplePipeline_AddDedupeStage() {
+	// events are identified by the part before the colon
+	eventID := func(obj interface{}) string {
+		return strings.SplitN(obj.(string), ":", 2)[0]
+	}
+
+	p := pipeline.New()
+	store := pipeline.NewLRUDedupeStore(1000, 0)
+	p.AddDedupeStage(eventID, store, 1)
+	p.AddStage(printStage)
+
+	in := make(chan interface{}, 4)
+	in <- "1:signup"
+	in <- "2:login"
+	in <- "1:signup (redelivered)"
+	in <- "3:logout"
+	close(in)
+	<-p.Run(in)
+	fmt.Println(store.Len(), "keys")
+
+	// Output: 1:signup
+	// 2:login
+	// 3:logout
+	// 3 keys
+}
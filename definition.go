@@ -0,0 +1,300 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Definition is the serializable description of the topology of a pipeline:
+// its stages and the options they were configured with. It is what
+// Pipeline.MarshalJSON produces.
+type Definition struct {
+	Stages  []StageDefinition
+	Options map[string]string `json:",omitempty"`
+}
+
+// StageDefinition describes a single stage of a Definition.
+type StageDefinition struct {
+	Name    string
+	Func    string `json:",omitempty"` // registered function, see Build
+	Kind    string // "process", "envelope" or "raw"
+	FanSize uint64
+	Options map[string]string `json:",omitempty"`
+}
+
+// Definition returns the description of the pipeline topology.
+func (p *Pipeline) Definition() Definition {
+	def := Definition{Options: map[string]string{}}
+	setFlag(def.Options, "envelopes", p.envelopes)
+	setFlag(def.Options, "synchronous", p.synchronous)
+	setFlag(def.Options, "tracing", p.tracer != nil)
+	setFlag(def.Options, "logging", p.logger != nil)
+	setFlag(def.Options, "dead_letter", p.deadLetter != nil)
+	setFlag(def.Options, "recording", p.recorder != nil)
+	setFlag(def.Options, "profiling", p.profiling)
+	setFlag(def.Options, "worker_pool", p.pool != nil)
+	setFlag(def.Options, "strict_accounting", p.accounting != nil)
+	if len(p.middleware) > 0 {
+		def.Options["middleware"] = strconv.Itoa(len(p.middleware))
+	}
+	if p.maxInFlight > 0 {
+		def.Options["max_in_flight"] = strconv.Itoa(p.maxInFlight)
+	}
+	if p.priorities > 0 {
+		def.Options["priorities"] = strconv.Itoa(p.priorities)
+	}
+	if p.errorPolicy != ContinueOnError {
+		def.Options["error_policy"] = p.errorPolicy.String()
+	}
+	if ls := p.shedding; ls != nil {
+		def.Options["load_shedding"] = fmt.Sprintf("max_in_flight=%d max_latency=%s", ls.MaxInFlight, ls.MaxLatency)
+	}
+	if len(p.sideOutputs) > 0 {
+		var names []string
+		for name := range p.sideOutputs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		def.Options["side_outputs"] = strings.Join(names, ",")
+	}
+
+	for _, s := range p.stageList() {
+		sd := StageDefinition{Name: s.name, Func: s.funcName, Kind: "process", Options: map[string]string{}}
+		switch {
+		case s.raw != nil:
+			sd.Kind = "raw"
+		case s.envelope:
+			sd.Kind = "envelope"
+		}
+		if s.raw == nil {
+			sd.FanSize = s.control.getFanSize()
+		}
+		if len(s.middleware) > 0 {
+			sd.Options["middleware"] = strconv.Itoa(len(s.middleware))
+		}
+		if r := s.retry; r != nil {
+			sd.Options["retry"] = fmt.Sprintf("attempts=%d initial=%s max=%s multiplier=%g jitter=%g",
+				r.maxAttempts, r.backoff.Initial, r.backoff.Max, r.backoff.Multiplier, r.backoff.Jitter)
+		}
+		if b := s.breaker; b != nil {
+			sd.Options["circuit_breaker"] = fmt.Sprintf("threshold=%d cooldown=%s fallback=%t",
+				b.threshold, b.cooldown, b.fallback != nil)
+		}
+		if s.limiter != nil {
+			sd.Options["limiter"] = fmt.Sprintf("%s (%d)", s.limiter.name, s.limiter.Limit())
+		}
+		if s.scaler != nil {
+			sd.Options["io_scaling"] = s.scaler.String()
+		}
+		if a := s.adaptive; a != nil {
+			sd.Options["adaptive_concurrency"] = fmt.Sprintf("target=%s backoff=%g initial=%d min=%d max=%d",
+				a.TargetLatency, a.Backoff, a.InitialLimit, a.MinLimit, a.MaxLimit)
+		}
+		if s.distribution != Shared {
+			sd.Options["distribution"] = s.distribution.String()
+		}
+		if s.weighted != nil {
+			var weights []string
+			for _, w := range s.weights() {
+				weights = append(weights, strconv.Itoa(w))
+			}
+			sd.Options["weights"] = strings.Join(weights, ",")
+		}
+		if len(sd.Options) == 0 {
+			sd.Options = nil
+		}
+		def.Stages = append(def.Stages, sd)
+	}
+	if len(def.Options) == 0 {
+		def.Options = nil
+	}
+	return def
+}
+
+// MarshalJSON serializes the Definition of the pipeline.
+func (p *Pipeline) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Definition())
+}
+
+// Validate checks that the definition describes a pipeline that can be built:
+// it has stages, their names are unique and their kinds and fan sizes are
+// valid. The error lists all the problems found.
+func (d Definition) Validate() error {
+	var problems []string
+	if len(d.Stages) == 0 {
+		problems = append(problems, "no stages")
+	}
+	names := map[string]bool{}
+	for i, s := range d.Stages {
+		switch {
+		case s.Name == "":
+			problems = append(problems, fmt.Sprintf("stage %d has no name", i))
+		case names[s.Name]:
+			problems = append(problems, fmt.Sprintf("duplicate stage name %q", s.Name))
+		}
+		names[s.Name] = true
+
+		switch s.Kind {
+		case "process", "envelope":
+			if s.FanSize < 1 {
+				problems = append(problems, fmt.Sprintf("stage %q has fan size 0", s.Name))
+			}
+		case "raw":
+			if s.FanSize != 0 {
+				problems = append(problems, fmt.Sprintf("raw stage %q has a fan size", s.Name))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("stage %q has unknown kind %q", s.Name, s.Kind))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("pipeline: invalid definition: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// String describes the topology of the pipeline, one stage per line.
+func (d Definition) String() string {
+	var buf bytes.Buffer
+	for i, s := range d.Stages {
+		fmt.Fprintf(&buf, "%d. %s %s\n", i+1, s.Name, describeStage(s))
+		for _, name := range sortedKeys(s.Options) {
+			fmt.Fprintf(&buf, "     %s: %s\n", name, s.Options[name])
+		}
+	}
+	for _, name := range sortedKeys(d.Options) {
+		fmt.Fprintf(&buf, "%s: %s\n", name, d.Options[name])
+	}
+	return buf.String()
+}
+
+func sortedKeys(m map[string]string) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return
+}
+
+func setFlag(options map[string]string, name string, on bool) {
+	if on {
+		options[name] = "true"
+	}
+}
+
+// Change is a difference between two pipeline definitions.
+type Change struct {
+	Type  string // "added", "removed" or "changed"
+	Stage string // empty for pipeline options
+	Field string // what changed, for "changed" only
+	Old   string
+	New   string
+}
+
+func (c Change) String() string {
+	target := "pipeline"
+	if c.Stage != "" {
+		target = "stage " + c.Stage
+	}
+	switch c.Type {
+	case "added":
+		return fmt.Sprintf("+ %s %s", target, c.New)
+	case "removed":
+		return fmt.Sprintf("- %s %s", target, c.Old)
+	}
+	return fmt.Sprintf("~ %s %s: %q -> %q", target, c.Field, c.Old, c.New)
+}
+
+// DiffDefinitions reports the stages added, removed or changed between two
+// pipeline definitions, stages being matched by name, as well as the changes
+// in options and in the order of the stages.
+func DiffDefinitions(old, new Definition) (changes []Change) {
+	changes = diffOptions("", old.Options, new.Options)
+
+	oldStages := map[string]StageDefinition{}
+	for _, s := range old.Stages {
+		oldStages[s.Name] = s
+	}
+	newStages := map[string]StageDefinition{}
+	for _, s := range new.Stages {
+		newStages[s.Name] = s
+	}
+
+	for _, s := range old.Stages {
+		if _, ok := newStages[s.Name]; !ok {
+			changes = append(changes, Change{Type: "removed", Stage: s.Name, Old: describeStage(s)})
+		}
+	}
+	for i, s := range new.Stages {
+		o, ok := oldStages[s.Name]
+		if !ok {
+			changes = append(changes, Change{Type: "added", Stage: s.Name, New: describeStage(s)})
+			continue
+		}
+		if oi := stageIndex(old.Stages, s.Name); oi != i {
+			changes = append(changes, Change{Type: "changed", Stage: s.Name, Field: "position",
+				Old: strconv.Itoa(oi), New: strconv.Itoa(i)})
+		}
+		if o.Kind != s.Kind {
+			changes = append(changes, Change{Type: "changed", Stage: s.Name, Field: "kind", Old: o.Kind, New: s.Kind})
+		}
+		if o.FanSize != s.FanSize {
+			changes = append(changes, Change{Type: "changed", Stage: s.Name, Field: "fan_size",
+				Old: strconv.FormatUint(o.FanSize, 10), New: strconv.FormatUint(s.FanSize, 10)})
+		}
+		changes = append(changes, diffOptions(s.Name, o.Options, s.Options)...)
+	}
+	return
+}
+
+// DiffJSON is DiffDefinitions for definitions serialized with MarshalJSON.
+func DiffJSON(old, new []byte) ([]Change, error) {
+	var o, n Definition
+	if err := json.Unmarshal(old, &o); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(new, &n); err != nil {
+		return nil, err
+	}
+	return DiffDefinitions(o, n), nil
+}
+
+func diffOptions(stage string, old, new map[string]string) (changes []Change) {
+	var names []string
+	for name := range old {
+		names = append(names, name)
+	}
+	for name := range new {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if old[name] != new[name] {
+			changes = append(changes, Change{Type: "changed", Stage: stage, Field: name, Old: old[name], New: new[name]})
+		}
+	}
+	return
+}
+
+func describeStage(s StageDefinition) string {
+	if s.Kind == "raw" {
+		return "(raw)"
+	}
+	return fmt.Sprintf("(%s, fan size %d)", s.Kind, s.FanSize)
+}
+
+func stageIndex(stages []StageDefinition, name string) int {
+	for i, s := range stages {
+		if s.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,52 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExampleDiffJSON() {
+	deployed := pipeline.New()
+	deployed.AddStage(squareStage, pipeline.WithName("square"))
+	deployed.AddStage(printStage, pipeline.WithName("print"))
+	before, _ := deployed.MarshalJSON()
+
+	candidate := pipeline.New()
+	candidate.AddStage(printStage, pipeline.WithName("validate"))
+	candidate.AddStageWithFanOut(squareStage, 8, pipeline.WithName("square"),
+		pipeline.WithRetry(3, pipeline.Backoff{Initial: time.Second}))
+	candidate.EnableTracing(printTracer{})
+	after, _ := candidate.MarshalJSON()
+
+	changes, _ := pipeline.DiffJSON(before, after)
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+
+	// Output: ~ pipeline tracing: "" -> "true"
+	// - stage print (process, fan size 1)
+	// + stage validate (process, fan size 1)
+	// ~ stage square position: "0" -> "1"
+	// ~ stage square fan_size: "1" -> "8"
+	// ~ stage square retry: "" -> "attempts=3 initial=1s max=0s multiplier=0 jitter=0"
+}
+
+func ExampleDefinition_Validate() {
+	p := pipeline.New()
+	p.AddStageWithFanOut(squareStage, 4, pipeline.WithName("square"))
+	p.AddStage(printStage, pipeline.WithName("print"))
+	p.SetMaxInFlight(100)
+	def := p.Definition()
+	fmt.Print(def)
+	fmt.Println(def.Validate())
+
+	def.Stages[1].Name = "square"
+	fmt.Println(def.Validate())
+
+	// Output: 1. square (process, fan size 4)
+	// 2. print (process, fan size 1)
+	// max_in_flight: 100
+	// <nil>
+	// pipeline: invalid definition: duplicate stage name "square"
+}
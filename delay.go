@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"time"
+)
+
+// AddDelayStage adds a stage holding every object back for delay before
+// passing it on, e.g. to give late corrections a grace period or to lag behind
+// real time on purpose. The delay runs from the ingestion time of objects
+// carried by envelopes, and from their arrival in the stage otherwise.
+//
+// Up to buffer objects are held at once; the stage stops reading from the
+// previous one while it is full. Objects keep their order. The stage is a
+// raw stage, so only the WithName option applies to it. It runs on the Clock
+// of the pipeline.
+func (p *Pipeline) AddDelayStage(delay time.Duration, buffer int, opts ...StageOption) {
+	st := &stage{control: newStageControl(0)}
+	st.clocked = func(clock Clock, done <-chan struct{}) StageFn {
+		return func(inChan <-chan interface{}) (outChan chan interface{}) {
+			outChan = make(chan interface{})
+			go delayObjects(inChan, outChan, delay, buffer, clock, done)
+			return
+		}
+	}
+	st.raw = st.clocked(SystemClock(), nil)
+	p.addStage(st, opts...)
+}
+
+type heldObject struct {
+	obj interface{}
+	due time.Time
+}
+
+func delayObjects(inChan <-chan interface{}, outChan chan interface{}, delay time.Duration, buffer int, clock Clock, done <-chan struct{}) {
+	defer close(outChan)
+	if buffer < 1 {
+		buffer = 1
+	}
+	timer := clock.NewTimer(delay)
+	timer.Stop() // armed only while objects are held
+	defer timer.Stop()
+
+	var held []heldObject
+	for inChan != nil || len(held) > 0 {
+		var in <-chan interface{}
+		if inChan != nil && len(held) < buffer {
+			in = inChan
+		}
+		var out chan interface{}
+		var next interface{}
+		var wait <-chan time.Time
+		if len(held) > 0 {
+			if d := held[0].due.Sub(clock.Now()); d > 0 {
+				resetTimer(timer, d)
+				wait = timer.C()
+			} else {
+				out, next = outChan, held[0].obj
+			}
+		}
+
+		select {
+		case obj, ok := <-in:
+			if !ok {
+				inChan = nil
+				continue
+			}
+			due := clock.Now()
+			if env, ok := obj.(*Envelope); ok && !env.Ingested.IsZero() {
+				due = env.Ingested
+			}
+			held = append(held, heldObject{obj: obj, due: due.Add(delay)})
+		case out <- next:
+			held[0] = heldObject{}
+			held = held[1:]
+		case <-wait:
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExamplePipeline_AddDelayStage() {
+	start := time.Now()
+	p := pipeline.New()
+	p.AddDelayStage(50*time.Millisecond, 100)
+	p.AddStage(func(inObj interface{}) interface{} {
+		fmt.Println(inObj, time.Since(start) >= 50*time.Millisecond)
+		return inObj
+	})
+
+	in := make(chan interface{}, 2)
+	in <- "first"
+	in <- "second"
+	close(in)
+	<-p.Run(in)
+
+	// Output: first true
+	// second true
+}
@@ -0,0 +1,358 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+// Distribution is how objects are spread over the goroutines of a stage, see
+// WithDistribution, or over channels, see Distribute.
+type Distribution int
+
+const (
+	// Shared hands every object to the first goroutine or channel ready to
+	// take it. It is the default: all the goroutines of a stage read from
+	// the same channel.
+	Shared Distribution = iota
+	// RoundRobin hands the objects to the goroutines or channels in turn,
+	// waiting for the one whose turn it is.
+	RoundRobin
+	// LeastLoaded hands every object to the goroutine or channel with the
+	// fewest objects waiting, ties going in turn.
+	LeastLoaded
+	// WorkStealing queues objects for the goroutines like LeastLoaded, and
+	// goroutines running out of objects steal them from the others, see
+	// WithDistribution. Distribute treats it as LeastLoaded.
+	WorkStealing
+)
+
+var distributionNames = []string{"shared", "round_robin", "least_loaded", "work_stealing"}
+
+func (d Distribution) String() string {
+	if d < 0 || int(d) >= len(distributionNames) {
+		return fmt.Sprintf("Distribution(%d)", int(d))
+	}
+	return distributionNames[d]
+}
+
+// ParseDistribution returns the Distribution named s, as returned by String.
+func ParseDistribution(s string) (Distribution, error) {
+	for i, name := range distributionNames {
+		if name == s {
+			return Distribution(i), nil
+		}
+	}
+	return Shared, fmt.Errorf("pipeline: unknown distribution %q", s)
+}
+
+// WithDistribution is a StageOption choosing how the objects of a stage are
+// spread over its goroutines. Other than with Shared, a dispatcher goroutine
+// reads the objects of the stage and hands them to the goroutines: with
+// RoundRobin and LeastLoaded, every goroutine gets its own channel holding one
+// object besides the one it processes; with WorkStealing, every goroutine gets
+// a queue of up to 32 objects, read without contention with the other
+// goroutines unless they run out of objects, which can help stages processing
+// very high object rates. It doesn't apply to the stages running on a
+// WorkerPool.
+func WithDistribution(d Distribution) StageOption {
+	return func(s *stage) {
+		s.distribution = d
+	}
+}
+
+// Distribute spreads the objects of inChan over n channels buffering up to
+// bufferSize objects each, and closes them once inChan is closed. LeastLoaded
+// picks the channel with the fewest objects buffered, so it needs a buffer to
+// be any different from RoundRobin.
+func Distribute(inChan <-chan interface{}, n, bufferSize int, d Distribution) (outChans []chan interface{}) {
+	outChans = make([]chan interface{}, n)
+	for i := range outChans {
+		outChans[i] = make(chan interface{}, bufferSize)
+	}
+	go func() {
+		defer func() {
+			for _, ch := range outChans {
+				close(ch)
+			}
+		}()
+		var next int
+		for obj := range inChan {
+			switch d {
+			case RoundRobin:
+				outChans[next] <- obj
+				next = (next + 1) % n
+			case LeastLoaded, WorkStealing:
+				best := next
+				for i := 1; i < n; i++ {
+					if j := (next + i) % n; len(outChans[j]) < len(outChans[best]) {
+						best = j
+					}
+				}
+				outChans[best] <- obj
+				next = (best + 1) % n
+			default:
+				sendFirst(outChans, obj)
+			}
+		}
+	}()
+	return
+}
+
+// sendFirst sends obj to the first of chans ready to receive it.
+func sendFirst(chans []chan interface{}, obj interface{}) {
+	cases := make([]reflect.SelectCase, len(chans))
+	for i, ch := range chans {
+		cases[i] = reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(ch), Send: reflect.ValueOf(&obj).Elem()}
+	}
+	reflect.Select(cases)
+}
+
+// laneSize is the number of objects a lane holds besides the one its
+// goroutine processes.
+const laneSize = 1
+
+// lane feeds a single goroutine of a stage whose objects are dispatched
+// rather than shared, through a channel or, with WorkStealing, a queue.
+type lane struct {
+	ch    chan interface{} // nil with WorkStealing
+	quit  chan struct{}    // closed to scale the stage down
+	busy  int32            // 1 while the goroutine holds an object
+	index int              // the smallest one not taken by another lane
+
+	current int // smooth weighted round-robin state, for weighted stages
+
+	// with WorkStealing
+	mu      sync.Mutex
+	queue   []interface{}
+	retired chan struct{} // closed instead of ch
+}
+
+func (ln *lane) load() int {
+	if ln.ch == nil {
+		ln.mu.Lock()
+		defer ln.mu.Unlock()
+		return len(ln.queue) + int(atomic.LoadInt32(&ln.busy))
+	}
+	return len(ln.ch) + int(atomic.LoadInt32(&ln.busy))
+}
+
+// receive returns the next object of the goroutine of the lane, or false once
+// the lane is closed and drained or the run is aborted.
+func (ln *lane) receive(d *dispatcher, done <-chan struct{}) (interface{}, bool) {
+	if ln.ch == nil {
+		return d.take(ln, done)
+	}
+	select {
+	case obj, ok := <-ln.ch:
+		if ok {
+			atomic.StoreInt32(&ln.busy, 1)
+		}
+		return obj, ok
+	case <-done:
+		return nil, false
+	}
+}
+
+// closeLane closes a lane so that its goroutine stops once it has drained it.
+func closeLane(ln *lane) {
+	if ln.ch == nil {
+		close(ln.retired)
+	} else {
+		close(ln.ch)
+	}
+}
+
+// dispatcher feeds the lanes of a stage instance. It is the only one closing
+// them: when the goroutine of a lane is told to quit, it keeps processing the
+// objects of its lane until the dispatcher closes it, so that none is lost.
+type dispatcher struct {
+	distribution Distribution
+	weights      []int         // by lane index, nil unless the stage is weighted
+	joined       chan struct{} // signaled when a lane is added
+
+	// with WorkStealing
+	queued   chan struct{} // signaled when an object is queued
+	dequeued chan struct{} // signaled when an object is taken from a queue
+	finished chan struct{} // closed with the input of the stage
+
+	mu    sync.Mutex
+	lanes []*lane
+	next  int
+}
+
+func newDispatcher(d Distribution, weights []int) *dispatcher {
+	return &dispatcher{
+		distribution: d,
+		weights:      weights,
+		joined:       make(chan struct{}, 1),
+		queued:       make(chan struct{}, 1),
+		dequeued:     make(chan struct{}, 1),
+		finished:     make(chan struct{}),
+	}
+}
+
+// join adds a lane for a goroutine, or returns nil if the input of the stage
+// is closed already.
+func (d *dispatcher) join(quit chan struct{}) *lane {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if isClosed(d.finished) {
+		return nil
+	}
+	taken := map[int]bool{}
+	for _, ln := range d.lanes {
+		taken[ln.index] = true
+	}
+	ln := &lane{quit: quit}
+	if d.distribution == WorkStealing && d.weights == nil {
+		ln.retired = make(chan struct{})
+	} else {
+		ln.ch = make(chan interface{}, laneSize)
+	}
+	for taken[ln.index] {
+		ln.index++
+	}
+	d.lanes = append(d.lanes, ln)
+	notify(d.joined)
+	return ln
+}
+
+// run dispatches the objects of inChan until it is closed or the run is
+// aborted, then closes the lanes.
+func (d *dispatcher) run(inChan <-chan interface{}, ctl *stageControl, done <-chan struct{}) {
+	defer d.finish()
+	for {
+		_, _, changed := ctl.state()
+		select {
+		case obj, ok := <-inChan:
+			if !ok || !d.send(obj, ctl, done) {
+				return
+			}
+		case <-changed:
+			d.mu.Lock()
+			d.prune()
+			d.mu.Unlock()
+		case <-done:
+			return
+		}
+	}
+}
+
+// send hands obj to a lane. It returns false if the run was aborted first.
+func (d *dispatcher) send(obj interface{}, ctl *stageControl, done <-chan struct{}) bool {
+	for {
+		fanSize, _, changed := ctl.state()
+		ln := d.pick(int(fanSize))
+		if ln == nil {
+			select {
+			case <-d.joined:
+			case <-done:
+				return false
+			}
+			continue
+		}
+		if ln.ch == nil {
+			if d.queue(ln, obj) {
+				return true
+			}
+			// all the queues are full, wait for room
+			select {
+			case <-d.dequeued:
+			case <-changed:
+			case <-done:
+				return false
+			}
+			continue
+		}
+		select {
+		case ln.ch <- obj:
+			return true
+		case <-changed:
+			// the fan size changed, pick again
+		case <-done:
+			return false
+		}
+	}
+}
+
+// pick returns the lane the next object goes to, or nil until all the
+// goroutines of the stage have joined so that they all get their turn.
+func (d *dispatcher) pick(fanSize int) *lane {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prune()
+	n := len(d.lanes)
+	if n == 0 || n < fanSize {
+		return nil
+	}
+	if d.weights != nil {
+		return d.pickWeighted()
+	}
+	best := d.next % n
+	if d.distribution == LeastLoaded || d.distribution == WorkStealing {
+		for i := 1; i < n; i++ {
+			if j := (d.next + i) % n; d.lanes[j].load() < d.lanes[best].load() {
+				best = j
+			}
+		}
+	}
+	d.next = best + 1
+	return d.lanes[best]
+}
+
+// pickWeighted picks a lane by smooth weighted round-robin: every lane earns
+// its weight on every pick and the richest one pays the total weight back.
+// It must be called with the mutex held.
+func (d *dispatcher) pickWeighted() *lane {
+	var best *lane
+	total := 0
+	for _, ln := range d.lanes {
+		ln.current += d.weights[ln.index]
+		total += d.weights[ln.index]
+		if best == nil || ln.current > best.current {
+			best = ln
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// prune closes and removes the lanes whose goroutine was told to quit. It
+// must be called with the mutex held.
+func (d *dispatcher) prune() {
+	live := d.lanes[:0]
+	for _, ln := range d.lanes {
+		if isClosed(ln.quit) {
+			closeLane(ln)
+		} else {
+			live = append(live, ln)
+		}
+	}
+	for i := len(live); i < len(d.lanes); i++ {
+		d.lanes[i] = nil
+	}
+	d.lanes = live
+}
+
+// finish closes the lanes once the input of the stage is closed. The queues
+// of WorkStealing lanes are kept for the goroutines stealing from them.
+func (d *dispatcher) finish() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	close(d.finished)
+	for _, ln := range d.lanes {
+		if ln.ch != nil {
+			close(ln.ch)
+		}
+	}
+}
+
+// notify wakes up a goroutine waiting on ch, unless one will wake up already.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,99 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"sort"
+	"sync"
+)
+
+// countingWorker counts the objects its goroutine processed.
+type countingWorker struct {
+	mu     *sync.Mutex
+	counts *[]int
+	index  int
+}
+
+func (w countingWorker) Process(inObj interface{}) (interface{}, error) {
+	w.mu.Lock()
+	(*w.counts)[w.index]++
+	w.mu.Unlock()
+	return inObj, nil
+}
+
+func ExampleWithDistribution() {
+	var mu sync.Mutex
+	var counts []int
+	newWorker := func() pipeline.Worker {
+		mu.Lock()
+		defer mu.Unlock()
+		counts = append(counts, 0)
+		return countingWorker{mu: &mu, counts: &counts, index: len(counts) - 1}
+	}
+
+	p := pipeline.New()
+	p.AddStageWithWorkers(newWorker, 3, pipeline.WithDistribution(pipeline.RoundRobin))
+
+	in := make(chan interface{}, 9)
+	for i := 0; i < 9; i++ {
+		in <- i
+	}
+	close(in)
+	<-p.Run(in)
+
+	sort.Ints(counts)
+	fmt.Println(counts)
+
+	// Output: [3 3 3]
+}
+
+func ExampleDistribute() {
+	in := make(chan interface{})
+	go func() {
+		for i := 1; i <= 100; i++ {
+			in <- i
+		}
+		close(in)
+	}()
+
+	// the consumers with the fewest objects waiting get the next ones
+	outs := pipeline.Distribute(in, 3, 10, pipeline.LeastLoaded)
+	var mu sync.Mutex
+	var sum int
+	var wg sync.WaitGroup
+	for _, out := range outs {
+		wg.Add(1)
+		go func(out chan interface{}) {
+			defer wg.Done()
+			for obj := range out {
+				mu.Lock()
+				sum += obj.(int)
+				mu.Unlock()
+			}
+		}(out)
+	}
+	wg.Wait()
+	fmt.Println(sum)
+
+	// Output: 5050
+}
+
+func ExampleWithDistribution_workStealing() {
+	p := pipeline.New()
+	p.AddStageWithFanOut(squareStage, 4, pipeline.WithDistribution(pipeline.WorkStealing))
+	var sum int
+	p.AddStage(func(inObj interface{}) interface{} {
+		sum += inObj.(int)
+		return inObj
+	})
+
+	in := make(chan interface{}, 10)
+	for i := 1; i <= 10; i++ {
+		in <- i
+	}
+	close(in)
+	<-p.Run(in)
+	fmt.Println(sum)
+
+	// Output: 385
+}
@@ -0,0 +1,74 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Dot renders the topology of the pipeline in the DOT language of Graphviz,
+// e.g. to document it with `dot -Tsvg`. Stages are boxes labeled with their
+// name, kind and fan size, the input is labeled with its priority buffer and
+// in-flight limit if any, and the side output pipelines are drawn as
+// clusters branching off the stages.
+func (p *Pipeline) Dot() string {
+	var buf bytes.Buffer
+	buf.WriteString("digraph pipeline {\n")
+	buf.WriteString("\trankdir=LR;\n")
+	buf.WriteString("\tnode [shape=box];\n")
+	p.writeDot(&buf, "", "\t")
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// writeDot writes the nodes and edges of the pipeline, prefixing the node IDs
+// so that the ones of side outputs don't clash, and returns the ID of the
+// input node. The IDs of the stages are namespaced apart from the input and
+// output nodes, whatever the names of the stages.
+func (p *Pipeline) writeDot(buf *bytes.Buffer, prefix, indent string) (input string) {
+	input = strconv.Quote(prefix + "input")
+	label := "input"
+	if p.priorities > 0 {
+		label += fmt.Sprintf("\npriorities, buffer %d", p.priorities)
+	}
+	if p.maxInFlight > 0 {
+		label += fmt.Sprintf("\nmax in flight %d", p.maxInFlight)
+	}
+	fmt.Fprintf(buf, "%s%s [shape=plaintext, label=%s];\n", indent, input, strconv.Quote(label))
+
+	prev := input
+	var ids []string
+	for _, sd := range p.Definition().Stages {
+		id := strconv.Quote(prefix + "stage:" + sd.Name)
+		ids = append(ids, id)
+		label := sd.Name + "\n" + sd.Kind
+		if sd.Kind != "raw" {
+			label += fmt.Sprintf(" ×%d", sd.FanSize)
+		}
+		fmt.Fprintf(buf, "%s%s [label=%s];\n", indent, id, strconv.Quote(label))
+		fmt.Fprintf(buf, "%s%s -> %s;\n", indent, prev, id)
+		prev = id
+	}
+	output := strconv.Quote(prefix + "output")
+	fmt.Fprintf(buf, "%s%s [shape=plaintext, label=\"output\"];\n", indent, output)
+	fmt.Fprintf(buf, "%s%s -> %s;\n", indent, prev, output)
+
+	var names []string
+	for name := range p.sideOutputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		sidePrefix := prefix + "side:" + name + "/"
+		fmt.Fprintf(buf, "%ssubgraph %s {\n", indent, strconv.Quote("cluster_"+sidePrefix))
+		fmt.Fprintf(buf, "%s\tlabel=%s;\n", indent, strconv.Quote("side output "+name))
+		sideInput := p.sideOutputs[name].writeDot(buf, sidePrefix, indent+"\t")
+		fmt.Fprintf(buf, "%s}\n", indent)
+		// any stage may send objects to a side output
+		for _, id := range ids {
+			fmt.Fprintf(buf, "%s%s -> %s [style=dashed];\n", indent, id, sideInput)
+		}
+	}
+	return
+}
@@ -0,0 +1,41 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_Dot() {
+	invalid := pipeline.New()
+	invalid.AddStage(printStage, pipeline.WithName("quarantine"))
+
+	p := pipeline.New()
+	p.AddStageWithFanOut(squareStage, 4, pipeline.WithName("square"))
+	p.AddStage(printStage, pipeline.WithName("output"))
+	p.AttachSideOutput("invalid", &invalid)
+	p.EnablePriorities(64)
+
+	fmt.Print(p.Dot())
+
+	// Output: digraph pipeline {
+	// 	rankdir=LR;
+	// 	node [shape=box];
+	// 	"input" [shape=plaintext, label="input\npriorities, buffer 64"];
+	// 	"stage:square" [label="square\nprocess ×4"];
+	// 	"input" -> "stage:square";
+	// 	"stage:output" [label="output\nprocess ×1"];
+	// 	"stage:square" -> "stage:output";
+	// 	"output" [shape=plaintext, label="output"];
+	// 	"stage:output" -> "output";
+	// 	subgraph "cluster_side:invalid/" {
+	// 		label="side output invalid";
+	// 		"side:invalid/input" [shape=plaintext, label="input"];
+	// 		"side:invalid/stage:quarantine" [label="quarantine\nprocess ×1"];
+	// 		"side:invalid/input" -> "side:invalid/stage:quarantine";
+	// 		"side:invalid/output" [shape=plaintext, label="output"];
+	// 		"side:invalid/stage:quarantine" -> "side:invalid/output";
+	// 	}
+	// 	"stage:square" -> "side:invalid/input" [style=dashed];
+	// 	"stage:output" -> "side:invalid/input" [style=dashed];
+	// }
+}
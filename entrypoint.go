@@ -0,0 +1,180 @@
+package pipeline
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Exit codes returned by Main, e.g. for the pod failure policy of a Kubernetes
+// Job to tell failures worth a retry from the others.
+const (
+	ExitSuccess        = 0 // every object was processed
+	ExitFatal          = 1 // the run could not start, was aborted or failed too many objects
+	ExitPartialFailure = 2 // the run completed but some objects failed
+)
+
+// MainOptions configure Main.
+type MainOptions struct {
+	// HealthAddr, if set, is the address serving the /healthz liveness and
+	// /readyz readiness endpoints, e.g. ":8080".
+	HealthAddr string
+
+	// DrainTimeout bounds the time given to the objects in flight to get
+	// through the pipeline after a termination signal. The run is aborted
+	// once it elapses. Zero waits until the pipeline is drained.
+	DrainTimeout time.Duration
+
+	// FlushTimeout bounds the time given to the sinks to flush their
+	// buffers once the run is done, see Pipeline.Flush. Zero doesn't bound
+	// it.
+	FlushTimeout time.Duration
+
+	// Labels label the run, see WithLabels.
+	Labels Labels
+
+	// Activity is passed on to RunActivity.
+	Activity ActivityOptions
+}
+
+// Main runs the pipeline as the main job of a process, such as a Kubernetes
+// Job, and returns the exit code of the process:
+//
+//	func main() {
+//		os.Exit(p.Main(readInput(), pipeline.MainOptions{HealthAddr: ":8080"}))
+//	}
+//
+// The stages are initialized and closed around the run, see Pipeline.Init,
+// their caches are preloaded before the process is reported as ready, see
+// Pipeline.Preload, and the sinks are flushed once the run is done, see
+// Pipeline.Flush. On SIGTERM or SIGINT, the pipeline stops reading inChan,
+// which its producer must then stop sending to, and the objects in flight are
+// drained before the process exits. /readyz reports the process as ready while
+// the pipeline is running and not draining; /healthz reports it as alive while
+// it serves.
+func (p *Pipeline) Main(inChan <-chan interface{}, opts MainOptions) (exitCode int) {
+	var ready int32
+	if opts.HealthAddr != "" {
+		l, err := net.Listen("tcp", opts.HealthAddr)
+		if err != nil {
+			log.Printf("pipeline: %v", err)
+			return ExitFatal
+		}
+		srv := &http.Server{Handler: healthHandler(&ready)}
+		go srv.Serve(l)
+		defer srv.Close()
+	}
+
+	ctx, cancel := context.WithCancel(WithLabels(context.Background(), opts.Labels))
+	defer cancel()
+	if err := p.Init(ctx); err != nil {
+		log.Print(err)
+		return ExitFatal
+	}
+	defer func() {
+		if err := p.Close(); err != nil {
+			log.Print(err)
+			exitCode = ExitFatal
+		}
+	}()
+	if err := p.Preload(ctx); err != nil {
+		log.Print(err)
+		return ExitFatal
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(signals)
+
+	drain := make(chan struct{})
+	runDone := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			log.Printf("pipeline: received %v, draining", sig)
+			atomic.StoreInt32(&ready, 0)
+			close(drain)
+		case <-runDone:
+			return
+		}
+		if opts.DrainTimeout > 0 {
+			select {
+			case <-time.After(opts.DrainTimeout):
+				log.Print("pipeline: drain timed out")
+				cancel()
+			case <-runDone:
+			}
+		}
+	}()
+
+	atomic.StoreInt32(&ready, 1)
+	report, err := p.RunActivity(ctx, untilClosed(inChan, drain), opts.Activity)
+	close(runDone)
+	atomic.StoreInt32(&ready, 0)
+
+	flushCtx := context.Background()
+	if opts.FlushTimeout > 0 {
+		var cancelFlush context.CancelFunc
+		flushCtx, cancelFlush = context.WithTimeout(flushCtx, opts.FlushTimeout)
+		defer cancelFlush()
+	}
+	if ferr := p.Flush(flushCtx); ferr != nil {
+		log.Print(ferr)
+		return ExitFatal
+	}
+	if err != nil {
+		log.Print(err)
+		return ExitFatal
+	}
+	for _, s := range report.Stages {
+		if s.Errors > 0 {
+			return ExitPartialFailure
+		}
+	}
+	return ExitSuccess
+}
+
+// untilClosed forwards inChan until either it or drain is closed.
+func untilClosed(inChan <-chan interface{}, drain <-chan struct{}) <-chan interface{} {
+	outChan := make(chan interface{})
+	go func() {
+		defer close(outChan)
+		for {
+			select {
+			case obj, ok := <-inChan:
+				if !ok {
+					return
+				}
+				select {
+				case outChan <- obj:
+				case <-drain:
+					return
+				}
+			case <-drain:
+				return
+			}
+		}
+	}()
+	return outChan
+}
+
+func healthHandler(ready *int32) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(ready) == 0 {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	return mux
+}
@@ -0,0 +1,28 @@
+package pipeline_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_Main() {
+	p := pipeline.New()
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		if inObj.(int) < 0 {
+			return nil, errors.New("negative value")
+		}
+		return inObj, nil
+	}, 2)
+
+	in := make(chan interface{}, 3)
+	in <- 1
+	in <- -2
+	in <- 3
+	close(in)
+
+	// in a main function: os.Exit(p.Main(in, pipeline.MainOptions{}))
+	fmt.Println(p.Main(in, pipeline.MainOptions{}) == pipeline.ExitPartialFailure)
+
+	// Output: true
+}
@@ -0,0 +1,203 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Envelope carries an object through a pipeline along with metadata about it,
+// enabling ordering, tracing and latency measurements without changing the
+// objects themselves.
+//
+// Stages added with AddStage and the like see the payloads only: the payload
+// they return is put back into the envelope, so metadata set by earlier stages
+// is preserved. Stages added with AddEnvelopeStage see the envelopes and may
+// read and write their metadata. Raw stages see the envelopes as well and must
+// pass them on.
+//
+// The envelopes made by the pipeline itself are recycled once their object
+// leaves the pipeline, i.e. when it comes out of the last stage, is dropped or
+// fails, to spare the garbage collector in high-throughput pipelines. Stages
+// must not keep them after returning.
+type Envelope struct {
+	Payload interface{}
+
+	Ingested time.Time         // when the object entered the pipeline
+	Seq      uint64            // position of the object in the input of its run
+	Key      string            // e.g. a partitioning or deduplication key
+	Attrs    map[string]string // free-form attributes
+
+	// Priority orders the objects waiting for a stage, higher first, see
+	// EnablePriorities.
+	Priority int
+
+	// Trace is the trace context of the object, see EnableTracing.
+	Trace context.Context
+
+	// Acker is notified once the object has been processed, see
+	// Acknowledger.
+	Acker Acknowledger
+
+	rootSpan Span
+	pooled   bool // made by the pipeline, see releaseEnvelope
+}
+
+var envelopePool = sync.Pool{
+	New: func() interface{} {
+		return new(Envelope)
+	},
+}
+
+// newEnvelope returns an envelope from the pool.
+func newEnvelope(payload interface{}, seq uint64) *Envelope {
+	env := envelopePool.Get().(*Envelope)
+	env.Payload, env.Seq, env.pooled = payload, seq, true
+	return env
+}
+
+// releaseEnvelope puts an envelope made by newEnvelope back into the pool.
+// Other objects are left alone.
+func releaseEnvelope(obj interface{}) {
+	if env, ok := obj.(*Envelope); ok && env.pooled {
+		*env = Envelope{}
+		envelopePool.Put(env)
+	}
+}
+
+// SetAttr sets an attribute, allocating the Attrs map if needed.
+func (e *Envelope) SetAttr(key, value string) {
+	if e.Attrs == nil {
+		e.Attrs = map[string]string{}
+	}
+	e.Attrs[key] = value
+}
+
+// Age returns how long ago the object entered the pipeline, according to the
+// system clock. The ingestion time is set with the Clock of the pipeline, see
+// SetClock.
+func (e *Envelope) Age() time.Duration {
+	return time.Since(e.Ingested)
+}
+
+// EnvelopeFn is the type of the stages working on envelopes rather than on
+// payloads. It may modify the envelope it is given and return it, return a
+// new one, or return nil to drop the object.
+type EnvelopeFn func(env *Envelope) (*Envelope, error)
+
+// EnableEnvelopes makes the pipeline wrap every object it receives into an
+// Envelope stamped with its ingestion time and sequence number. Objects that
+// are already envelopes are passed as is, only their ingestion time is set if
+// it is zero. Envelopes are also enabled by EnableTracing.
+func (p *Pipeline) EnableEnvelopes() {
+	p.checkMutable()
+	p.envelopes = true
+}
+
+// AddEnvelopeStage adds a fan-out stage working on envelopes. Objects that
+// aren't envelopes yet are wrapped into one. See AddStageWithFanOut for the
+// meaning of fanSize and opts.
+func (p *Pipeline) AddEnvelopeStage(inFunc EnvelopeFn, fanSize uint64, opts ...StageOption) {
+	p.addStage(&stage{
+		process: func(inObj interface{}) (interface{}, error) {
+			outEnv, err := inFunc(inObj.(*Envelope))
+			if outEnv == nil || err != nil {
+				return nil, err
+			}
+			return outEnv, nil
+		},
+		envelope: true,
+		control:  newStageControl(fanSize),
+	}, opts...)
+}
+
+// envelopeIntake makes the StageFn that wraps incoming objects into envelopes
+// and starts their root span if t isn't nil, until done is closed.
+func envelopeIntake(t Tracer, clock Clock, done <-chan struct{}) StageFn {
+	return func(inChan <-chan interface{}) (outChan chan interface{}) {
+		outChan = make(chan interface{})
+		go func() {
+			defer close(outChan)
+			var seq uint64
+			for {
+				var obj interface{}
+				select {
+				case o, ok := <-inChan:
+					if !ok {
+						return
+					}
+					obj = o
+				case <-done:
+					return
+				}
+
+				env, ok := obj.(*Envelope)
+				if !ok {
+					env = newEnvelope(obj, seq)
+				}
+				seq++
+				if env.Ingested.IsZero() {
+					env.Ingested = clock.Now()
+				}
+				if t != nil {
+					if env.Trace == nil {
+						env.Trace = context.Background()
+					}
+					env.Trace, env.rootSpan = t.Start(env.Trace, "pipeline")
+				}
+
+				select {
+				case outChan <- env:
+				case <-done:
+					endTrace(env)
+					return
+				}
+			}
+		}()
+		return
+	}
+}
+
+// unwrapEnvelope makes a payload-level ProcessFnErr accept envelopes: the
+// payload is processed and the result put back into the envelope.
+func unwrapEnvelope(fn ProcessFnErr) ProcessFnErr {
+	return func(inObj interface{}) (interface{}, error) {
+		env, ok := inObj.(*Envelope)
+		if !ok {
+			return fn(inObj)
+		}
+
+		outObj, err := fn(env.Payload)
+		if outObj == nil || err != nil {
+			return nil, err
+		}
+		if outEnv, ok := outObj.(*Envelope); ok {
+			return outEnv, nil
+		}
+		env.Payload = outObj
+		return env, nil
+	}
+}
+
+// wrapEnvelope makes an envelope-level ProcessFnErr accept bare objects,
+// stamped with the time of clock.
+func wrapEnvelope(fn ProcessFnErr, clock Clock) ProcessFnErr {
+	var seq uint64
+	return func(inObj interface{}) (interface{}, error) {
+		if _, ok := inObj.(*Envelope); !ok {
+			env := newEnvelope(inObj, atomic.AddUint64(&seq, 1)-1)
+			env.Ingested = clock.Now()
+			inObj = env
+		}
+		return fn(inObj)
+	}
+}
+
+// payload returns the object carried by an Envelope.
+func payload(obj interface{}) interface{} {
+	if env, ok := obj.(*Envelope); ok {
+		return env.Payload
+	}
+	return obj
+}
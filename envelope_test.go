@@ -0,0 +1,34 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"strings"
+)
+
+func ExampleEnvelope() {
+	p := pipeline.New()
+	p.EnableEnvelopes()
+	p.AddEnvelopeStage(func(env *pipeline.Envelope) (*pipeline.Envelope, error) {
+		env.Key = strings.ToLower(env.Payload.(string))
+		env.SetAttr("source", "example")
+		return env, nil
+	}, 1)
+	p.AddStage(func(inObj interface{}) interface{} {
+		// payload stages are unaware of the envelopes
+		return strings.Repeat(inObj.(string), 2)
+	})
+	p.AddEnvelopeStage(func(env *pipeline.Envelope) (*pipeline.Envelope, error) {
+		fmt.Println(env.Seq, env.Key, env.Payload, env.Attrs["source"], !env.Ingested.IsZero())
+		return env, nil
+	}, 1)
+
+	ch := make(chan interface{}, 2)
+	ch <- "A"
+	ch <- "B"
+	close(ch)
+	<-p.Run(ch)
+
+	// Output: 0 a AA example true
+	// 1 b BB example true
+}
@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrorPolicy decides what a run does when a stage fails to process an
+// object, see SetErrorPolicy.
+type ErrorPolicy int
+
+const (
+	// ContinueOnError passes the failed objects to the dead-letter function
+	// and carries on. It is the default.
+	ContinueOnError ErrorPolicy = iota
+	// CollectErrors is ContinueOnError keeping the errors of every run, see
+	// Run.Errors.
+	CollectErrors
+	// FailFast aborts the run on the first error, which Run.Err returns.
+	FailFast
+)
+
+var errorPolicyNames = []string{"continue", "collect", "fail_fast"}
+
+func (e ErrorPolicy) String() string {
+	if e < 0 || int(e) >= len(errorPolicyNames) {
+		return fmt.Sprintf("ErrorPolicy(%d)", int(e))
+	}
+	return errorPolicyNames[e]
+}
+
+// ParseErrorPolicy returns the ErrorPolicy named s, as returned by String.
+func ParseErrorPolicy(s string) (ErrorPolicy, error) {
+	for i, name := range errorPolicyNames {
+		if name == s {
+			return ErrorPolicy(i), nil
+		}
+	}
+	return ContinueOnError, fmt.Errorf("pipeline: unknown error policy %q", s)
+}
+
+// SetErrorPolicy decides whether the runs of the pipeline carry on when a
+// stage fails, which is the default, or are aborted on the first error as if
+// their context was canceled: the stages stop, upstream ones included, and the
+// objects in flight are abandoned. The dead-letter function, if any, gets the
+// failed objects either way.
+func (p *Pipeline) SetErrorPolicy(policy ErrorPolicy) {
+	p.checkMutable()
+	p.errorPolicy = policy
+}
+
+// ProcessFnErr is a ProcessFn that can fail. Objects for which it returns an
+// error are not passed on but sent to the pipeline's dead-letter function
+// instead, see SetDeadLetter.
+type ProcessFnErr func(inObj interface{}) (outObj interface{}, err error)
+
+// ItemError describes an object that a stage failed to process.
+type ItemError struct {
+	Stage    string      // name of the stage that failed
+	Obj      interface{} // the object the stage was given
+	Attempts int         // number of times the object was tried
+	Err      error       // the last error returned by the stage
+}
+
+func (e *ItemError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("pipeline: %s failed after %d attempts: %v", e.Stage, e.Attempts, e.Err)
+	}
+	return fmt.Sprintf("pipeline: %s failed: %v", e.Stage, e.Err)
+}
+
+// AddStageErr adds a fan-out stage that can fail. It otherwise behaves exactly
+// like AddStageWithFanOut.
+func (p *Pipeline) AddStageErr(inFunc ProcessFnErr, fanSize uint64, opts ...StageOption) {
+	p.addStage(&stage{process: inFunc, control: newStageControl(fanSize)}, opts...)
+}
+
+// SetDeadLetter sets the function that receives the objects which a stage
+// failed to process, e.g. to log them or to store them for a later redrive.
+// It is called from the goroutines of the stages and must be safe for
+// concurrent use. Without one, failed objects are only counted in Stats.
+func (p *Pipeline) SetDeadLetter(fn func(*ItemError)) {
+	p.checkMutable()
+	p.deadLetter = fn
+}
+
+// handleError routes the failure of a stage to the dead-letter function and,
+// if the run is reported, to its error samples.
+func (p *Pipeline) handleError(s *stage, run *runState, inObj interface{}, err error) {
+	if run.logger != nil {
+		run.logger.itemFailed(s.name, err)
+	}
+	if p.deadLetter == nil && run.samples == nil && run.events == nil && p.errorPolicy == ContinueOnError {
+		return
+	}
+
+	itemErr := &ItemError{Stage: s.name, Obj: payload(inObj), Attempts: 1, Err: err}
+	if exhausted, ok := err.(*retriesExhausted); ok {
+		itemErr.Attempts, itemErr.Err = exhausted.attempts, exhausted.err
+	}
+	if run.samples != nil {
+		run.samples.add(itemErr)
+	}
+	if p.deadLetter != nil {
+		atomic.AddUint64(&s.counters.deadLettered, 1)
+		p.deadLetter(itemErr)
+		run.events.Publish(Event{Type: EventDeadLettered, Labels: run.labels, Stage: s.name,
+			Object: itemErr.Obj, Err: itemErr.Err})
+	}
+	switch p.errorPolicy {
+	case CollectErrors:
+		run.collectError(itemErr)
+	case FailFast:
+		run.fail(itemErr)
+	}
+}
+
+// collectError keeps the error of a run whose pipeline collects them.
+func (run *runState) collectError(err *ItemError) {
+	run.errMu.Lock()
+	defer run.errMu.Unlock()
+	run.errors = append(run.errors, err)
+}
+
+// fail aborts a run failing fast, unless it was aborted already.
+func (run *runState) fail(err *ItemError) {
+	run.errMu.Lock()
+	defer run.errMu.Unlock()
+	if run.failed == nil && run.ctx.Err() == nil {
+		run.failed = err
+		run.errors = append(run.errors, err)
+		run.cancel()
+	}
+}
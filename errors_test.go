@@ -0,0 +1,56 @@
+package pipeline_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_SetErrorPolicy() {
+	p := pipeline.New()
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		if inObj.(int) == 3 {
+			return nil, errors.New("unexpected 3")
+		}
+		return inObj, nil
+	}, 1, pipeline.WithName("check"))
+	p.SetErrorPolicy(pipeline.FailFast)
+
+	// the input of the run is never closed, the error ends it
+	in := make(chan interface{}, 5)
+	for i := 1; i <= 5; i++ {
+		in <- i
+	}
+	run := p.Start(in)
+	<-run.Done()
+	fmt.Println(run.Err())
+
+	// Output: pipeline: check failed: unexpected 3
+}
+
+func ExampleCollectErrors() {
+	p := pipeline.New()
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		if inObj.(int)%2 == 0 {
+			return nil, fmt.Errorf("%d is even", inObj)
+		}
+		return inObj, nil
+	}, 1, pipeline.WithName("odd"))
+	p.SetErrorPolicy(pipeline.CollectErrors)
+
+	in := make(chan interface{}, 5)
+	for i := 1; i <= 5; i++ {
+		in <- i
+	}
+	close(in)
+	run := p.Start(in)
+	<-run.Done()
+	fmt.Println(run.Err())
+	for _, err := range run.Errors() {
+		fmt.Println(err)
+	}
+
+	// Output: <nil>
+	// pipeline: odd failed: 2 is even
+	// pipeline: odd failed: 4 is even
+}
@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+// The events published on the EventBus of a pipeline.
+const (
+	EventRunStarted   EventType = "run_started"   // a run started
+	EventRunFinished  EventType = "run_finished"  // a run finished, Err is set if it was aborted
+	EventDeadLettered EventType = "dead_lettered" // a failed object was handed to the dead-letter function
+	EventCheckpoint   EventType = "checkpoint"    // a Checkpointer saved its positions, carried by Object
+	EventAutoscaled   EventType = "autoscaled"    // StartAutoscaling changed the fan size of a stage
+	EventStageStalled EventType = "stage_stalled" // StartWatchdog found a stage that stopped making progress, carried by Object
+)
+
+// Event is something that happened in a pipeline, for metrics, logging,
+// alerting or automation subscribed to its EventBus. The fields that don't
+// apply to the type of event are left empty.
+type Event struct {
+	Type    EventType
+	Time    time.Time
+	Labels  Labels // of the run, see WithLabels
+	Stage   string
+	Object  interface{}
+	Err     error
+	Message string
+}
+
+// EventBus delivers the events of a pipeline to its subscribers. The nil
+// *EventBus drops all the events.
+type EventBus struct {
+	mu          sync.Mutex
+	nextID      int
+	subscribers map[int]subscriber
+	clock       Clock // of the pipeline, see SetClock
+}
+
+type subscriber struct {
+	fn    func(Event)
+	types map[EventType]bool // nil for all the types
+}
+
+// NewEventBus creates an EventBus without subscribers.
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: map[int]subscriber{}}
+}
+
+// Events returns the EventBus of the pipeline, to subscribe to its events:
+//
+//	p.Events().Subscribe(func(e pipeline.Event) {
+//		log.Printf("%s %s: %v", e.Type, e.Stage, e.Err)
+//	}, pipeline.EventDeadLettered)
+//
+// Like Use, it must be called for the first time before the pipeline runs.
+func (p *Pipeline) Events() *EventBus {
+	if p.events == nil {
+		p.events = NewEventBus()
+		p.events.setClock(p.clock)
+	}
+	return p.events
+}
+
+// setClock makes the bus stamp the events with the time of clock, or of the
+// system clock if nil.
+func (b *EventBus) setClock(clock Clock) {
+	b.mu.Lock()
+	b.clock = clock
+	b.mu.Unlock()
+}
+
+// Subscribe calls fn with the events of the given types, or with all the
+// events if no type is given, until unsubscribe is called. Events are
+// delivered synchronously from the goroutine publishing them, such as the
+// goroutine of a stage, so fn must be quick and safe for concurrent use.
+func (b *EventBus) Subscribe(fn func(Event), types ...EventType) (unsubscribe func()) {
+	sub := subscriber{fn: fn}
+	if len(types) > 0 {
+		sub.types = map[EventType]bool{}
+		for _, t := range types {
+			sub.types[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = sub
+	b.mu.Unlock()
+
+	return func() {
+		b.mu.Lock()
+		delete(b.subscribers, id)
+		b.mu.Unlock()
+	}
+}
+
+// Publish delivers an event to the subscribers, setting its Time if it is
+// zero.
+func (b *EventBus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	if e.Time.IsZero() {
+		e.Time = clockOrSystem(b.clock).Now()
+	}
+	var fns []func(Event)
+	for _, sub := range b.subscribers {
+		if sub.types == nil || sub.types[e.Type] {
+			fns = append(fns, sub.fn)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(e)
+	}
+}
@@ -0,0 +1,32 @@
+package pipeline_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExampleEventBus_Subscribe() {
+	p := pipeline.New()
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		if inObj.(int) < 0 {
+			return nil, errors.New("negative")
+		}
+		return inObj, nil
+	}, 1, pipeline.WithName("validate"))
+	p.SetDeadLetter(func(*pipeline.ItemError) {})
+
+	p.Events().Subscribe(func(e pipeline.Event) {
+		fmt.Println(e.Type, e.Stage, e.Object, e.Err)
+	})
+
+	in := make(chan interface{}, 2)
+	in <- 1
+	in <- -1
+	close(in)
+	<-p.Run(in)
+
+	// Output: run_started  <nil> <nil>
+	// dead_lettered validate -1 negative
+	// run_finished  <nil> <nil>
+}
@@ -1,8 +1,10 @@
 package pipeline_test
 
 import (
+	"context"
 	"fmt"
 	"github.com/hyfather/pipeline"
+	"time"
 )
 
 func printStage(inObj interface{}) interface{} {
@@ -33,3 +35,25 @@ func Example() {
 	// Output: 4
 	// 9
 }
+
+func ExamplePipeline_RunContext() {
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	p.AddStage(printStage)
+
+	// the input is never closed, so only the deadline ends the run
+	ch := make(chan interface{}, 10)
+	ch <- 2
+	ch <- 3
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	<-p.RunContext(ctx, ch)
+
+	fmt.Println(ctx.Err())
+	fmt.Println(p.Stats().Stages[0].In)
+	// Output: 4
+	// 9
+	// context deadline exceeded
+	// 2
+}
@@ -0,0 +1,19 @@
+package pipeline
+
+import (
+	"expvar"
+)
+
+// PublishExpvar exposes the pipeline's Stats through the standard library
+// expvar package under the given name, making them visible on `/debug/vars`
+// without any metrics stack. The labels of the runs in progress are published
+// along with the counters, see Stats.Runs.
+//
+// The published value is computed on every read, so stages added after the
+// call are picked up as well. Like expvar.Publish, it panics if the name is
+// already in use.
+func (p *Pipeline) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return p.Stats()
+	}))
+}
@@ -0,0 +1,47 @@
+package pipeline_test
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_PublishExpvar() {
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	p.PublishExpvar("squares")
+
+	ch := make(chan interface{}, 10)
+	ch <- 2
+	ch <- "two"
+	close(ch)
+	<-p.Run(ch)
+
+	var stats pipeline.Stats
+	pipeline.DefaultCodec().Unmarshal([]byte(expvar.Get("squares").String()), &stats)
+	s := stats.Stages[0]
+	fmt.Println(s.Name, s.In, s.Out, s.Dropped, s.Errors)
+	// Output: stage0 2 1 1 0
+}
+
+func ExamplePipeline_PublishExpvar_labels() {
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	p.PublishExpvar("labeled-squares")
+
+	in := make(chan interface{})
+	ctx := pipeline.WithLabels(context.Background(), pipeline.Labels{"team": "billing"})
+	run := p.StartContext(ctx, in)
+
+	// the counters are attributed to the runs in progress
+	var stats pipeline.Stats
+	pipeline.DefaultCodec().Unmarshal([]byte(expvar.Get("labeled-squares").String()), &stats)
+	fmt.Println(stats.Runs[run.ID()]["team"])
+	close(in)
+	<-run.Done()
+	fmt.Println(run.Stats().Labels["team"])
+
+	// Output: billing
+	// billing
+}
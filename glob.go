@@ -0,0 +1,137 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FileRecord is a record read by a GlobSource, tagged with where it comes
+// from.
+type FileRecord struct {
+	File   string
+	Index  int         // of the record in the file, starting at 1
+	Record interface{} // as emitted by the ReaderSource reading the file
+}
+
+// GlobSource is a Source reading the files matching a glob pattern, several
+// at a time, and emitting their records as FileRecords. The records of a
+// file are emitted in order, those of different files are interleaved.
+//
+// A file that can't be read doesn't stop the others: its error is passed to
+// OnError, if set, and reported by Err once the source is done.
+type GlobSource struct {
+	Pattern     string
+	Concurrency int // files read at a time, 1 if zero
+
+	// NewReader creates the ReaderSource reading a file, NewLineSource if
+	// nil.
+	NewReader func(r io.Reader) *ReaderSource
+
+	OnError func(file string, err error)
+
+	mu   sync.Mutex
+	errs []string
+}
+
+// NewGlobSource creates a GlobSource emitting the lines of the files matching
+// pattern, see filepath.Glob, reading up to concurrency files at a time.
+func NewGlobSource(pattern string, concurrency int) *GlobSource {
+	return &GlobSource{Pattern: pattern, Concurrency: concurrency}
+}
+
+// Open implements Source. It fails if the pattern is malformed; matching no
+// file isn't an error.
+func (s *GlobSource) Open(ctx context.Context) (<-chan interface{}, error) {
+	files, err := filepath.Glob(s.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	concurrency := s.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ch := make(chan interface{})
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			for file := range paths {
+				if err := s.readFile(ctx, file, ch); err != nil {
+					s.fail(file, err)
+				}
+			}
+		}()
+	}
+	go func() {
+		defer close(ch)
+		defer wg.Wait()
+		defer close(paths)
+		for _, file := range files {
+			select {
+			case paths <- file:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (s *GlobSource) readFile(ctx context.Context, file string, ch chan<- interface{}) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	newReader := s.NewReader
+	if newReader == nil {
+		newReader = NewLineSource
+	}
+	src := newReader(f)
+	records, err := src.Open(ctx)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	index := 0
+	for record := range records {
+		index++
+		select {
+		case ch <- FileRecord{File: file, Index: index, Record: record}:
+		case <-ctx.Done():
+			// let the reader see ctx and close the file
+			for range records {
+			}
+			return nil
+		}
+	}
+	return src.Err()
+}
+
+func (s *GlobSource) fail(file string, err error) {
+	s.mu.Lock()
+	s.errs = append(s.errs, fmt.Sprintf("%s: %v", file, err))
+	s.mu.Unlock()
+	if s.OnError != nil {
+		s.OnError(file, err)
+	}
+}
+
+// Err returns an error listing the files that couldn't be read, nil if all
+// were. It must be called once the channel is closed.
+func (s *GlobSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("pipeline: %d files failed: %s", len(s.errs), strings.Join(s.errs, "; "))
+}
@@ -0,0 +1,41 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+func ExampleGlobSource() {
+	dir, _ := ioutil.TempDir("", "logs")
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "a.log"), []byte("a1\na2\n"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "b.log"), []byte("b1\n"), 0644)
+
+	var lines []string
+	p := pipeline.New()
+	p.AddStage(func(inObj interface{}) interface{} {
+		r := inObj.(pipeline.FileRecord)
+		lines = append(lines, fmt.Sprintf("%s:%d %s", filepath.Base(r.File), r.Index, r.Record))
+		return inObj
+	})
+
+	src := pipeline.NewGlobSource(filepath.Join(dir, "*.log"), 2)
+	in, _ := src.Open(context.Background())
+	<-p.Run(in)
+
+	sort.Strings(lines)
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+	fmt.Println(src.Err())
+
+	// Output: a.log:1 a1
+	// a.log:2 a2
+	// b.log:1 b1
+	// <nil>
+}
@@ -0,0 +1,224 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/hyfather/pipeline/segment"
+	"time"
+)
+
+// RunRecord is the end-of-run snapshot of the Stats of a pipeline, kept to
+// compare later runs of the same job against it.
+type RunRecord struct {
+	Key      string // identifies the job, see Pipeline.Fingerprint
+	Start    time.Time
+	Duration time.Duration
+	Stats    Stats
+	Labels   Labels // labels of the run, see WithLabels
+}
+
+// StatsStore persists RunRecords.
+type StatsStore interface {
+	Save(r RunRecord) error
+	History(key string) ([]RunRecord, error)
+}
+
+// SetStatsStore makes the pipeline save a RunRecord of every run to store
+// once it ends, keyed by the Fingerprint of the pipeline. The runs that are
+// aborted aren't saved, so as not to skew the baselines. Errors saving the
+// records go to onError, if not nil.
+func (p *Pipeline) SetStatsStore(store StatsStore, onError func(error)) {
+	p.checkMutable()
+	p.statsStore = &statsStoreConfig{store: store, onError: onError}
+}
+
+type statsStoreConfig struct {
+	store   StatsStore
+	onError func(error)
+}
+
+// startHistory returns the function saving the record of a run, or nil if
+// the pipeline has no StatsStore.
+func (p *Pipeline) startHistory(run *runState) func() {
+	cfg := p.statsStore
+	if cfg == nil {
+		return nil
+	}
+	key, start, before := p.Fingerprint(), run.clock.Now(), p.Stats()
+	return func() {
+		r := RunRecord{
+			Key:      key,
+			Start:    start,
+			Duration: run.clock.Now().Sub(start),
+			Stats:    p.Stats().Sub(before),
+			Labels:   run.labels,
+		}
+		if err := cfg.store.Save(r); err != nil && cfg.onError != nil {
+			cfg.onError(err)
+		}
+	}
+}
+
+// Regression is a metric of a stage that got worse than its baseline.
+type Regression struct {
+	Stage    string
+	Metric   string // "throughput" (objects out per second) or "error_rate"
+	Baseline float64
+	Current  float64
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s %s: %.4g -> %.4g", r.Stage, r.Metric, r.Baseline, r.Current)
+}
+
+// Fingerprint returns a hash of the pipeline topology, i.e. the names and fan
+// sizes of its stages, suitable as the key of RunRecords so that runs are only
+// compared with runs of the same configuration.
+func (p *Pipeline) Fingerprint() string {
+	h := sha256.New()
+	for _, s := range p.stageList() {
+		fmt.Fprintf(h, "%s/%d/%t;", s.name, s.control.getFanSize(), s.raw != nil)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// Sub returns the counters accumulated since prev was taken, which turns the
+// cumulative Stats of a pipeline into the Stats of a single run. Stages are
+// matched by name, should stages have been inserted in the meantime. The
+// labels of the runs are the ones of s.
+func (s Stats) Sub(prev Stats) Stats {
+	diff := Stats{Stages: append([]StageStats(nil), s.Stages...), Runs: s.Runs}
+	for i := range diff.Stages {
+		j := i
+		if j >= len(prev.Stages) || prev.Stages[j].Name != diff.Stages[i].Name {
+			j = prev.index(diff.Stages[i].Name)
+		}
+		if j >= 0 {
+			diff.Stages[i].In -= prev.Stages[j].In
+			diff.Stages[i].Out -= prev.Stages[j].Out
+			diff.Stages[i].Dropped -= prev.Stages[j].Dropped
+			diff.Stages[i].Errors -= prev.Stages[j].Errors
+			diff.Stages[i].DeadLettered -= prev.Stages[j].DeadLettered
+			diff.Stages[i].ProcessingTime -= prev.Stages[j].ProcessingTime
+		}
+	}
+	return diff
+}
+
+// index returns the index of the stage with the given name, -1 if none.
+func (s Stats) index(name string) int {
+	for i, st := range s.Stages {
+		if st.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// CompareRuns compares the throughput and error rate of every stage of the
+// current run against their average over the history, and reports the ones
+// that are worse by more than tolerance (e.g. 0.2 for 20%). Stages are matched
+// by name, as with Sub.
+func CompareRuns(current RunRecord, history []RunRecord, tolerance float64) (regressions []Regression) {
+	if len(history) == 0 {
+		return nil
+	}
+
+	for i, cur := range current.Stats.Stages {
+		var throughput, errorRate float64
+		var n int
+		for _, past := range history {
+			j := i
+			if j >= len(past.Stats.Stages) || past.Stats.Stages[j].Name != cur.Name {
+				if j = past.Stats.index(cur.Name); j < 0 {
+					continue
+				}
+			}
+			throughput += stageThroughput(past.Stats.Stages[j], past.Duration)
+			errorRate += stageErrorRate(past.Stats.Stages[j])
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		throughput /= float64(n)
+		errorRate /= float64(n)
+
+		if t := stageThroughput(cur, current.Duration); t < throughput*(1-tolerance) {
+			regressions = append(regressions, Regression{cur.Name, "throughput", throughput, t})
+		}
+		if e := stageErrorRate(cur); e > errorRate*(1+tolerance) {
+			regressions = append(regressions, Regression{cur.Name, "error_rate", errorRate, e})
+		}
+	}
+	return
+}
+
+func stageThroughput(s StageStats, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(s.Out) / d.Seconds()
+}
+
+func stageErrorRate(s StageStats) float64 {
+	if s.In == 0 {
+		return 0
+	}
+	return float64(s.Errors) / float64(s.In)
+}
+
+// FileStatsStore is a StatsStore keeping the records in segment files in a
+// directory, serialized with the default codec at the time they were saved.
+type FileStatsStore struct {
+	log   *segment.Log
+	codec Codec
+}
+
+// NewFileStatsStore opens or creates a FileStatsStore in dir. The records are
+// encrypted at rest with keys, unless nil.
+func NewFileStatsStore(dir string, keys segment.KeyProvider) (*FileStatsStore, error) {
+	codec := DefaultCodec()
+	log, err := segment.OpenLog(dir, segment.Header{Kind: "stats", Codec: codec.Name()}, segment.LogOptions{Keys: keys})
+	if err != nil {
+		return nil, err
+	}
+	return &FileStatsStore{log: log, codec: codec}, nil
+}
+
+// Save appends a record to the store.
+func (s *FileStatsStore) Save(r RunRecord) error {
+	data, err := s.codec.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err = s.log.Append(data); err != nil {
+		return err
+	}
+	return s.log.Sync()
+}
+
+// History returns the records saved with the given key, oldest first.
+func (s *FileStatsStore) History(key string) (records []RunRecord, err error) {
+	err = s.log.IterateWithHeader(func(h segment.Header, data []byte) error {
+		codec, ok := LookupCodec(h.Codec)
+		if !ok {
+			return fmt.Errorf("pipeline: unknown codec %q", h.Codec)
+		}
+		var r RunRecord
+		if err := codec.Unmarshal(data, &r); err != nil {
+			return err
+		}
+		if r.Key == key {
+			records = append(records, r)
+		}
+		return nil
+	})
+	return
+}
+
+// Close closes the underlying files.
+func (s *FileStatsStore) Close() error {
+	return s.log.Close()
+}
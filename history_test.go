@@ -0,0 +1,87 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/segment"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+func ExampleCompareRuns() {
+	dir, _ := ioutil.TempDir("", "stats")
+	defer os.RemoveAll(dir)
+	store, _ := pipeline.NewFileStatsStore(dir, nil)
+	defer store.Close()
+
+	record := func(out, errors uint64, d time.Duration) pipeline.RunRecord {
+		return pipeline.RunRecord{
+			Key:      "nightly-import",
+			Duration: d,
+			Stats: pipeline.Stats{Stages: []pipeline.StageStats{
+				{Name: "stage0", In: 1000, Out: out, Errors: errors},
+			}},
+		}
+	}
+	store.Save(record(990, 10, 10*time.Second))
+	store.Save(record(980, 20, 10*time.Second))
+
+	history, _ := store.History("nightly-import")
+	current := record(900, 100, 20*time.Second)
+	for _, r := range pipeline.CompareRuns(current, history, 0.2) {
+		fmt.Println(r)
+	}
+
+	// Output: stage0 throughput: 98.5 -> 45
+	// stage0 error_rate: 0.015 -> 0.1
+}
+
+func ExampleCompareRuns_insertedStage() {
+	history := []pipeline.RunRecord{{
+		Duration: time.Second,
+		Stats: pipeline.Stats{Stages: []pipeline.StageStats{
+			{Name: "parse", In: 100, Out: 100},
+			{Name: "store", In: 100, Out: 100},
+		}},
+	}}
+	// a stage was inserted since, the others are still compared by name
+	current := pipeline.RunRecord{
+		Duration: time.Second,
+		Stats: pipeline.Stats{Stages: []pipeline.StageStats{
+			{Name: "parse", In: 100, Out: 100},
+			{Name: "validate", In: 100, Out: 100},
+			{Name: "store", In: 100, Out: 50, Errors: 50},
+		}},
+	}
+	for _, r := range pipeline.CompareRuns(current, history, 0.2) {
+		fmt.Println(r)
+	}
+
+	// Output: store throughput: 100 -> 50
+	// store error_rate: 0 -> 0.5
+}
+
+func ExamplePipeline_SetStatsStore() {
+	dir, _ := ioutil.TempDir("", "stats")
+	defer os.RemoveAll(dir)
+	// the records are encrypted at rest
+	store, _ := pipeline.NewFileStatsStore(dir, segment.StaticKey("k1", make([]byte, 32)))
+	defer store.Close()
+
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	p.SetStatsStore(store, func(err error) {
+		fmt.Println(err)
+	})
+	ctx := pipeline.WithLabels(context.Background(), pipeline.Labels{"job": "nightly-import"})
+	<-p.RunContext(ctx, pipeline.FromFunc(sequence(3)))
+
+	history, _ := store.History(p.Fingerprint())
+	for _, r := range history {
+		fmt.Println(r.Labels["job"], r.Stats.Stages[0].Out)
+	}
+
+	// Output: nightly-import 3
+}
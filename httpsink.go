@@ -0,0 +1,179 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTPSink is a Sink sending objects to an HTTP endpoint, one per request or
+// in batches. Requests are retried on network errors and on 429 and 5xx
+// responses, following their Retry-After header if any; other responses
+// besides 2xx fail right away. Objects whose requests failed are sent to the
+// dead-letter function of the pipeline.
+type HTTPSink struct {
+	url string
+
+	// Client sends the requests, http.DefaultClient if nil.
+	Client *http.Client
+
+	// Method is the method of the requests, POST if empty.
+	Method string
+
+	// Header is added to every request.
+	Header http.Header
+
+	// Encode encodes the body of a request: an object or, when batching,
+	// the []interface{} of the objects of a batch. Bodies are JSON by
+	// default, with an application/json Content-Type.
+	Encode func(obj interface{}) ([]byte, error)
+
+	// BatchSize, if above one, sends objects in batches of up to BatchSize,
+	// waiting for up to FlushInterval (100ms if zero) for a batch to fill
+	// up. As with SQLSink, a write returns once its batch is sent, and the
+	// sink should be added with a fan size of at least BatchSize.
+	BatchSize     int
+	FlushInterval time.Duration
+
+	// Clock, if set, measures the FlushInterval instead of the system clock.
+	Clock Clock
+
+	// Timeout bounds each attempt of a request, 10s if zero.
+	Timeout time.Duration
+
+	// Concurrency, if set, bounds the number of requests in flight, across
+	// all the stages and runs using the sink.
+	Concurrency int
+
+	// MaxAttempts is the number of times a request is tried, 3 if zero,
+	// waiting according to Backoff in between.
+	MaxAttempts int
+	Backoff     Backoff
+
+	batcher   batcher
+	slotsOnce sync.Once
+	slots     chan struct{}
+}
+
+// NewHTTPSink creates an HTTPSink sending objects to url.
+func NewHTTPSink(url string) *HTTPSink {
+	s := &HTTPSink{url: url, Backoff: Backoff{Initial: 100 * time.Millisecond, Max: 5 * time.Second, Jitter: 0.2}}
+	s.batcher.commit = func(objs []interface{}) error {
+		return s.send(objs)
+	}
+	return s
+}
+
+// Write implements Sink.
+func (s *HTTPSink) Write(obj interface{}) error {
+	if s.BatchSize <= 1 {
+		return s.send(obj)
+	}
+	interval := s.FlushInterval
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+	return s.batcher.add(obj, s.BatchSize, interval, clockOrSystem(s.Clock))
+}
+
+// Flush implements Flushable, sending the batch filling up right away.
+func (s *HTTPSink) Flush(ctx context.Context) error {
+	return s.batcher.flush(ctx)
+}
+
+// send sends a request for obj, retrying.
+func (s *HTTPSink) send(obj interface{}) error {
+	var body []byte
+	var err error
+	if s.Encode != nil {
+		body, err = s.Encode(obj)
+	} else {
+		body, err = json.Marshal(obj)
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.Concurrency > 0 {
+		s.slotsOnce.Do(func() {
+			s.slots = make(chan struct{}, s.Concurrency)
+		})
+		s.slots <- struct{}{}
+		defer func() { <-s.slots }()
+	}
+
+	maxAttempts := s.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	return retryCall(maxAttempts, s.Backoff, isRetryableHTTP, clockOrSystem(s.Clock), nil, func() error {
+		return s.do(body)
+	})
+}
+
+// do makes a single attempt of a request.
+func (s *HTTPSink) do(body []byte) error {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	method := s.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	req, err := http.NewRequest(method, s.url, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	req = req.WithContext(ctx)
+	for name, values := range s.Header {
+		req.Header[name] = values
+	}
+	if s.Encode == nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		return RetryAfterWithClock(resp, clockOrSystem(s.Clock))
+	case resp.StatusCode >= 500:
+		return fmt.Errorf("pipeline: %s %s: %s", method, s.url, resp.Status)
+	}
+	return &permanentError{fmt.Errorf("pipeline: %s %s: %s", method, s.url, resp.Status)}
+}
+
+// permanentError is an error not worth retrying.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func isRetryableHTTP(err error) bool {
+	_, permanent := err.(*permanentError)
+	return !permanent
+}
@@ -0,0 +1,51 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+func ExampleNewHTTPSink() {
+	var attempts int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		attempts++
+		switch {
+		case string(body) == `"invalid"`:
+			w.WriteHeader(http.StatusBadRequest)
+		case attempts == 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			fmt.Printf("received %s\n", body)
+		}
+	}))
+	defer server.Close()
+
+	sink := pipeline.NewHTTPSink(server.URL)
+	sink.Backoff = pipeline.Backoff{Initial: time.Millisecond}
+
+	p := pipeline.New()
+	p.AddSink(sink, 1)
+	p.SetDeadLetter(func(err *pipeline.ItemError) {
+		fmt.Println("dead-lettered", err.Obj)
+	})
+
+	ch := make(chan interface{}, 3)
+	ch <- map[string]int{"order": 1}
+	ch <- "invalid"
+	ch <- map[string]int{"order": 2}
+	close(ch)
+	<-p.Run(ch)
+
+	fmt.Println(attempts, "requests")
+
+	// Output: received {"order":1}
+	// dead-lettered invalid
+	// received {"order":2}
+	// 4 requests
+}
@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HTTPSource is a Source fed by HTTP requests: it is an http.Handler pushing
+// the bodies of POST requests into the pipeline, answering 202 Accepted once
+// the pipeline took them. When the pipeline is saturated, i.e. doesn't take a
+// body within Wait, it answers 429 Too Many Requests with a Retry-After
+// header, so that clients back off rather than pile up:
+//
+//	src := pipeline.NewHTTPSource()
+//	http.Handle("/ingest", src)
+//	in, _ := src.Open(ctx)
+//	<-p.Run(in)
+//
+// Requests are answered 503 Service Unavailable before Open and once its
+// context is done, at which point the channel is closed.
+type HTTPSource struct {
+	// MaxBodySize bounds the size of the bodies, 1MiB if zero. Larger
+	// bodies are answered 413 Request Entity Too Large.
+	MaxBodySize int64
+
+	// Wait is how long a request waits for the pipeline to take its body.
+	Wait time.Duration
+
+	// RetryAfter is the delay suggested to the clients of the requests
+	// answered 429, one second if zero.
+	RetryAfter time.Duration
+
+	// Decode, if set, turns a request and its body into the object pushed
+	// into the pipeline; errors are answered 400 Bad Request. Bodies are
+	// pushed as []byte otherwise.
+	Decode func(r *http.Request, body []byte) (interface{}, error)
+
+	mu     sync.RWMutex
+	ch     chan interface{}
+	done   <-chan struct{}
+	closed bool
+}
+
+// NewHTTPSource creates an HTTPSource with the default settings.
+func NewHTTPSource() *HTTPSource {
+	return &HTTPSource{}
+}
+
+// Open implements Source. An HTTPSource can only be opened once.
+func (s *HTTPSource) Open(ctx context.Context) (<-chan interface{}, error) {
+	ch := make(chan interface{})
+	s.mu.Lock()
+	s.ch, s.done = ch, ctx.Done()
+	s.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.mu.Lock()
+		s.closed = true
+		close(ch)
+		s.mu.Unlock()
+	}()
+	return ch, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *HTTPSource) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	maxSize := s.MaxBodySize
+	if maxSize <= 0 {
+		maxSize = defaultMaxRecordSize
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	var obj interface{} = body
+	if s.Decode != nil {
+		if obj, err = s.Decode(r, body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.ch == nil || s.closed {
+		http.Error(w, "not accepting requests", http.StatusServiceUnavailable)
+		return
+	}
+	timer := time.NewTimer(s.Wait)
+	defer timer.Stop()
+	select {
+	case s.ch <- obj:
+		w.WriteHeader(http.StatusAccepted)
+		return
+	default:
+	}
+	select {
+	case s.ch <- obj:
+		w.WriteHeader(http.StatusAccepted)
+	case <-timer.C:
+		retryAfter := s.RetryAfter
+		if retryAfter <= 0 {
+			retryAfter = time.Second
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(int((retryAfter+time.Second-1)/time.Second)))
+		http.Error(w, "pipeline saturated", http.StatusTooManyRequests)
+	case <-s.done:
+		http.Error(w, "not accepting requests", http.StatusServiceUnavailable)
+	case <-r.Context().Done():
+	}
+}
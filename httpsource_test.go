@@ -0,0 +1,48 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+func ExampleHTTPSource() {
+	ctx, cancel := context.WithCancel(context.Background())
+	src := pipeline.NewHTTPSource()
+	src.Wait = 50 * time.Millisecond
+	in, _ := src.Open(ctx)
+
+	post := func(body string) {
+		w := httptest.NewRecorder()
+		src.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+		line := fmt.Sprint(body, " ", w.Code)
+		if retryAfter := w.Header().Get("Retry-After"); retryAfter != "" {
+			line += " retry after " + retryAfter + "s"
+		}
+		fmt.Println(line)
+	}
+
+	// nothing reads the channel yet
+	post("first")
+
+	received := make(chan interface{})
+	go func() {
+		received <- <-in
+	}()
+	post("second")
+	fmt.Printf("%s\n", <-received)
+
+	cancel()
+	for range in {
+	}
+	post("third")
+
+	// Output: first 429 retry after 1s
+	// second 202
+	// second
+	// third 503
+}
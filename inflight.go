@@ -0,0 +1,128 @@
+package pipeline
+
+// SetMaxInFlight bounds the number of objects in flight in every run of the
+// pipeline, across all its stages, giving a predictable memory usage however
+// many stages and buffers there are. Objects are only read from the input of
+// a run while fewer than n are in flight, i.e. haven't come out of the last
+// stage, been dropped or failed. Zero removes the bound.
+//
+// Raw stages may drop or emit objects freely: the objects they hold are not
+// counted.
+func (p *Pipeline) SetMaxInFlight(n int) {
+	p.checkMutable()
+	p.maxInFlight = n
+}
+
+// acquireAll makes the objects of inChan acquire a permit before entering the
+// run.
+func (run *runState) acquireAll(inChan <-chan interface{}) <-chan interface{} {
+	outChan := make(chan interface{})
+	go func() {
+		defer close(outChan)
+		for {
+			select {
+			case run.permits <- struct{}{}:
+			case <-run.done:
+				return
+			}
+
+			var obj interface{}
+			select {
+			case o, ok := <-inChan:
+				if !ok {
+					<-run.permits
+					return
+				}
+				obj = o
+			case <-run.done:
+				return
+			}
+
+			select {
+			case outChan <- obj:
+			case <-run.done:
+				return
+			}
+		}
+	}()
+	return outChan
+}
+
+// exit settles an object leaving the run, successfully if err is nil, and
+// releases its permit.
+func (run *runState) exit(obj interface{}, err error) {
+	// settling recycles the envelope
+	run.observe(obj)
+	run.leave()
+	settle(obj, err)
+}
+
+// observe records the latency of an object leaving the run. It must be called
+// while the object is still held by the run, as the envelope may be recycled
+// as soon as it is passed on or settled.
+func (run *runState) observe(obj interface{}) {
+	if run.shedder != nil {
+		run.shedder.observe(obj)
+	}
+}
+
+// leave accounts for an object leaving the run, once observed.
+func (run *runState) leave() {
+	run.release()
+	if run.progress != nil {
+		run.progress.exited()
+	}
+}
+
+// release releases the permit of an object leaving the run.
+func (run *runState) release() {
+	if run.permits != nil {
+		<-run.permits
+	}
+}
+
+// limitRaw releases the permits of the objects going into a raw stage and
+// acquires new ones for the objects coming out of it.
+func (run *runState) limitRaw(raw StageFn) StageFn {
+	return func(inChan <-chan interface{}) (outChan chan interface{}) {
+		released := make(chan interface{})
+		go func() {
+			defer close(released)
+			for {
+				select {
+				case obj, ok := <-inChan:
+					if !ok {
+						return
+					}
+					run.release()
+					select {
+					case released <- obj:
+					case <-run.done:
+						return
+					}
+				case <-run.done:
+					return
+				}
+			}
+		}()
+
+		rawOut := raw(released)
+		outChan = make(chan interface{})
+		go func() {
+			defer close(outChan)
+			for obj := range rawOut {
+				select {
+				case run.permits <- struct{}{}:
+				case <-run.done:
+					return
+				}
+				select {
+				case outChan <- obj:
+				case <-run.done:
+					return
+				}
+			}
+		}()
+		return
+	}
+}
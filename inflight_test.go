@@ -0,0 +1,44 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"sync"
+	"time"
+)
+
+func ExamplePipeline_SetMaxInFlight() {
+	var mu sync.Mutex
+	var inFlight, maxInFlight int
+	enter := func(inObj interface{}) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		if inFlight++; inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		return inObj
+	}
+	leave := func(inObj interface{}) interface{} {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		inFlight--
+		return inObj
+	}
+
+	p := pipeline.New()
+	p.SetMaxInFlight(3)
+	p.AddStageWithFanOut(enter, 8)
+	p.AddStageWithFanOut(squareStage, 8)
+	p.AddStageWithFanOut(leave, 8)
+
+	in := make(chan interface{}, 50)
+	for i := 0; i < 50; i++ {
+		in <- i
+	}
+	close(in)
+	<-p.Run(in)
+	fmt.Println(maxInFlight <= 3)
+
+	// Output: true
+}
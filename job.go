@@ -0,0 +1,207 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Source produces the objects fed into a pipeline by a Job.
+type Source interface {
+	// Open starts producing objects on the returned channel, which the
+	// source closes once it is exhausted or ctx is done. Sources that can
+	// fail while producing objects report it with an Err() error method,
+	// called once the channel is closed.
+	Open(ctx context.Context) (<-chan interface{}, error)
+}
+
+// SourceFunc adapts an ordinary function to the Source interface.
+type SourceFunc func(ctx context.Context) (<-chan interface{}, error)
+
+// Open calls f(ctx).
+func (f SourceFunc) Open(ctx context.Context) (<-chan interface{}, error) {
+	return f(ctx)
+}
+
+// Job bundles everything a typical ETL service wires around a pipeline: where
+// the objects come from, where they go, checkpointing, metrics and how to
+// shut down. Only Source and Pipeline are required:
+//
+//	job := &pipeline.Job{
+//		Name:               "orders",
+//		Source:             ordersSource,
+//		Pipeline:           &p,
+//		Sink:               warehouse,
+//		SinkFanSize:        8,
+//		Checkpointer:       checkpointer,
+//		CheckpointInterval: 10 * time.Second,
+//		DrainTimeout:       30 * time.Second,
+//	}
+//	err := job.Start(ctx)
+type Job struct {
+	// Name labels the runs of the job with "job" and, if PublishMetrics is
+	// set, is the expvar name of its Stats.
+	Name           string
+	PublishMetrics bool
+
+	Source   Source
+	Pipeline *Pipeline
+
+	// Sink, if set, is added as the last stage of the pipeline, see
+	// AddSink, with SinkFanSize goroutines (one if zero).
+	Sink        Sink
+	SinkFanSize uint64
+
+	// Checkpointer, if set, is flushed every CheckpointInterval while the
+	// job runs and once the sinks are flushed at the end. The source is
+	// expected to track its objects with it.
+	Checkpointer       *Checkpointer
+	CheckpointInterval time.Duration
+
+	// DrainTimeout bounds the time given to the objects in flight to get
+	// through the pipeline once the context of Start is done; the run is
+	// aborted when it elapses. Zero waits until the pipeline is drained.
+	DrainTimeout time.Duration
+
+	// FlushTimeout bounds the time given to the sinks to flush their
+	// buffers, see Pipeline.Flush. Zero doesn't bound it.
+	FlushTimeout time.Duration
+
+	// Labels label the runs of the job, see WithLabels.
+	Labels Labels
+
+	// Activity is passed on to RunActivity.
+	Activity ActivityOptions
+
+	once   sync.Once
+	mu     sync.Mutex
+	report *Report
+}
+
+// Start runs the job and blocks until it is done: the stages are initialized
+// and preloaded, the source is run through the pipeline until it is
+// exhausted, then the sinks are flushed, the last checkpoint is written and
+// the stages are closed. When ctx is done, the source is no longer read and
+// the objects in flight are drained, see DrainTimeout, and Start returns
+// ctx.Err() if everything else went well.
+//
+// The error is an *ActivityError if the run failed, see RunActivity. A job
+// can only be started once.
+func (j *Job) Start(ctx context.Context) (err error) {
+	first := false
+	j.once.Do(func() {
+		first = true
+	})
+	if !first {
+		return errors.New("pipeline: job already started")
+	}
+	if j.Source == nil || j.Pipeline == nil {
+		return errors.New("pipeline: job needs a source and a pipeline")
+	}
+
+	p := j.Pipeline
+	if j.Sink != nil {
+		fanSize := j.SinkFanSize
+		if fanSize == 0 {
+			fanSize = 1
+		}
+		p.AddSink(j.Sink, fanSize, WithName("sink"))
+	}
+	if j.PublishMetrics {
+		p.PublishExpvar(j.Name)
+	}
+
+	labels := Labels{}
+	if j.Name != "" {
+		labels["job"] = j.Name
+	}
+	for k, v := range j.Labels {
+		labels[k] = v
+	}
+	runCtx, cancel := context.WithCancel(WithLabels(context.Background(), labels))
+	defer cancel()
+
+	if err = p.Init(runCtx); err != nil {
+		return
+	}
+	defer func() {
+		if cerr := p.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	if err = p.Preload(ctx); err != nil {
+		return
+	}
+
+	in, err := j.Source.Open(ctx)
+	if err != nil {
+		return fmt.Errorf("pipeline: open source: %v", err)
+	}
+	var stopCheckpoints func() error
+	if j.Checkpointer != nil && p.events != nil {
+		j.Checkpointer.PublishTo(p.events)
+	}
+	if j.Checkpointer != nil && j.CheckpointInterval > 0 {
+		stopCheckpoints = j.Checkpointer.Start(j.CheckpointInterval, nil)
+	}
+
+	runDone := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-runDone:
+			return
+		}
+		if j.DrainTimeout > 0 {
+			timer := clockOrSystem(p.clock).NewTimer(j.DrainTimeout)
+			defer timer.Stop()
+			select {
+			case <-timer.C():
+				cancel()
+			case <-runDone:
+			}
+		}
+	}()
+	report, err := p.RunActivity(runCtx, untilClosed(in, ctx.Done()), j.Activity)
+	close(runDone)
+	j.mu.Lock()
+	j.report = report
+	j.mu.Unlock()
+
+	flushCtx := context.Background()
+	if j.FlushTimeout > 0 {
+		var cancelFlush context.CancelFunc
+		flushCtx, cancelFlush = context.WithTimeout(flushCtx, j.FlushTimeout)
+		defer cancelFlush()
+	}
+	if ferr := p.Flush(flushCtx); ferr != nil && err == nil {
+		err = ferr
+	}
+	if stopCheckpoints != nil {
+		if cerr := stopCheckpoints(); cerr != nil && err == nil {
+			err = fmt.Errorf("pipeline: checkpoint: %v", cerr)
+		}
+	} else if j.Checkpointer != nil {
+		if cerr := j.Checkpointer.Flush(); cerr != nil && err == nil {
+			err = fmt.Errorf("pipeline: checkpoint: %v", cerr)
+		}
+	}
+	if s, ok := j.Source.(interface{ Err() error }); ok && err == nil {
+		if serr := s.Err(); serr != nil {
+			err = fmt.Errorf("pipeline: source: %v", serr)
+		}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	return
+}
+
+// Report returns the Report of the run of the job, nil until it is done.
+func (j *Job) Report() *Report {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.report
+}
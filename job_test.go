@@ -0,0 +1,36 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExampleJob() {
+	p := pipeline.New()
+	p.AddStage(squareStage, pipeline.WithName("square"))
+
+	job := &pipeline.Job{
+		Name: "squares",
+		Source: pipeline.SourceFunc(func(ctx context.Context) (<-chan interface{}, error) {
+			out := make(chan interface{}, 3)
+			out <- 1
+			out <- 2
+			out <- 3
+			close(out)
+			return out, nil
+		}),
+		Pipeline: &p,
+		Sink: pipeline.SinkFunc(func(obj interface{}) error {
+			fmt.Println(obj)
+			return nil
+		}),
+	}
+	err := job.Start(context.Background())
+	fmt.Println(err, job.Report().Status, job.Report().Labels["job"])
+
+	// Output: 1
+	// 4
+	// 9
+	// <nil> succeeded squares
+}
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// JSONLinesSink is a Sink writing every object as a line of JSON, to a single
+// writer or to a series of files rotated by size or age, see Rotation. It is
+// safe for concurrent use.
+//
+// Lines are buffered: JSONLinesSink is Flushable, so Pipeline.Flush writes
+// them out, and Close flushes them and closes the current file.
+type JSONLinesSink struct {
+	Rotation
+
+	mu    sync.Mutex
+	files rotatingFiles
+}
+
+// NewJSONLinesSink creates a JSONLinesSink writing lines to w, without
+// rotation.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{files: rotatingFiles{w: w}}
+}
+
+// NewJSONLinesFileSink creates a JSONLinesSink writing to files named after
+// pattern, a fmt format given the sequence number of the file, starting at 0:
+// e.g. "out/events-%04d.jsonl". Files are created as the sink rotates,
+// skipping the sequence numbers of the existing ones rather than overwriting
+// them.
+func NewJSONLinesFileSink(pattern string) *JSONLinesSink {
+	return &JSONLinesSink{files: rotatingFiles{pattern: pattern}}
+}
+
+// Write implements Sink. Objects are marshaled with encoding/json.
+func (s *JSONLinesSink) Write(obj interface{}) error {
+	line, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.files.write(line, s.Rotation)
+}
+
+// Flush implements Flushable, writing out the buffered lines.
+func (s *JSONLinesSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.files.flush(s.Rotation)
+}
+
+// Close flushes the buffered lines and closes the current file. The writer
+// given to NewJSONLinesSink is not closed. A file sink writing after Close
+// starts a new file.
+func (s *JSONLinesSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.files.close(s.Rotation)
+}
@@ -0,0 +1,64 @@
+package pipeline_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func ExampleNewJSONLinesFileSink() {
+	dir, _ := ioutil.TempDir("", "jsonl")
+	defer os.RemoveAll(dir)
+
+	sink := pipeline.NewJSONLinesFileSink(filepath.Join(dir, "events-%02d.jsonl"))
+	sink.MaxSize = 40 // bytes
+	sink.Compress = true
+	sink.Sync = pipeline.SyncOnClose
+	for _, user := range []string{"ada", "grace", "linus"} {
+		sink.Write(map[string]string{"event": "login", "user": user})
+	}
+	sink.Close()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for _, file := range files {
+		f, _ := os.Open(file)
+		zr, _ := gzip.NewReader(f)
+		data, _ := ioutil.ReadAll(zr)
+		f.Close()
+		fmt.Printf("%s:\n%s", filepath.Base(file), data)
+	}
+
+	// Output: events-00.jsonl.gz:
+	// {"event":"login","user":"ada"}
+	// events-01.jsonl.gz:
+	// {"event":"login","user":"grace"}
+	// events-02.jsonl.gz:
+	// {"event":"login","user":"linus"}
+}
+
+func ExampleNewJSONLinesFileSink_restart() {
+	dir, _ := ioutil.TempDir("", "jsonl")
+	defer os.RemoveAll(dir)
+	pattern := filepath.Join(dir, "events-%02d.jsonl")
+
+	// the sink of a restarted process carries on after the existing files
+	for _, user := range []string{"ada", "grace"} {
+		sink := pipeline.NewJSONLinesFileSink(pattern)
+		sink.Write(map[string]string{"event": "login", "user": user})
+		sink.Close()
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for _, file := range files {
+		data, _ := ioutil.ReadFile(file)
+		fmt.Printf("%s:\n%s", filepath.Base(file), data)
+	}
+
+	// Output: events-00.jsonl:
+	// {"event":"login","user":"ada"}
+	// events-01.jsonl:
+	// {"event":"login","user":"grace"}
+}
@@ -0,0 +1,35 @@
+package pipeline
+
+import (
+	"context"
+)
+
+// Labels are arbitrary key-value pairs describing a run, such as the team
+// owning it, the dataset it processes or its environment, e.g. to attribute
+// the cost of shared pipelines.
+type Labels map[string]string
+
+type labelsKey struct{}
+
+// WithLabels returns a context labeling the runs started with it, see
+// RunContext. The labels are added to the ones ctx may already carry.
+//
+// The labels of a run are attached to its log records, under a "labels"
+// group, and to its Report.
+func WithLabels(ctx context.Context, labels Labels) context.Context {
+	merged := Labels{}
+	for k, v := range LabelsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, labelsKey{}, merged)
+}
+
+// LabelsFromContext returns the labels carried by ctx, if any. The returned
+// map must not be modified.
+func LabelsFromContext(ctx context.Context) Labels {
+	labels, _ := ctx.Value(labelsKey{}).(Labels)
+	return labels
+}
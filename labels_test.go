@@ -0,0 +1,26 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExampleWithLabels() {
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	p.SetReport(func(r *pipeline.Report) {
+		fmt.Println(r.Labels["team"], r.Labels["dataset"], r.Stages[0].Out)
+	}, 0)
+
+	ctx := pipeline.WithLabels(context.Background(), pipeline.Labels{"team": "search"})
+	ctx = pipeline.WithLabels(ctx, pipeline.Labels{"dataset": "clicks"})
+
+	in := make(chan interface{}, 2)
+	in <- 2
+	in <- 3
+	close(in)
+	<-p.RunContext(ctx, in)
+
+	// Output: search clicks 2
+}
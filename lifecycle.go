@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// Stage is a stage holding resources, such as database connections or write
+// buffers, which are set up before the pipeline runs and released once it is
+// done.
+type Stage interface {
+	// Init is called by Pipeline.Init before any object is processed.
+	Init(ctx context.Context) error
+	// Process is the ProcessFnErr of the stage. It is called concurrently
+	// by the goroutines of the stage, and of all the runs of the pipeline,
+	// so it must be safe for concurrent use.
+	Process(inObj interface{}) (outObj interface{}, err error)
+	// Close is called by Pipeline.Close once all the runs are done, e.g. to
+	// flush buffers and to close connections.
+	Close() error
+}
+
+// AddLifecycleStage adds a Stage with the given fanSize. It otherwise behaves
+// exactly like AddStageErr. The Init and Close methods of the stage are called
+// by the pipeline's Init and Close methods, which its owner must call around
+// the runs, and so is its Preload method, by Pipeline.Preload, if it
+// implements Preloader:
+//
+//	if err := p.Init(ctx); err != nil {
+//		return err
+//	}
+//	defer p.Close()
+//	if err := p.Preload(ctx); err != nil {
+//		return err
+//	}
+//	<-p.Run(inChan)
+func (p *Pipeline) AddLifecycleStage(s Stage, fanSize uint64, opts ...StageOption) {
+	st := &stage{process: s.Process, lifecycle: s, control: newStageControl(fanSize)}
+	if preloader, ok := s.(Preloader); ok {
+		st.preload = preloader.Preload
+	}
+	p.addStage(st, opts...)
+}
+
+// Init initializes the stages added with AddLifecycleStage, in order. If a
+// stage fails to initialize, the stages initialized before it are closed and
+// the error is returned.
+func (p *Pipeline) Init(ctx context.Context) error {
+	stages := p.stageList()
+	for i, s := range stages {
+		if s.lifecycle == nil {
+			continue
+		}
+		if err := s.lifecycle.Init(ctx); err != nil {
+			p.closeStages(stages[:i])
+			return fmt.Errorf("pipeline: init %s: %v", s.name, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the stages added with AddLifecycleStage in reverse order, so
+// that a stage is closed after the stages it feeds. All the stages are closed
+// even if some fail; the first error is returned.
+func (p *Pipeline) Close() error {
+	return p.closeStages(p.stageList())
+}
+
+func (p *Pipeline) closeStages(stages []*stage) (err error) {
+	for i := len(stages) - 1; i >= 0; i-- {
+		s := stages[i]
+		if s.lifecycle == nil {
+			continue
+		}
+		if cerr := s.lifecycle.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("pipeline: close %s: %v", s.name, cerr)
+		}
+	}
+	return
+}
@@ -0,0 +1,61 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+// batchWriter buffers objects and writes them in batches.
+type batchWriter struct {
+	batch []interface{}
+}
+
+func (w *batchWriter) Init(ctx context.Context) error {
+	fmt.Println("connecting")
+	return nil
+}
+
+func (w *batchWriter) Process(inObj interface{}) (interface{}, error) {
+	w.batch = append(w.batch, inObj)
+	if len(w.batch) == 2 {
+		w.flush()
+	}
+	return inObj, nil
+}
+
+func (w *batchWriter) Close() error {
+	w.flush()
+	fmt.Println("disconnecting")
+	return nil
+}
+
+func (w *batchWriter) flush() {
+	if len(w.batch) > 0 {
+		fmt.Println("writing", w.batch)
+		w.batch = nil
+	}
+}
+
+func ExamplePipeline_AddLifecycleStage() {
+	p := pipeline.New()
+	p.AddLifecycleStage(&batchWriter{}, 1)
+
+	if err := p.Init(context.Background()); err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer p.Close()
+
+	in := make(chan interface{}, 3)
+	in <- 1
+	in <- 2
+	in <- 3
+	close(in)
+	<-p.Run(in)
+
+	// Output: connecting
+	// writing [1 2]
+	// writing [3]
+	// disconnecting
+}
@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Limiter bounds the number of concurrent operations on a dependency shared
+// by several stages, possibly of different pipelines, such as a database
+// primary: together they don't overload it, however their fan sizes add up.
+type Limiter struct {
+	name  string
+	slots chan struct{}
+}
+
+var limiters = struct {
+	sync.Mutex
+	byName map[string]*Limiter
+}{byName: map[string]*Limiter{}}
+
+// RegisterLimiter registers a process-wide Limiter allowing up to limit
+// concurrent operations, e.g. RegisterLimiter("postgres-primary", 64). If a
+// limiter with that name is already registered it is returned as is, so that
+// every pipeline can register the limiters it uses.
+func RegisterLimiter(name string, limit int) *Limiter {
+	limiters.Lock()
+	defer limiters.Unlock()
+	if l, ok := limiters.byName[name]; ok {
+		return l
+	}
+	if limit < 1 {
+		limit = 1
+	}
+	l := &Limiter{name: name, slots: make(chan struct{}, limit)}
+	limiters.byName[name] = l
+	return l
+}
+
+// LookupLimiter returns the Limiter registered with the given name.
+func LookupLimiter(name string) (*Limiter, bool) {
+	limiters.Lock()
+	defer limiters.Unlock()
+	l, ok := limiters.byName[name]
+	return l, ok
+}
+
+// Limiters returns the names of the registered limiters, sorted.
+func Limiters() (names []string) {
+	limiters.Lock()
+	defer limiters.Unlock()
+	for name := range limiters.byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+// WithLimiter is a StageOption making every call of the ProcessFn of a stage
+// take a slot of the named Limiter, waiting for one to be free. Retries take a
+// slot per attempt. Raw stages are unaffected. It panics if no limiter with
+// that name is registered.
+func WithLimiter(name string) StageOption {
+	l, ok := LookupLimiter(name)
+	if !ok {
+		panic(fmt.Sprintf("pipeline: no limiter named %q", name))
+	}
+	return func(s *stage) {
+		s.limiter = l
+	}
+}
+
+// Name returns the name of the limiter.
+func (l *Limiter) Name() string {
+	return l.name
+}
+
+// Limit returns the maximum number of concurrent operations.
+func (l *Limiter) Limit() int {
+	return cap(l.slots)
+}
+
+// InUse returns the number of operations in progress.
+func (l *Limiter) InUse() int {
+	return len(l.slots)
+}
+
+// Acquire waits for a free slot, giving up and returning false once done is
+// closed. Each successful Acquire must be followed by a Release.
+func (l *Limiter) Acquire(done <-chan struct{}) bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// Release frees a slot taken with Acquire.
+func (l *Limiter) Release() {
+	<-l.slots
+}
+
+// errAborted fails the objects whose run was aborted while they waited.
+var errAborted = errors.New("pipeline: run aborted")
+
+// wrap makes fn take a slot for every call.
+func (l *Limiter) wrap(fn ProcessFnErr, done <-chan struct{}) ProcessFnErr {
+	return func(inObj interface{}) (interface{}, error) {
+		if !l.Acquire(done) {
+			return nil, errAborted
+		}
+		defer l.Release()
+		return fn(inObj)
+	}
+}
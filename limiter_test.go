@@ -0,0 +1,49 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"sync"
+	"time"
+)
+
+func ExampleRegisterLimiter() {
+	pipeline.RegisterLimiter("postgres-primary", 2)
+
+	var mu sync.Mutex
+	inUse, maxInUse := 0, 0
+	query := func(inObj interface{}) interface{} {
+		mu.Lock()
+		inUse++
+		if inUse > maxInUse {
+			maxInUse = inUse
+		}
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		inUse--
+		mu.Unlock()
+		return inObj
+	}
+
+	// two pipelines with 4 goroutines each querying the same database
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		p := pipeline.New()
+		p.AddStageWithFanOut(query, 4, pipeline.WithLimiter("postgres-primary"))
+		in := make(chan interface{}, 20)
+		for j := 0; j < 20; j++ {
+			in <- j
+		}
+		close(in)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-p.Run(in)
+		}()
+	}
+	wg.Wait()
+	fmt.Println("max concurrent queries:", maxInUse)
+
+	// Output: max concurrent queries: 2
+}
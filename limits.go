@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RunLimits guard shared infrastructure against runaway runs, such as a
+// pipeline accidentally fed an infinite source.
+type RunLimits struct {
+	MaxDuration time.Duration // zero means no limit
+	MaxObjects  uint64        // objects read from the input, zero means no limit
+
+	// OnExceeded, if set, is called with the error of every run aborted for
+	// exceeding a limit, once its stages have stopped.
+	OnExceeded func(*RunLimitError)
+}
+
+// RunLimitError describes a run aborted for exceeding its limits.
+type RunLimitError struct {
+	Limit string // "duration" or "objects"
+	Max   string // the limit that was exceeded
+
+	// Stats are the counters of the run when it stopped.
+	Stats Stats
+}
+
+func (e *RunLimitError) Error() string {
+	return fmt.Sprintf("pipeline: run aborted after exceeding its %s limit of %s", e.Limit, e.Max)
+}
+
+// SetRunLimits sets limits aborting the runs of the pipeline that exceed
+// them, as if their context was canceled. The run's Report, if any, carries
+// the error.
+func (p *Pipeline) SetRunLimits(limits RunLimits) {
+	p.checkMutable()
+	p.limits = &limits
+}
+
+// runLimiter enforces the limits of a single run.
+type runLimiter struct {
+	p      *Pipeline
+	limits RunLimits
+	before Stats
+	cancel context.CancelFunc
+	timer  Timer
+
+	mu       sync.Mutex
+	exceeded *RunLimitError
+}
+
+// applyLimits derives the context of a run from ctx so that it is canceled
+// when the run exceeds the limits of the pipeline, if any.
+func (p *Pipeline) applyLimits(ctx context.Context, run *runState, inChan <-chan interface{}) (context.Context, <-chan interface{}) {
+	if p.limits == nil {
+		return ctx, inChan
+	}
+	l := &runLimiter{p: p, limits: *p.limits, before: p.Stats()}
+	ctx, l.cancel = context.WithCancel(ctx)
+	run.limits = l
+
+	if max := l.limits.MaxDuration; max > 0 {
+		l.timer = run.clock.AfterFunc(max, func() {
+			l.exceed("duration", max.String())
+		})
+	}
+	if max := l.limits.MaxObjects; max > 0 {
+		inChan = l.countObjects(inChan, ctx.Done())
+	}
+	return ctx, inChan
+}
+
+// countObjects forwards inChan, exceeding the limit when more than MaxObjects
+// come in.
+func (l *runLimiter) countObjects(inChan <-chan interface{}, done <-chan struct{}) <-chan interface{} {
+	outChan := make(chan interface{})
+	go func() {
+		defer close(outChan)
+		var n uint64
+		for {
+			select {
+			case obj, ok := <-inChan:
+				if !ok {
+					return
+				}
+				if n++; n > l.limits.MaxObjects {
+					l.exceed("objects", fmt.Sprint(l.limits.MaxObjects))
+					return
+				}
+				select {
+				case outChan <- obj:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return outChan
+}
+
+func (l *runLimiter) exceed(limit, max string) {
+	l.mu.Lock()
+	if l.exceeded == nil {
+		l.exceeded = &RunLimitError{Limit: limit, Max: max}
+	}
+	l.mu.Unlock()
+	l.cancel()
+}
+
+// finish releases the resources of the limiter once the stages of the run
+// have stopped, and returns the error of the run if it exceeded a limit.
+func (l *runLimiter) finish() error {
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.cancel()
+
+	l.mu.Lock()
+	err := l.exceeded
+	l.mu.Unlock()
+	if err == nil {
+		return nil
+	}
+	err.Stats = l.p.Stats().Sub(l.before)
+	if l.limits.OnExceeded != nil {
+		l.limits.OnExceeded(err)
+	}
+	return err
+}
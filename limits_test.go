@@ -0,0 +1,30 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_SetRunLimits() {
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	p.SetRunLimits(pipeline.RunLimits{
+		MaxObjects: 3,
+		OnExceeded: func(err *pipeline.RunLimitError) {
+			fmt.Println(err)
+			fmt.Println("read:", err.Stats.Stages[0].In)
+		},
+	})
+
+	// an endless source
+	in := make(chan interface{})
+	go func() {
+		for i := 0; ; i++ {
+			in <- i
+		}
+	}()
+	<-p.Run(in)
+
+	// Output: pipeline: run aborted after exceeding its objects limit of 3
+	// read: 3
+}
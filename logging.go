@@ -0,0 +1,43 @@
+package pipeline
+
+import (
+	"time"
+)
+
+// stageLogger receives the lifecycle events of the stages of a pipeline. It is
+// implemented on top of log/slog by SetLogger.
+type stageLogger interface {
+	stageStarted(stage string, fanSize uint64)
+	stageStopped(stage string)
+	workerPanicked(stage string, recovered interface{})
+	dropped(stage string)
+	itemFailed(stage string, err error)
+	slowItem(stage string, d time.Duration)
+	slowThreshold() time.Duration
+	withLabels(labels Labels) stageLogger
+}
+
+// logProcessFn wraps a ProcessFnErr so that drops, panics and slow objects
+// are reported to the logger. Panics are logged and then propagated as usual.
+// Failures are reported by the pipeline's error handling.
+func logProcessFn(l stageLogger, name string, fn ProcessFnErr) ProcessFnErr {
+	threshold := l.slowThreshold()
+	return func(inObj interface{}) (outObj interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				l.workerPanicked(name, r)
+				panic(r)
+			}
+		}()
+
+		start := time.Now()
+		outObj, err = fn(inObj)
+		if d := time.Since(start); threshold > 0 && d >= threshold {
+			l.slowItem(name, d)
+		}
+		if outObj == nil && err == nil {
+			l.dropped(name)
+		}
+		return
+	}
+}
@@ -0,0 +1,45 @@
+package pipeline
+
+import "fmt"
+
+// AddUnmarshalStage adds a stage decoding serialized objects with codec, such
+// as the protobuf messages of a gRPC or Kafka system. Each []byte (or string)
+// object is unmarshaled into a new object returned by factory, typically a
+// pointer to a message, which is passed on:
+//
+//	p.AddUnmarshalStage(protoCodec{}, func() interface{} { return new(orderpb.Order) }, 4)
+//
+// See Codec for a protobuf codec. Objects that can't be decoded fail and are
+// sent to the dead-letter function. Streams of length-delimited messages,
+// as written by protobuf's delimited writers, are split with
+// NewRecordSource. See AddStageWithFanOut for the meaning of fanSize and
+// opts.
+func (p *Pipeline) AddUnmarshalStage(codec Codec, factory func() interface{}, fanSize uint64, opts ...StageOption) {
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		var data []byte
+		switch in := inObj.(type) {
+		case []byte:
+			data = in
+		case string:
+			data = []byte(in)
+		default:
+			return nil, fmt.Errorf("pipeline: can't unmarshal %T", inObj)
+		}
+		obj := factory()
+		if err := codec.Unmarshal(data, obj); err != nil {
+			return nil, err
+		}
+		return obj, nil
+	}, fanSize, opts...)
+}
+
+// AddMarshalStage adds a stage encoding objects with codec into []byte, e.g.
+// to publish them as protobuf messages. Objects that can't be encoded fail
+// and are sent to the dead-letter function. The encoded objects can be
+// written as a length-delimited stream with NewRecordSink. See
+// AddStageWithFanOut for the meaning of fanSize and opts.
+func (p *Pipeline) AddMarshalStage(codec Codec, fanSize uint64, opts ...StageOption) {
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		return codec.Marshal(inObj)
+	}, fanSize, append([]StageOption{WithTypes(nil, bytesType)}, opts...)...)
+}
@@ -0,0 +1,48 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+type reading struct {
+	Sensor  string
+	Celsius float64
+}
+
+func ExamplePipeline_AddUnmarshalStage() {
+	// a length-delimited stream of readings, as protobuf's delimited
+	// writers produce, here with the JSON codec
+	var stream bytes.Buffer
+	codec, _ := pipeline.LookupCodec("json")
+	sink := pipeline.NewRecordSink(&stream, 0)
+	for _, r := range []reading{{"kitchen", 21.5}, {"garage", 12}} {
+		data, _ := codec.Marshal(r)
+		sink.Write(data)
+	}
+
+	var out bytes.Buffer
+	p := pipeline.New()
+	p.AddUnmarshalStage(codec, func() interface{} { return new(reading) }, 1)
+	p.AddStage(func(inObj interface{}) interface{} {
+		r := inObj.(*reading)
+		return map[string]interface{}{"sensor": r.Sensor, "fahrenheit": r.Celsius*9/5 + 32}
+	})
+	p.AddMarshalStage(codec, 1)
+	p.AddSink(pipeline.NewRecordSink(&out, 0), 1)
+
+	src := pipeline.NewRecordSource(&stream)
+	in, _ := src.Open(context.Background())
+	<-p.Run(in)
+
+	converted := pipeline.NewRecordSource(&out)
+	records, _ := converted.Open(context.Background())
+	for record := range records {
+		fmt.Printf("%s\n", record)
+	}
+
+	// Output: {"fahrenheit":70.7,"sensor":"kitchen"}
+	// {"fahrenheit":53.6,"sensor":"garage"}
+}
@@ -0,0 +1,29 @@
+//go:build go1.18
+// +build go1.18
+
+package pipeline
+
+import "sync"
+
+// Merge merges typed channels into a single channel, closed once they all
+// are. It is MergeAll for code working with typed channels rather than with
+// pipelines.
+func Merge[T any](chans ...<-chan T) <-chan T {
+	out := make(chan T)
+	var wg sync.WaitGroup
+	wg.Add(len(chans))
+	for _, ch := range chans {
+		go func(ch <-chan T) {
+			defer wg.Done()
+			for v := range ch {
+				out <- v
+			}
+		}(ch)
+	}
+
+	go func() {
+		defer close(out)
+		wg.Wait()
+	}()
+	return out
+}
@@ -0,0 +1,34 @@
+//go:build go1.18
+// +build go1.18
+
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"sort"
+)
+
+func ExampleMerge() {
+	produce := func(ints ...int) <-chan int {
+		ch := make(chan int)
+		go func() {
+			defer close(ch)
+			for _, i := range ints {
+				ch <- i
+			}
+		}()
+		return ch
+	}
+
+	var sum int
+	var all []int
+	for i := range pipeline.Merge(produce(1, 2, 3), produce(40, 50)) {
+		sum += i
+		all = append(all, i)
+	}
+	sort.Ints(all)
+	fmt.Println(all, sum)
+
+	// Output: [1 2 3 40 50] 96
+}
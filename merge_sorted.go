@@ -0,0 +1,68 @@
+package pipeline
+
+import "container/heap"
+
+// MergeSorted merges channels whose objects are sorted according to less into
+// a single sorted channel, e.g. to combine the results of pipelines run over
+// pre-sorted shards. It holds one object per channel, so it only sends an
+// object once every channel sent its next one or was closed, and a channel
+// that stalls stalls the merge. The returned channel is closed once all the
+// channels are.
+func MergeSorted(less func(a, b interface{}) bool, inChans ...<-chan interface{}) (outChan chan interface{}) {
+	outChan = make(chan interface{})
+	go func() {
+		defer close(outChan)
+		h := &sortedHeads{less: less}
+		for i, ch := range inChans {
+			if obj, ok := <-ch; ok {
+				h.heads = append(h.heads, sortedHead{obj: obj, ch: ch, index: i})
+			}
+		}
+		heap.Init(h)
+		for h.Len() > 0 {
+			head := &h.heads[0]
+			outChan <- head.obj
+			if obj, ok := <-head.ch; ok {
+				head.obj = obj
+				heap.Fix(h, 0)
+			} else {
+				heap.Pop(h)
+			}
+		}
+	}()
+	return
+}
+
+// sortedHead is the next object of a channel merged by MergeSorted.
+type sortedHead struct {
+	obj   interface{}
+	ch    <-chan interface{}
+	index int // of the channel, so that ties go to the channel given first
+}
+
+// sortedHeads implements heap.Interface.
+type sortedHeads struct {
+	heads []sortedHead
+	less  func(a, b interface{}) bool
+}
+
+func (h *sortedHeads) Len() int { return len(h.heads) }
+
+func (h *sortedHeads) Less(i, j int) bool {
+	a, b := h.heads[i], h.heads[j]
+	if h.less(a.obj, b.obj) {
+		return true
+	}
+	return !h.less(b.obj, a.obj) && a.index < b.index
+}
+
+func (h *sortedHeads) Swap(i, j int) { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+
+func (h *sortedHeads) Push(x interface{}) { h.heads = append(h.heads, x.(sortedHead)) }
+
+func (h *sortedHeads) Pop() interface{} {
+	last := h.heads[len(h.heads)-1]
+	h.heads[len(h.heads)-1] = sortedHead{}
+	h.heads = h.heads[:len(h.heads)-1]
+	return last
+}
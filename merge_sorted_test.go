@@ -0,0 +1,26 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExampleMergeSorted() {
+	shard := func(values ...int) <-chan interface{} {
+		ch := make(chan interface{}, len(values))
+		for _, v := range values {
+			ch <- v
+		}
+		close(ch)
+		return ch
+	}
+
+	less := func(a, b interface{}) bool { return a.(int) < b.(int) }
+	var merged []interface{}
+	for obj := range pipeline.MergeSorted(less, shard(1, 4, 9), shard(2, 3, 10, 11), shard(), shard(5)) {
+		merged = append(merged, obj)
+	}
+	fmt.Println(merged)
+
+	// Output: [1 2 3 4 5 9 10 11]
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+// Middleware wraps a ProcessFn with cross-cutting behavior such as timing,
+// logging, retries or authorization, without modifying the ProcessFn itself.
+type Middleware func(next ProcessFn) ProcessFn
+
+// StageOption customizes a single stage when it is added to a pipeline.
+type StageOption func(*stage)
+
+// WithName is a StageOption naming a stage, which otherwise gets a name based
+// on its position such as "stage0". Names identify the stages in Stats, logs,
+// traces and the other APIs taking a stage name, and should be unique.
+func WithName(name string) StageOption {
+	return func(s *stage) {
+		s.name = name
+	}
+}
+
+// Use adds middleware wrapping the ProcessFn of every stage of the pipeline,
+// including the stages added after the call. Raw stages aren't wrapped.
+//
+// In stages that can fail, middleware sees failed calls as returning an opaque
+// non-nil object that must be returned as is.
+//
+// Middleware is applied in the order given: the first one is the outermost
+// and sees the object first. Pipeline middleware wraps stage middleware
+// added with WithMiddleware.
+func (p *Pipeline) Use(middleware ...Middleware) {
+	p.checkMutable()
+	p.middleware = append(p.middleware, middleware...)
+}
+
+// WithMiddleware is a StageOption adding middleware to a single stage. See
+// Use for the order in which it is applied.
+func WithMiddleware(middleware ...Middleware) StageOption {
+	return func(s *stage) {
+		s.middleware = append(s.middleware, middleware...)
+	}
+}
+
+// chain wraps fn with the middleware, the first one being the outermost.
+func chain(fn ProcessFn, middleware []Middleware) ProcessFn {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		fn = middleware[i](fn)
+	}
+	return fn
+}
+
+// chainErr is chain for a ProcessFnErr. Errors are carried through the
+// middleware as failedCall objects.
+func chainErr(fn ProcessFnErr, middleware []Middleware) ProcessFnErr {
+	if len(middleware) == 0 {
+		return fn
+	}
+
+	wrapped := chain(func(inObj interface{}) interface{} {
+		outObj, err := fn(inObj)
+		if err != nil {
+			return failedCall{err}
+		}
+		return outObj
+	}, middleware)
+
+	return func(inObj interface{}) (interface{}, error) {
+		outObj := wrapped(inObj)
+		if failed, ok := outObj.(failedCall); ok {
+			return nil, failed.err
+		}
+		return outObj, nil
+	}
+}
+
+type failedCall struct {
+	err error
+}
@@ -0,0 +1,33 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func tag(name string) pipeline.Middleware {
+	return func(next pipeline.ProcessFn) pipeline.ProcessFn {
+		return func(inObj interface{}) interface{} {
+			fmt.Println("enter", name)
+			defer fmt.Println("leave", name)
+			return next(inObj)
+		}
+	}
+}
+
+func ExamplePipeline_Use() {
+	p := pipeline.New()
+	p.Use(tag("outer"))
+	p.AddStage(printStage, pipeline.WithMiddleware(tag("inner")))
+
+	ch := make(chan interface{}, 1)
+	ch <- 42
+	close(ch)
+	<-p.Run(ch)
+
+	// Output: enter outer
+	// enter inner
+	// 42
+	// leave inner
+	// leave outer
+}
@@ -3,7 +3,12 @@
 package pipeline
 
 import (
+	"context"
+	"fmt"
+	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Pipeline type defines a pipeline to which processing "stages" can
@@ -13,7 +18,131 @@ import (
 // A pipeline can be simultaneously run multiple times with different
 // input channels by invoking the Run() method multiple times.
 // A running pipeline shouldn't be copied.
-type Pipeline []StageFn
+//
+// Pipeline used to be a []StageFn. The pipelines made with New and the Add
+// methods work as they did, while the ones made as slices are made with
+// FromStageFns instead, see the upgrade notes of the README.
+type Pipeline struct {
+	stages      []*stage
+	middleware  []Middleware
+	tracer      Tracer
+	logger      stageLogger
+	deadLetter  func(*ItemError)
+	report      *reportConfig
+	progress    *progressConfig
+	limits      *RunLimits
+	accounting  *accountingConfig
+	statsStore  *statsStoreConfig
+	pool        *WorkerPool
+	maxInFlight int
+	shedding    *LoadShedding
+	priorities  int // size of the priority queues, zero if disabled
+	errorPolicy ErrorPolicy
+	sideOutputs map[string]*Pipeline
+	events      *EventBus
+	clock       Clock
+	recorder    *Recorder
+	profiling   bool // see SetProfiling
+	envelopes   bool
+	synchronous bool
+	built       bool // see Build
+
+	runs      map[uint64]*Run // active runs, guarded by runsMu
+	lastRunID uint64
+}
+
+// stage is a single step of a Pipeline along with its bookkeeping. Stages are
+// turned into StageFn functions when the pipeline is run so that pipeline-wide
+// settings apply regardless of the order in which they were made.
+type stage struct {
+	name       string
+	funcName   string       // set for the stages built from a definition
+	process    ProcessFnErr // nil for raw stages and the ones added with AddStageCtx
+	processCtx ProcessFnCtx // set for stages added with AddStageCtx
+	raw        StageFn
+	envelope   bool // whether process takes envelopes rather than payloads
+	middleware []Middleware
+	retry      *retryPolicy
+	breaker    *circuitBreaker
+	lifecycle  Stage            // set for stages added with AddLifecycleStage
+	newWorker  func() Worker    // set for stages added with AddStageWithWorkers
+	weighted   []WeightedWorker // set for stages added with AddWeightedStage
+	flusher    Flushable        // set for the sinks that buffer objects
+	preload    func(ctx context.Context) error
+	scaler     *ioScaler  // set for the stages added with WithIOScaling
+	eventTime  *EventTime // set for the window stages added with WithEventTime
+	limiter    *Limiter
+	adaptive   *adaptiveLimit // set for the stages added with WithAdaptiveConcurrency
+	counters   *counters
+	control    *stageControl
+
+	distribution Distribution
+	inType       reflect.Type // declared with WithTypes, nil if unknown
+	outType      reflect.Type
+
+	// clocked is set for the raw stages measuring time, which are made for
+	// the Clock and the done channel of every run
+	clocked func(clock Clock, done <-chan struct{}) StageFn
+}
+
+// counters are updated atomically by the goroutines of a stage. The uint64
+// fields are kept first in the struct for 64-bit alignment.
+type counters struct {
+	in      uint64
+	out     uint64
+	dropped uint64
+	errors  uint64
+	nanos   uint64 // time spent processing
+
+	deadLettered uint64
+}
+
+// runState is what the stages of a single run share.
+type runState struct {
+	ctx      context.Context
+	done     <-chan struct{} // closed when the run is aborted
+	labels   Labels
+	clock    Clock
+	logger   stageLogger    // the pipeline's logger with the labels of the run
+	samples  *errorSamples  // nil unless the run is reported
+	stages   sync.WaitGroup // the stages that haven't stopped yet
+	permits  chan struct{}  // objects in flight, nil if they aren't limited
+	shedder  *shedder       // nil unless load is shed
+	events   *EventBus
+	report   func(abortErr error)
+	progress *progressTracker // nil unless the pipeline reports progress
+	account  func()           // nil unless the pipeline is strict
+	history  func()           // nil unless the runs are saved, see SetStatsStore
+	limits   *runLimiter
+
+	sides     map[string]chan interface{} // nil unless side outputs are attached
+	sidesDone []chan struct{}
+
+	cancel context.CancelFunc // set if the run fails fast
+	errMu  sync.Mutex
+	errors []*ItemError // collected, or the one the run failed with
+	failed *ItemError
+}
+
+// stageConfig holds everything the goroutines of a running stage need.
+type stageConfig struct {
+	owner      *Pipeline
+	name       string
+	process    ProcessFnErr
+	newProcess func(ctx context.Context) ProcessFnErr // if set, called by every goroutine with its context instead of sharing process
+	counters   *counters
+	control    *stageControl
+	pool       *WorkerPool // nil unless the stage runs on a shared pool
+	run        *runState
+	done       <-chan struct{} // closed when the run is aborted
+	onError    func(inObj interface{}, err error)
+	onDone     func()
+	profiled   bool // the goroutines publish their phase, see SetProfiling
+
+	distribution Distribution
+	weights      []int          // of the goroutines, set for weighted stages
+	processes    []ProcessFnErr // of the goroutines, set for weighted stages
+}
 
 // StageFn is a lower level function type that chains together multiple
 // stages using channels.
@@ -30,8 +159,8 @@ func New() Pipeline {
 
 // AddStage is a convenience method for adding a stage with fanSize = 1.
 // See AddStageWithFanOut for more information.
-func (p *Pipeline) AddStage(inFunc ProcessFn) {
-	*p = append(*p, fanningStageFnFactory(inFunc, 1))
+func (p *Pipeline) AddStage(inFunc ProcessFn, opts ...StageOption) {
+	p.AddStageWithFanOut(inFunc, 1, opts...)
 }
 
 // AddStageWithFanOut adds a parallel fan-out ProcessFn to the pipeline. The
@@ -45,14 +174,43 @@ func (p *Pipeline) AddStage(inFunc ProcessFn) {
 //
 // Since discrete goroutines process the inChan for FanOut > 1, the order of
 // objects flowing through the FanOut stages can't be guaranteed.
-func (p *Pipeline) AddStageWithFanOut(inFunc ProcessFn, fanSize uint64) {
-	*p = append(*p, fanningStageFnFactory(inFunc, fanSize))
+//
+// StageOption values can be passed to customize the stage further.
+func (p *Pipeline) AddStageWithFanOut(inFunc ProcessFn, fanSize uint64, opts ...StageOption) {
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		return inFunc(inObj), nil
+	}, fanSize, opts...)
 }
 
 // AddRawStage simply adds a StageFn type to the pipeline without any further
 // processing or parsing. This is meant for extensibility and customizations.
-func (p *Pipeline) AddRawStage(inFunc StageFn) {
-	*p = append(*p, inFunc)
+//
+// Raw stages are opaque to the pipeline and always report zero counts in Stats.
+// Only the WithName option applies to them. They learn about the abort of the
+// run with RawStageContext.
+func (p *Pipeline) AddRawStage(inFunc StageFn, opts ...StageOption) {
+	p.addStage(&stage{raw: inFunc, control: newStageControl(0)}, opts...)
+}
+
+func (p *Pipeline) addStage(s *stage, opts ...StageOption) {
+	p.checkMutable()
+	stagesMu.Lock()
+	defer stagesMu.Unlock()
+	p.stages = append(p.stages, p.newStage(s, opts))
+}
+
+// newStage applies the options to a stage about to be added, and names it
+// after its position unless one of them does. It must be called with stagesMu
+// held.
+func (p *Pipeline) newStage(s *stage, opts []StageOption) *stage {
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.name == "" {
+		s.name = fmt.Sprint("stage", len(p.stages))
+	}
+	s.counters = new(counters)
+	return s
 }
 
 // Run starts the pipeline with all the stages that have been added. Run is not
@@ -65,60 +223,449 @@ func (p *Pipeline) AddRawStage(inFunc StageFn) {
 // Once all stages are complete, the last outChan is drained and the doneChan is closed.
 //
 // Run() can be invoked multiple times to start multiple instances of a pipeline
-// that will typically process different incoming channels.
+// that will typically process different incoming channels. Start returns a
+// handle telling how the run finished instead.
 func (p *Pipeline) Run(inChan <-chan interface{}) (doneChan chan struct{}) {
-	for _, stage := range *p {
-		inChan = stage(inChan)
+	return p.RunContext(context.Background(), inChan)
+}
+
+// RunContext is like Run but the run is aborted once ctx is done, e.g. when a
+// batch job misses its deadline:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+//	defer cancel()
+//	<-p.RunContext(ctx, inChan)
+//	if ctx.Err() == context.DeadlineExceeded {
+//		// aborted, p.Stats() tells how far the run got
+//	}
+//
+// On abort, the stages stop taking objects and the objects in flight are
+// abandoned; doneChan is closed once all the stages have stopped. The stages
+// stop reading `inChan` too, so its producer must not block on sending forever.
+func (p *Pipeline) RunContext(ctx context.Context, inChan <-chan interface{}) (doneChan chan struct{}) {
+	return p.runContext(ctx, inChan, nil).done
+}
+
+// runContext is StartContext with an extra function receiving the Report of
+// the run, regardless of SetReport.
+func (p *Pipeline) runContext(ctx context.Context, inChan <-chan interface{}, onReport func(*Report)) *Run {
+	ctx, cancel := context.WithCancel(ctx)
+	r := &Run{done: make(chan struct{}), cancel: cancel, p: p, start: time.Now(), before: p.Stats(), stopping: make(chan struct{})}
+	if p.synchronous {
+		p.checkSynchronous()
+		run, inChan := p.newRun(ctx, r.intake(inChan, ctx.Done()), onReport)
+		r.labels, r.state = run.labels, run
+		p.addRun(r)
+		p.runSync(run, inChan)
+		r.finish(run.finish())
+		close(r.done)
+		return r
 	}
+	run, outChan := p.start(ctx, r.intake(inChan, ctx.Done()), onReport)
+	r.labels, r.state = run.labels, run
+	p.addRun(r)
 
-	doneChan = make(chan struct{})
 	go func() {
-		defer close(doneChan)
-		for range inChan {
-			// pull objects from inChan so that the gc marks them
+		defer close(r.done)
+		for obj := range outChan {
+			// pull objects from outChan so that the gc marks them
+			endTrace(obj)
+			run.exit(obj, nil)
 		}
+		r.finish(run.finish())
 	}()
+	return r
+}
+
+// start starts the stages of a run and returns the output of the last one,
+// which must be drained before calling run.finish.
+func (p *Pipeline) start(ctx context.Context, inChan <-chan interface{}, onReport func(*Report)) (run *runState, outChan <-chan interface{}) {
+	run, inChan = p.newRun(ctx, inChan, onReport)
+	// held until the stages are started, so that ReplaceStage and InsertStage
+	// either find them running or change them beforehand
+	stagesMu.RLock()
+	defer stagesMu.RUnlock()
+	for _, s := range p.stages {
+		if p.priorities > 0 && s.raw == nil {
+			inChan = prioritize(inChan, p.priorities, run.done)
+		}
+		inChan = p.stageFn(s, run)(inChan)
+	}
+	return run, inChan
+}
+
+// newRun sets up the state of a run and returns it along with the objects to
+// pass to its first stage.
+func (p *Pipeline) newRun(ctx context.Context, inChan <-chan interface{}, onReport func(*Report)) (run *runState, outChan <-chan interface{}) {
+	run = &runState{labels: LabelsFromContext(ctx), clock: clockOrSystem(p.clock), logger: p.logger, events: p.events}
+	if p.recorder != nil {
+		inChan = p.recorder.intake(inChan, run.clock, ctx.Done())
+	}
+	ctx, inChan = p.applyLimits(ctx, run, inChan)
+	if p.errorPolicy == FailFast {
+		ctx, run.cancel = context.WithCancel(ctx)
+	}
+	run.ctx, run.done = ctx, ctx.Done()
+	if run.logger != nil && len(run.labels) > 0 {
+		run.logger = run.logger.withLabels(run.labels)
+	}
+	run.report = p.startReport(run, onReport)
+	run.progress = p.startProgress(run)
+	run.account = p.startAccounting()
+	run.history = p.startHistory(run)
+	p.startSides(run)
+	run.events.Publish(Event{Type: EventRunStarted, Labels: run.labels})
+	if p.shedding != nil {
+		run.shedder = &shedder{LoadShedding: *p.shedding, p: p, clock: run.clock}
+		inChan = run.shedder.shed(inChan, run.done)
+	}
+	if p.maxInFlight > 0 {
+		run.permits = make(chan struct{}, p.maxInFlight)
+		inChan = run.acquireAll(inChan)
+	}
+	if p.envelopes || p.tracer != nil || p.priorities > 0 {
+		inChan = envelopeIntake(p.tracer, run.clock, run.done)(inChan)
+	}
+	return run, inChan
+}
+
+// finish waits for the stages of a run to stop, which they may still be doing
+// if the run was aborted, and completes its report. It returns the error the
+// run was aborted with, if any.
+func (run *runState) finish() (abortErr error) {
+	run.stages.Wait()
+	run.finishSides()
+	abortErr = run.ctx.Err()
+	if run.limits != nil {
+		if err := run.limits.finish(); err != nil {
+			abortErr = err
+		}
+	}
+	if run.cancel != nil {
+		run.cancel()
+		run.errMu.Lock()
+		if run.failed != nil {
+			abortErr = run.failed
+		}
+		run.errMu.Unlock()
+	}
+	if run.account != nil && abortErr == nil {
+		run.account()
+	}
+	if run.history != nil && abortErr == nil {
+		run.history()
+	}
+	if run.progress != nil {
+		run.progress.finish()
+	}
+	if run.report != nil {
+		run.report(abortErr)
+	}
+	run.events.Publish(Event{Type: EventRunFinished, Labels: run.labels, Err: abortErr})
 	return
 }
 
-// stageFnFactory makes a standard stage function from a given ProcessFn.
-// StageFn functions types accept an inChan and return an outChan, allowing
-// us to chain multiple functions into a pipeline.
-func stageFnFactory(inFunc ProcessFn) (outFunc StageFn) {
+// stageFn builds the StageFn of a stage with the pipeline-wide settings, for
+// the given run.
+func (p *Pipeline) stageFn(s *stage, run *runState) StageFn {
+	if s.raw != nil {
+		raw := s.raw
+		if s.clocked != nil {
+			raw = s.clocked(run.clock, run.done)
+		}
+		raw = withRawStageContext(run.ctx, raw)
+		if run.permits != nil {
+			return run.limitRaw(raw)
+		}
+		return raw
+	}
+
 	return func(inChan <-chan interface{}) (outChan chan interface{}) {
 		outChan = make(chan interface{})
-		go func() {
-			defer close(outChan)
-			for inObj := range inChan {
-				if outObj := inFunc(inObj); outObj != nil {
-					outChan <- outObj
-				}
-			}
-		}()
+		p.startStage(s, run, inChan, outChan)
 		return
 	}
 }
 
-// fanningStageFnFactory makes a stage function that fans into multiple
-// goroutines increasing the stage throughput depending on the CPU.
-func fanningStageFnFactory(inFunc ProcessFn, fanSize uint64) (outFunc StageFn) {
-	return func(inChan <-chan interface{}) (outChan chan interface{}) {
-		var channels []chan interface{}
-		for i := uint64(0); i < fanSize; i++ {
-			channels = append(channels, stageFnFactory(inFunc)(inChan))
+// startStage starts an instance of a stage other than a raw one for the given
+// run, reading inChan and writing outChan.
+func (p *Pipeline) startStage(s *stage, run *runState, inChan <-chan interface{}, outChan chan interface{}) {
+	cfg := p.stageConfig(s, run)
+	l := run.logger
+	cfg.onDone = func() {
+		if l != nil {
+			l.stageStopped(s.name)
 		}
-		outChan = MergeChannels(channels)
-		return
+		run.stages.Done()
+	}
+	run.stages.Add(1)
+	if l != nil {
+		l.stageStarted(s.name, s.control.getFanSize())
+	}
+	startInstance(cfg, inChan, outChan)
+}
+
+// stageConfig builds the configuration of a stage other than a raw one, for
+// the given run.
+func (p *Pipeline) stageConfig(s *stage, run *runState) *stageConfig {
+	cfg := &stageConfig{
+		owner:    p,
+		name:     s.name,
+		counters: s.counters,
+		control:  s.control,
+		pool:     p.pool,
+		run:      run,
+		done:     run.done,
+		profiled: p.profiling && p.pool == nil,
+
+		distribution: s.distribution,
+		onError: func(inObj interface{}, err error) {
+			p.handleError(s, run, inObj, err)
+		},
+	}
+	switch {
+	case s.weighted != nil:
+		cfg.pool = nil
+		cfg.weights = s.weights()
+		for _, w := range s.weighted {
+			cfg.processes = append(cfg.processes, p.wrapProcessFn(s, run, w.Worker.Process))
+		}
+	case s.newWorker != nil:
+		cfg.newProcess = func(context.Context) ProcessFnErr {
+			return p.wrapProcessFn(s, run, s.newWorker().Process)
+		}
+	default:
+		cfg.process = p.wrapProcessFn(s, run, s.processFn(run.ctx))
+		if s.processCtx != nil && cfg.profiled {
+			// every goroutine passes the context carrying its slot, see Phase
+			cfg.newProcess = func(ctx context.Context) ProcessFnErr {
+				return p.wrapProcessFn(s, run, s.processFn(ctx))
+			}
+		}
+	}
+	return cfg
+}
+
+// wrapProcessFn wraps the ProcessFnErr of a stage with the stage and pipeline
+// settings, for the given run.
+func (p *Pipeline) wrapProcessFn(s *stage, run *runState, fn ProcessFnErr) ProcessFnErr {
+	fn = s.control.holdProcessFn(fn, run.clock, run.done)
+	if s.limiter != nil {
+		fn = s.limiter.wrap(fn, run.done)
+	}
+	if s.adaptive != nil {
+		fn = s.adaptive.wrap(fn, run.done)
+	}
+	if s.scaler != nil {
+		fn = s.scaler.count(fn)
+	}
+	if s.retry != nil {
+		fn = s.retry.wrap(fn, run.clock, run.done)
+	}
+	if s.breaker != nil {
+		fn = s.breaker.wrap(fn, run.clock)
+	}
+	fn = chainErr(fn, s.middleware)
+	fn = chainErr(fn, p.middleware)
+	if run.sides != nil {
+		fn = run.routeSides(fn)
+	}
+	if s.envelope {
+		fn = wrapEnvelope(fn, run.clock)
+	} else {
+		fn = unwrapEnvelope(fn)
+	}
+	if p.tracer != nil {
+		fn = traceProcessFn(p.tracer, s.name, fn)
+	}
+	if run.logger != nil {
+		fn = logProcessFn(run.logger, s.name, fn)
+	}
+	return fn
+}
+
+// startInstance starts a stage instance that fans into multiple goroutines
+// increasing the stage throughput depending on the CPU. All the goroutines
+// read from the inChan and write to the same outChan, and their number can be
+// changed while the stage runs through its stageControl.
+func startInstance(cfg *stageConfig, inChan <-chan interface{}, outChan chan interface{}) {
+	inst := &stageInstance{cfg: cfg, inChan: inChan, outChan: outChan}
+	if cfg.weights != nil || (cfg.distribution != Shared && cfg.pool == nil) {
+		inst.dispatcher = newDispatcher(cfg.distribution, cfg.weights)
+		go inst.dispatcher.run(inChan, cfg.control, cfg.done)
+	}
+	cfg.control.start(inst)
+}
+
+// work is the loop of a single goroutine of a stage instance. It returns when
+// the inChan is closed, when the run is aborted or when quit is closed to
+// scale the stage down.
+func (inst *stageInstance) work(quit chan struct{}) {
+	cfg := inst.cfg
+	ctx := cfg.run.ctx
+	var slot *workerSlot // nil unless the run is profiled
+	if cfg.profiled {
+		slot, ctx = registerWorker(ctx, cfg.owner, cfg.name)
+	}
+	var process ProcessFnErr // of the goroutine, nil if it shares the one of the stage
+	inst.mu.RLock()
+	if cfg.newProcess != nil {
+		process = cfg.newProcess(ctx)
+	}
+	inst.mu.RUnlock()
+	inputClosed := false
+	defer func() {
+		slot.unregister()
+		cfg.control.workerDone(inst, inputClosed)
+	}()
+
+	stop := quit
+	var ln *lane
+	if inst.dispatcher != nil {
+		if ln = inst.dispatcher.join(quit); ln == nil {
+			inputClosed = true
+			return
+		}
+		if cfg.processes != nil {
+			process = cfg.processes[ln.index]
+		}
+		// the dispatcher closes the lane once quit is closed
+		stop = nil
+	}
+
+	for {
+		if ln != nil {
+			atomic.StoreInt32(&ln.busy, 0)
+		}
+		if cfg.control.isPaused() {
+			slot.set(pausedPhase)
+		}
+		if !cfg.control.waitResumed(stop, cfg.done) {
+			inputClosed = isClosed(cfg.done)
+			return
+		}
+
+		var inObj interface{}
+		if ln != nil {
+			obj, ok := ln.receive(inst.dispatcher, cfg.done)
+			if !ok {
+				inputClosed = isClosed(cfg.done) || !isClosed(quit)
+				return
+			}
+			inObj = obj
+		} else {
+			select {
+			case obj, ok := <-inst.inChan:
+				if !ok {
+					inputClosed = true
+					return
+				}
+				inObj = obj
+			case <-quit:
+				return
+			case <-cfg.done:
+				inputClosed = true
+				return
+			}
+		}
+
+		if !inst.pass(process, inObj, slot) {
+			inputClosed = true
+			return
+		}
+	}
+}
+
+// pass processes inObj and sends the result on to the next stage, holding off
+// ReplaceStage and InsertStage until it is done. It returns false if the run
+// was aborted in the meantime.
+func (inst *stageInstance) pass(process ProcessFnErr, inObj interface{}, slot *workerSlot) bool {
+	cfg := inst.cfg
+	inst.mu.RLock()
+	defer inst.mu.RUnlock()
+	if cfg.newProcess == nil && cfg.processes == nil {
+		// the goroutines share the function of the stage, which may have
+		// been replaced since
+		process = cfg.process
+	}
+
+	slot.set(processingPhase)
+	outObj, ok := cfg.handle(process, inObj)
+	slot.set(idlePhase)
+	if !ok {
+		return true
+	}
+	slot.set(sendingPhase)
+	select {
+	case inst.outChan <- outObj:
+	case <-cfg.done:
+		return false
+	}
+	slot.set(idlePhase)
+	atomic.AddUint64(&cfg.counters.out, 1)
+	cfg.control.tapped(outObj)
+	return true
+}
+
+// handle processes inObj and counts it, returning the object to pass on to the
+// next stage unless it failed or was dropped.
+func (cfg *stageConfig) handle(process ProcessFnErr, inObj interface{}) (outObj interface{}, ok bool) {
+	c := cfg.counters
+	atomic.AddUint64(&c.in, 1)
+	start := cfg.run.clock.Now()
+	outObj, err := process(inObj)
+	atomic.AddUint64(&c.nanos, uint64(cfg.run.clock.Now().Sub(start)))
+	if err != nil {
+		atomic.AddUint64(&c.errors, 1)
+		cfg.onError(inObj, err)
+		cfg.run.exit(inObj, err)
+		return nil, false
+	}
+	if outObj == nil {
+		atomic.AddUint64(&c.dropped, 1)
+		cfg.run.exit(inObj, nil)
+		return nil, false
+	}
+	return outObj, true
+}
+
+// isClosed tells whether a channel that is never sent to is closed.
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
 	}
 }
 
 // MergeChannels merges an array of channels into a single channel. This utility
-// function can also be used independently outside of a pipeline.
+// function can also be used independently outside of a pipeline. See MergeAll
+// for receive-only channels.
 func MergeChannels(inChans []chan interface{}) (outChan chan interface{}) {
+	recvChans := make([]<-chan interface{}, len(inChans))
+	for i, ch := range inChans {
+		recvChans[i] = ch
+	}
+	outChan = make(chan interface{})
+	merge(recvChans, outChan)
+	return
+}
+
+// MergeAll merges channels into a single channel, closed once they all are.
+// Unlike MergeChannels, it takes receive-only channels, such as the outputs of
+// other pipelines or of a Source.
+func MergeAll(inChans ...<-chan interface{}) <-chan interface{} {
+	outChan := make(chan interface{})
+	merge(inChans, outChan)
+	return outChan
+}
+
+// merge sends the objects of inChans to outChan and closes it once they are
+// all closed.
+func merge(inChans []<-chan interface{}, outChan chan interface{}) {
 	var wg sync.WaitGroup
 	wg.Add(len(inChans))
-
-	outChan = make(chan interface{})
 	for _, inChan := range inChans {
 		go func(ch <-chan interface{}) {
 			defer wg.Done()
@@ -132,5 +679,4 @@ func MergeChannels(inChans []chan interface{}) (outChan chan interface{}) {
 		defer close(outChan)
 		wg.Wait()
 	}()
-	return
 }
@@ -35,3 +35,27 @@ func ExampleMergeChannels() {
 
 	// Output: [1 2 3 4 5 6 7 8 9 10]
 }
+
+func ExampleMergeAll() {
+	countdown := 3
+	ticks := pipeline.FromFunc(func() (interface{}, bool) {
+		countdown--
+		return countdown, countdown >= 0
+	})
+
+	var words <-chan interface{}
+	ch := make(chan interface{}, 2)
+	ch <- "ready"
+	ch <- "set"
+	close(ch)
+	words = ch
+
+	var out []string
+	for e := range pipeline.MergeAll(ticks, words) {
+		out = append(out, fmt.Sprint(e))
+	}
+	sort.Strings(out)
+	fmt.Println(out)
+
+	// Output: [0 1 2 ready set]
+}
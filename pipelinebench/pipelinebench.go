@@ -0,0 +1,250 @@
+// Package pipelinebench drives a pipeline with synthetic load and measures
+// how it copes: the throughput and latency percentiles of every stage and of
+// the whole pipeline, and the stage holding it back.
+//
+//	p := pipeline.New()
+//	p.AddStageWithFanOut(decode, 4, pipeline.WithName("decode"))
+//	p.AddStageWithFanOut(enrich, 16, pipeline.WithName("enrich"))
+//	r, err := pipelinebench.Run(context.Background(), &p, pipelinebench.Load{Objects: 100000})
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	fmt.Print(r) // a table of the stages, then the bottleneck
+package pipelinebench
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Load is the synthetic load sent into a pipeline by Run.
+type Load struct {
+	// Objects is the number of objects to send, 1000 if both Objects and
+	// Duration are zero. With Duration set, objects are sent until either
+	// limit is reached.
+	Objects  int
+	Duration time.Duration
+
+	// Rate, if set, paces the objects to Rate per second. Otherwise they are
+	// sent as fast as the pipeline takes them.
+	Rate float64
+
+	// Generate returns the i-th object to send, i itself if nil.
+	Generate func(i int) interface{}
+}
+
+// Result holds the measures of a benchmark run.
+type Result struct {
+	Objects    int           // sent into the pipeline
+	Duration   time.Duration // of the whole run
+	Throughput float64       // objects per second through the whole pipeline
+	Latency    Percentiles   // from entering the pipeline to leaving it
+	Stages     []StageResult
+
+	// Bottleneck is the name of the stage whose goroutines were the busiest,
+	// empty if no stage measured any processing time.
+	Bottleneck string
+}
+
+// StageResult holds the measures of a single stage. Raw stages aren't
+// measured and only have a name.
+type StageResult struct {
+	Name        string
+	FanSize     uint64
+	Throughput  float64     // objects per second processed
+	Latency     Percentiles // of the calls of the ProcessFn
+	Utilization float64     // share of the time its goroutines were busy, from 0 to 1
+}
+
+// Percentiles summarizes a distribution of latencies.
+type Percentiles struct {
+	P50, P90, P99, Max time.Duration
+}
+
+func (p Percentiles) String() string {
+	return fmt.Sprintf("p50=%v p90=%v p99=%v max=%v", p.P50, p.P90, p.P99, p.Max)
+}
+
+// Run sends load into p, waits for the run to finish and returns what it
+// measured. The run is aborted once ctx is done, in which case the error of
+// ctx is returned along with the measures so far.
+//
+// The latencies are measured by tracing the objects, see
+// Pipeline.EnableTracing: Run runs a clone of p with its own Tracer, see
+// Pipeline.Clone, leaving p as it is even if it was built. Objects carry an
+// Envelope while in the pipeline, which costs a little throughput.
+func Run(ctx context.Context, p *pipeline.Pipeline, load Load) (*Result, error) {
+	t := &tracer{latencies: map[string][]time.Duration{}}
+	clone := p.Clone()
+	p = &clone
+	p.EnableTracing(t)
+
+	before := p.Stats()
+	start := time.Now()
+	in := make(chan interface{})
+	r := p.StartContext(ctx, in)
+	sent := send(in, load, r.Done())
+	close(in)
+	<-r.Done()
+	duration := time.Since(start)
+
+	res := &Result{Objects: sent, Duration: duration, Throughput: perSecond(uint64(sent), duration)}
+	res.Latency = percentiles(t.take(rootSpan))
+	def := p.Definition()
+	busiest := 0.0
+	for i, s := range p.Stats().Sub(before).Stages {
+		sr := StageResult{Name: s.Name, FanSize: def.Stages[i].FanSize}
+		if sr.FanSize > 0 {
+			sr.Throughput = perSecond(s.Out+s.Dropped+s.Errors, duration)
+			sr.Latency = percentiles(t.take(s.Name))
+			sr.Utilization = s.ProcessingTime.Seconds() / (duration.Seconds() * float64(sr.FanSize))
+			if sr.Utilization > busiest {
+				busiest, res.Bottleneck = sr.Utilization, s.Name
+			}
+		}
+		res.Stages = append(res.Stages, sr)
+	}
+	return res, r.Err()
+}
+
+// send sends the objects of load into in until they are all sent or done is
+// closed, and returns how many were sent.
+func send(in chan<- interface{}, load Load, done <-chan struct{}) (sent int) {
+	objects := load.Objects
+	if objects == 0 && load.Duration == 0 {
+		objects = 1000
+	}
+	var deadline <-chan time.Time
+	if load.Duration > 0 {
+		timer := time.NewTimer(load.Duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	var interval time.Duration
+	if load.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / load.Rate)
+	}
+
+	next := time.Now()
+	for objects == 0 || sent < objects {
+		if interval > 0 {
+			if d := next.Sub(time.Now()); d > 0 {
+				select {
+				case <-time.After(d):
+				case <-deadline:
+					return
+				case <-done:
+					return
+				}
+			}
+			next = next.Add(interval)
+		}
+		var obj interface{} = sent
+		if load.Generate != nil {
+			obj = load.Generate(sent)
+		}
+		select {
+		case in <- obj:
+			sent++
+		case <-deadline:
+			return
+		case <-done:
+			return
+		}
+	}
+	return
+}
+
+func perSecond(n uint64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) / d.Seconds()
+}
+
+// percentiles returns the percentiles of the latencies, which it sorts.
+func percentiles(latencies []time.Duration) Percentiles {
+	if len(latencies) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+	at := func(q float64) time.Duration {
+		return latencies[int(q*float64(len(latencies)-1))]
+	}
+	return Percentiles{P50: at(0.5), P90: at(0.9), P99: at(0.99), Max: latencies[len(latencies)-1]}
+}
+
+// String formats the result as a table of the stages followed by the
+// measures of the whole pipeline and its bottleneck.
+func (r *Result) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tFAN\tOBJ/S\tBUSY\tP50\tP90\tP99\tMAX")
+	for _, s := range r.Stages {
+		if s.FanSize == 0 {
+			fmt.Fprintf(w, "%s\t(raw)\t\t\t\t\t\t\n", s.Name)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.0f\t%.0f%%\t%v\t%v\t%v\t%v\n", s.Name, s.FanSize, s.Throughput, 100*s.Utilization,
+			s.Latency.P50, s.Latency.P90, s.Latency.P99, s.Latency.Max)
+	}
+	w.Flush()
+	fmt.Fprintf(&buf, "%d objects in %v, %.0f/s, latency %v\n", r.Objects, r.Duration, r.Throughput, r.Latency)
+	if r.Bottleneck != "" {
+		fmt.Fprintf(&buf, "bottleneck: %s\n", r.Bottleneck)
+	}
+	return buf.String()
+}
+
+// rootSpan is the name the latencies of the root spans are kept under, which
+// can't be the name of a stage.
+const rootSpan = ""
+
+// tracer records the duration of the spans of the objects: the root ones,
+// for their whole journey through the pipeline, and the ones of the stages.
+type tracer struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration // by stage name
+}
+
+type spanKey struct{}
+
+func (t *tracer) Start(ctx context.Context, spanName string) (context.Context, pipeline.Span) {
+	if ctx.Value(spanKey{}) == nil {
+		// no parent, the span of the whole journey of an object
+		spanName = rootSpan
+		ctx = context.WithValue(ctx, spanKey{}, true)
+	}
+	return ctx, &span{t: t, name: spanName, start: time.Now()}
+}
+
+// take returns the latencies of the spans with the given name, forgetting
+// them.
+func (t *tracer) take(name string) []time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	latencies := t.latencies[name]
+	delete(t.latencies, name)
+	return latencies
+}
+
+type span struct {
+	t     *tracer
+	name  string
+	start time.Time
+}
+
+func (s *span) End() {
+	d := time.Since(s.start)
+	s.t.mu.Lock()
+	s.t.latencies[s.name] = append(s.t.latencies[s.name], d)
+	s.t.mu.Unlock()
+}
@@ -0,0 +1,29 @@
+package pipelinebench_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/pipelinebench"
+	"time"
+)
+
+func ExampleRun() {
+	p := pipeline.New()
+	p.AddStageWithFanOut(func(inObj interface{}) interface{} {
+		return inObj.(int) * 2
+	}, 2, pipeline.WithName("double"))
+	p.AddStageWithFanOut(func(inObj interface{}) interface{} {
+		time.Sleep(time.Millisecond)
+		return inObj
+	}, 2, pipeline.WithName("lookup"))
+
+	r, err := pipelinebench.Run(context.Background(), &p, pipelinebench.Load{Objects: 100})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(r.Objects, r.Bottleneck, r.Stages[1].Latency.P50 >= time.Millisecond)
+
+	// Output: 100 lookup true
+}
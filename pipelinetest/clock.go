@@ -0,0 +1,148 @@
+package pipelinetest
+
+import (
+	"github.com/hyfather/pipeline"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FakeClock is a pipeline.Clock whose time only moves when told to, so that
+// time-based stages can be tested instantly and deterministically:
+//
+//	clock := pipelinetest.NewFakeClock(time.Time{})
+//	p.SetClock(clock)
+//	p.AddTumblingWindowStage(time.Minute, nil)
+//	...
+//	in <- obj
+//	clock.WaitTimers(1)        // the stage opened a window
+//	clock.Advance(time.Minute) // and closes it
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	timers  []*fakeTimer  // pending, in no particular order
+	changed chan struct{} // closed when timers are added
+}
+
+// NewFakeClock creates a FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now, changed: make(chan struct{})}
+}
+
+// Now implements pipeline.Clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer implements pipeline.Clock. The timers due already, with d <= 0,
+// fire on the next call to Advance, even Advance(0).
+func (c *FakeClock) NewTimer(d time.Duration) pipeline.Timer {
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// AfterFunc implements pipeline.Clock. Unlike with the system clock, f is
+// called by Advance, before it returns.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) pipeline.Timer {
+	t := &fakeTimer{clock: c, fn: f}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the time forward by d, firing the timers due in order.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		sort.SliceStable(c.timers, func(i, j int) bool {
+			return c.timers[i].due.Before(c.timers[j].due)
+		})
+		if len(c.timers) == 0 || c.timers[0].due.After(end) {
+			break
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		if t.due.After(c.now) {
+			c.now = t.due
+		}
+		now := c.now
+		c.mu.Unlock()
+		t.fire(now)
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// Timers returns the number of timers pending.
+func (c *FakeClock) Timers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// WaitTimers waits until at least n timers are pending, e.g. for a stage to
+// arm the timer of an object before advancing the time.
+func (c *FakeClock) WaitTimers(n int) {
+	c.mu.Lock()
+	for len(c.timers) < n {
+		changed := c.changed
+		c.mu.Unlock()
+		<-changed
+		c.mu.Lock()
+	}
+	c.mu.Unlock()
+}
+
+// fakeTimer is a timer of a FakeClock, sending on ch or calling fn.
+type fakeTimer struct {
+	clock *FakeClock
+	ch    chan time.Time
+	fn    func()
+	due   time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTimer) fire(now time.Time) {
+	if t.fn != nil {
+		t.fn()
+		return
+	}
+	select {
+	case t.ch <- now:
+	default:
+	}
+}
+
+// Stop removes the timer from the pending ones, telling whether it was.
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Reset makes the timer fire once d elapsed, telling whether it was pending.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	active := t.Stop()
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t.due = c.now.Add(d)
+	c.timers = append(c.timers, t)
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return active
+}
@@ -0,0 +1,138 @@
+package pipelinetest_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/pipelinetest"
+	"time"
+)
+
+func ExampleFakeClock() {
+	clock := pipelinetest.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	p := pipeline.New()
+	p.SetClock(clock)
+	p.AddTumblingWindowStage(time.Minute, func(w pipeline.Window) interface{} {
+		return fmt.Sprint(w.Start.Format("15:04"), " ", w.Objects)
+	})
+	p.AddStage(func(inObj interface{}) interface{} {
+		fmt.Println(inObj)
+		return inObj
+	})
+
+	in := make(chan interface{})
+	done := p.Run(in)
+	in <- 1
+	clock.WaitTimers(1) // the window of 1 opened
+	clock.Advance(time.Minute)
+	in <- 2
+	in <- 3
+	close(in) // closes the window of 2 and 3 early
+	<-done
+
+	// Output: 12:00 [1]
+	// 12:01 [2 3]
+}
+
+func ExampleFakeClock_circuitBreaker() {
+	clock := pipelinetest.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	healthy := false
+	p := pipeline.New()
+	p.SetClock(clock)
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		if !healthy {
+			return nil, fmt.Errorf("timeout")
+		}
+		return inObj, nil
+	}, 1, pipeline.WithCircuitBreaker(1, time.Minute, nil))
+	out := make(chan interface{})
+	p.AddStage(func(inObj interface{}) interface{} {
+		out <- inObj
+		return inObj
+	})
+	p.SetDeadLetter(func(err *pipeline.ItemError) {
+		out <- err.Err
+	})
+
+	in := make(chan interface{})
+	done := p.Run(in)
+	in <- 1
+	fmt.Println(<-out)
+	healthy = true
+	in <- 2 // rejected until the cooldown elapsed
+	fmt.Println(<-out)
+	clock.Advance(time.Minute)
+	in <- 3
+	fmt.Println(<-out)
+	close(in)
+	<-done
+
+	// Output: timeout
+	// pipeline: circuit breaker is open
+	// 3
+}
+
+func ExampleFakeClock_delay() {
+	clock := pipelinetest.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	p := pipeline.New()
+	p.SetClock(clock)
+	p.EnableEnvelopes() // the envelopes are stamped on the fake clock too
+	p.AddDelayStage(time.Minute, 10)
+	p.AddEnvelopeStage(func(env *pipeline.Envelope) (*pipeline.Envelope, error) {
+		fmt.Println(env.Payload, env.Ingested.Format("15:04"), clock.Now().Format("15:04"))
+		return env, nil
+	}, 1)
+
+	in := make(chan interface{})
+	done := p.Run(in)
+	in <- "late correction"
+	clock.WaitTimers(1) // the object is held
+	clock.Advance(time.Minute)
+	close(in)
+	<-done
+
+	// Output: late correction 12:00 12:01
+}
+
+func ExampleFakeClock_replay() {
+	clock := pipelinetest.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	p := pipeline.New()
+	p.SetClock(clock)
+	p.AddStage(func(inObj interface{}) interface{} {
+		clock.Advance(time.Millisecond) // processing takes a millisecond
+		return inObj
+	})
+	in := make(chan interface{}, 1)
+	in <- 0
+	close(in)
+	<-p.Run(in) // measures the capacity of the pipeline
+	capacity := 1 / p.Stats().Stages[0].AvgLatency().Seconds()
+
+	backlog := make(chan interface{}, 1000)
+	for i := 0; i < cap(backlog); i++ {
+		backlog <- i
+	}
+	close(backlog)
+	ctx, cancel := context.WithCancel(context.Background())
+	out := p.ReplayContext(ctx, nil, backlog, 0.1)
+	replayed := make(chan int)
+	go func() {
+		n := 0
+		for range out {
+			n++
+		}
+		replayed <- n
+	}()
+	for i := 0; i < 1000; i++ {
+		clock.WaitTimers(1) // the backlog is held back
+		clock.Advance(time.Millisecond)
+	}
+	clock.WaitTimers(1)
+	cancel()
+
+	// one second takes at most a tenth of the capacity
+	n := <-replayed
+	fmt.Println(n > 1, float64(n) <= 0.1*capacity+1)
+
+	// Output: true true
+}
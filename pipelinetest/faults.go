@@ -0,0 +1,168 @@
+package pipelinetest
+
+import (
+	"errors"
+	"github.com/hyfather/pipeline"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrInjected is the error injected by a FaultInjector unless Faults.Err is
+// set.
+var ErrInjected = errors.New("pipelinetest: injected fault")
+
+// Faults are the probabilities, from 0 to 1, of the faults a FaultInjector
+// injects into every object.
+type Faults struct {
+	Delay     float64
+	Drop      float64
+	Duplicate float64 // only applies to Stage
+	Error     float64 // only applies to Wrap
+
+	MaxDelay time.Duration // delays are random up to MaxDelay, 10ms if zero
+	Err      error         // the injected error, ErrInjected if nil
+
+	// Seed, if set, makes the faults reproducible: the same objects coming
+	// in the same order get the same faults.
+	Seed int64
+}
+
+// FaultCounts counts the faults a FaultInjector injected.
+type FaultCounts struct {
+	Delayed    uint64
+	Dropped    uint64
+	Duplicated uint64
+	Errors     uint64
+}
+
+// FaultInjector randomly delays, drops, duplicates or fails objects, to check
+// how a pipeline copes with adverse conditions: that its retries and
+// dead-letter function handle the errors, that its sinks are idempotent and
+// that it doesn't rely on an order its stages don't keep. Wrap injects faults
+// into the function of a stage, Stage between two stages:
+//
+//	faults := pipelinetest.NewFaultInjector(pipelinetest.Faults{Error: 0.1, Seed: 1})
+//	p.AddStageErr(faults.Wrap(store), 4, pipeline.WithRetry(3, backoff))
+type FaultInjector struct {
+	faults Faults
+
+	mu     sync.Mutex
+	rng    *rand.Rand
+	counts FaultCounts
+}
+
+// NewFaultInjector creates a FaultInjector injecting the given faults.
+func NewFaultInjector(faults Faults) *FaultInjector {
+	seed := faults.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if faults.MaxDelay <= 0 {
+		faults.MaxDelay = 10 * time.Millisecond
+	}
+	if faults.Err == nil {
+		faults.Err = ErrInjected
+	}
+	return &FaultInjector{faults: faults, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Counts returns the number of faults injected so far.
+func (fi *FaultInjector) Counts() FaultCounts {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	return fi.counts
+}
+
+// Wrap returns fn injecting delays before calling it, and dropping or failing
+// objects instead of calling it. Injected errors go through the retries and
+// the dead-letter function of the stage like the ones of fn.
+func (fi *FaultInjector) Wrap(fn pipeline.ProcessFnErr) pipeline.ProcessFnErr {
+	return func(inObj interface{}) (interface{}, error) {
+		delay, fault := fi.roll(errorFault)
+		time.Sleep(delay)
+		switch fault {
+		case dropFault:
+			return nil, nil
+		case errorFault:
+			return nil, fi.faults.Err
+		}
+		return fn(inObj)
+	}
+}
+
+// Stage returns a raw stage passing objects on after injecting delays,
+// drops and duplicates, to add with AddRawStage. It delays the objects one
+// at a time. The envelopes of the dropped objects are settled, see
+// pipeline.Settle, and the duplicates carry a copy of the envelope of their
+// object without its Acknowledger.
+func (fi *FaultInjector) Stage() pipeline.StageFn {
+	return func(inChan <-chan interface{}) (outChan chan interface{}) {
+		outChan = make(chan interface{})
+		go func() {
+			defer close(outChan)
+			for obj := range inChan {
+				delay, fault := fi.roll(duplicateFault)
+				time.Sleep(delay)
+				switch fault {
+				case dropFault:
+					pipeline.Settle(obj, nil)
+					continue
+				case duplicateFault:
+					outChan <- obj
+					obj = duplicate(obj)
+				}
+				outChan <- obj
+			}
+		}()
+		return
+	}
+}
+
+const (
+	noFault = iota
+	dropFault
+	errorFault
+	duplicateFault
+)
+
+// roll draws the faults of an object: how long to delay it, and whether to
+// drop it or inject the other fault into it, errorFault or duplicateFault.
+func (fi *FaultInjector) roll(other int) (delay time.Duration, fault int) {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	if fi.rng.Float64() < fi.faults.Delay {
+		delay = time.Duration(1 + fi.rng.Int63n(int64(fi.faults.MaxDelay)))
+		fi.counts.Delayed++
+	}
+	p, count := fi.faults.Error, &fi.counts.Errors
+	if other == duplicateFault {
+		p, count = fi.faults.Duplicate, &fi.counts.Duplicated
+	}
+	switch r := fi.rng.Float64(); {
+	case r < fi.faults.Drop:
+		fault = dropFault
+		fi.counts.Dropped++
+	case r < fi.faults.Drop+p:
+		fault = other
+		*count++
+	}
+	return
+}
+
+// duplicate returns a copy of obj, with a copy of its envelope if any.
+func duplicate(obj interface{}) interface{} {
+	env, ok := obj.(*pipeline.Envelope)
+	if !ok {
+		return obj
+	}
+	dup := &pipeline.Envelope{Payload: env.Payload, Ingested: env.Ingested, Seq: env.Seq, Key: env.Key,
+		Priority: env.Priority, Trace: env.Trace}
+	if env.Attrs != nil {
+		dup.Attrs = map[string]string{}
+		for k, v := range env.Attrs {
+			dup.Attrs[k] = v
+		}
+	}
+	return dup
+}
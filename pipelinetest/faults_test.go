@@ -0,0 +1,65 @@
+package pipelinetest_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/pipelinetest"
+	"sync/atomic"
+)
+
+func ExampleFaultInjector() {
+	faults := pipelinetest.NewFaultInjector(pipelinetest.Faults{Drop: 0.1, Duplicate: 0.2, Error: 0.3, Seed: 1})
+
+	p := pipeline.New()
+	p.AddRawStage(faults.Stage())
+	p.AddStageErr(faults.Wrap(func(inObj interface{}) (interface{}, error) {
+		return inObj, nil
+	}), 4)
+	var deadLettered uint64
+	p.SetDeadLetter(func(*pipeline.ItemError) {
+		atomic.AddUint64(&deadLettered, 1)
+	})
+
+	inputs := make([]interface{}, 1000)
+	for i := range inputs {
+		inputs[i] = i
+	}
+	r := pipelinetest.Run(&p, inputs...)
+	c := faults.Counts()
+	// every object in came out, unless dropped or failed, along with the
+	// duplicates
+	fmt.Println(uint64(len(r.Outputs)) == 1000-c.Dropped+c.Duplicated-c.Errors)
+	fmt.Println(deadLettered == c.Errors, c.Errors > 0)
+
+	// Output: true
+	// true true
+}
+
+type countingTracer struct{ ended uint64 }
+
+func (t *countingTracer) Start(ctx context.Context, spanName string) (context.Context, pipeline.Span) {
+	return ctx, t
+}
+
+func (t *countingTracer) End() {
+	atomic.AddUint64(&t.ended, 1)
+}
+
+func ExampleFaultInjector_Stage() {
+	faults := pipelinetest.NewFaultInjector(pipelinetest.Faults{Drop: 1, Seed: 1})
+	tracer := &countingTracer{}
+
+	p := pipeline.New()
+	p.EnableTracing(tracer)
+	p.AddRawStage(faults.Stage())
+	p.AddStage(func(inObj interface{}) interface{} {
+		return inObj
+	})
+
+	// the traces of the dropped objects end, and their envelopes are recycled
+	r := pipelinetest.Run(&p, 1, 2, 3)
+	fmt.Println(len(r.Outputs), faults.Counts().Dropped, atomic.LoadUint64(&tracer.ended))
+
+	// Output: 0 3 3
+}
@@ -0,0 +1,135 @@
+// Package pipelinetest helps testing the stages of a pipeline, and small
+// pipelines, deterministically and without wiring channels by hand:
+//
+//	func TestParse(t *testing.T) {
+//		outputs, errs := pipelinetest.RunStage(parse, "1", "x", "3")
+//		pipelinetest.Expect(t, outputs, 1, 3)
+//		if len(errs) != 1 {
+//			t.Errorf("errors = %v, want 1", errs)
+//		}
+//	}
+package pipelinetest
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"reflect"
+	"sort"
+)
+
+// TB is the part of testing.TB the assertions use.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// RunStage runs fn as the only stage of a synchronous pipeline over inputs,
+// see Pipeline.SetSynchronous, and returns the objects it passed on and the
+// errors it returned, both in the order of the inputs. The objects it dropped
+// are in neither.
+func RunStage(fn pipeline.ProcessFnErr, inputs ...interface{}) (outputs []interface{}, errs []error) {
+	p := pipeline.New()
+	p.AddStageErr(fn, 1)
+	p.AddStage(func(outObj interface{}) interface{} {
+		outputs = append(outputs, outObj)
+		return outObj
+	})
+	p.SetDeadLetter(func(err *pipeline.ItemError) {
+		errs = append(errs, err.Err)
+	})
+	p.SetSynchronous(true)
+	<-p.Run(feed(inputs))
+	return
+}
+
+// Result is what came out of a run of a pipeline, see Run.
+type Result struct {
+	Outputs []interface{} // in the order they came out
+	Stats   pipeline.Stats
+}
+
+// Run runs p over inputs and returns the objects coming out of its last
+// stage, along with the counters of its stages during the run. The outputs
+// are in order only if the stages keep it, e.g. if their fan size is 1.
+func Run(p *pipeline.Pipeline, inputs ...interface{}) *Result {
+	before := p.Stats()
+	r := &Result{}
+	for obj := range pipeline.AsStageFn(p)(feed(inputs)) {
+		r.Outputs = append(r.Outputs, obj)
+	}
+	r.Stats = p.Stats().Sub(before)
+	return r
+}
+
+// feed returns a closed channel holding objs.
+func feed(objs []interface{}) <-chan interface{} {
+	ch := make(chan interface{}, len(objs))
+	for _, obj := range objs {
+		ch <- obj
+	}
+	close(ch)
+	return ch
+}
+
+// Stage returns the counters of the named stage during the run, or zero
+// counters if there is no such stage.
+func (r *Result) Stage(name string) pipeline.StageStats {
+	for _, s := range r.Stats.Stages {
+		if s.Name == name {
+			return s
+		}
+	}
+	return pipeline.StageStats{Name: name}
+}
+
+// ExpectOutputs reports an error unless the outputs are want, in order.
+func (r *Result) ExpectOutputs(t TB, want ...interface{}) {
+	t.Helper()
+	Expect(t, r.Outputs, want...)
+}
+
+// ExpectOutputsUnordered reports an error unless the outputs are want, in any
+// order.
+func (r *Result) ExpectOutputsUnordered(t TB, want ...interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(sorted(r.Outputs), sorted(want)) {
+		t.Errorf("outputs = %v, want %v in any order", r.Outputs, want)
+	}
+}
+
+// ExpectDropped reports an error unless the named stage dropped n objects.
+func (r *Result) ExpectDropped(t TB, stage string, n uint64) {
+	t.Helper()
+	if got := r.Stage(stage).Dropped; got != n {
+		t.Errorf("%s dropped %d objects, want %d", stage, got, n)
+	}
+}
+
+// ExpectErrors reports an error unless the named stage failed n objects.
+func (r *Result) ExpectErrors(t TB, stage string, n uint64) {
+	t.Helper()
+	if got := r.Stage(stage).Errors; got != n {
+		t.Errorf("%s failed %d objects, want %d", stage, got, n)
+	}
+}
+
+// Expect reports an error unless got holds the objects of want, in order.
+func Expect(t TB, got []interface{}, want ...interface{}) {
+	t.Helper()
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("outputs = %v, want %v", got, want)
+	}
+}
+
+// sorted returns a copy of objs sorted by their printed form, so that
+// objects of any type can be compared regardless of their order.
+func sorted(objs []interface{}) []interface{} {
+	sorted := append([]interface{}(nil), objs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return fmt.Sprintf("%#v", sorted[i]) < fmt.Sprintf("%#v", sorted[j])
+	})
+	return sorted
+}
@@ -0,0 +1,50 @@
+package pipelinetest_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/pipelinetest"
+	"strconv"
+)
+
+// printT prints the failures of the assertions instead of failing a test.
+type printT struct{}
+
+func (printT) Helper() {}
+
+func (printT) Errorf(format string, args ...interface{}) {
+	fmt.Printf(format+"\n", args...)
+}
+
+func ExampleRunStage() {
+	outputs, errs := pipelinetest.RunStage(func(inObj interface{}) (interface{}, error) {
+		if inObj == "" {
+			return nil, nil
+		}
+		return strconv.Atoi(inObj.(string))
+	}, "1", "", "x", "3")
+	fmt.Println(outputs, errs)
+
+	// Output: [1 3] [strconv.Atoi: parsing "x": invalid syntax]
+}
+
+func ExampleRun() {
+	p := pipeline.New()
+	p.AddStage(func(inObj interface{}) interface{} {
+		if inObj.(int)%2 == 0 {
+			return nil
+		}
+		return inObj
+	}, pipeline.WithName("odd"))
+	p.AddStageWithFanOut(func(inObj interface{}) interface{} {
+		return inObj.(int) * 10
+	}, 4, pipeline.WithName("times10"))
+
+	r := pipelinetest.Run(&p, 1, 2, 3, 4, 5)
+	var t printT
+	r.ExpectOutputsUnordered(t, 50, 10, 30)
+	r.ExpectDropped(t, "odd", 2)
+	r.ExpectErrors(t, "times10", 1)
+
+	// Output: times10 failed 0 objects, want 1
+}
@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// WorkerPool is a bounded set of goroutines processing the objects of the
+// stages of one or more pipelines, see SetWorkerPool.
+type WorkerPool struct {
+	tasks chan func()
+	once  sync.Once
+}
+
+// NewWorkerPool starts a pool of size goroutines.
+func NewWorkerPool(size int) *WorkerPool {
+	wp := &WorkerPool{tasks: make(chan func())}
+	for i := 0; i < size; i++ {
+		go func() {
+			for task := range wp.tasks {
+				task()
+			}
+		}()
+	}
+	return wp
+}
+
+// Close stops the goroutines of the pool. It must only be called once all the
+// runs of the pipelines using the pool are done.
+func (wp *WorkerPool) Close() {
+	wp.once.Do(func() {
+		close(wp.tasks)
+	})
+}
+
+// SetWorkerPool makes the stages of the pipeline process their objects on a
+// shared pool instead of starting fanSize goroutines each in every run, which
+// keeps the number of goroutines under control when many pipelines run at
+// once. Every stage then runs a single goroutine per run, dispatching up to
+// fanSize objects at a time to the pool. Raw stages aren't affected.
+//
+// The setting applies to the runs started after the call.
+func (p *Pipeline) SetWorkerPool(pool *WorkerPool) {
+	p.checkMutable()
+	p.pool = pool
+}
+
+// poolResult is an object processed on the pool, along with the ProcessFnErr
+// that processed it so that stateful workers can be reused.
+type poolResult struct {
+	outObj  interface{} // nil if the object was dropped or failed
+	process ProcessFnErr
+}
+
+// dispatch is the goroutine of a stage instance running on a pool. It reads
+// objects while fewer than fanSize are in flight, hands them to the pool and
+// sends their results on. The pool goroutines never block on the stage, so
+// stages sharing a pool can't starve each other out.
+func (inst *stageInstance) dispatch(pool *WorkerPool) {
+	cfg, c := inst.cfg, inst.cfg.counters
+	defer func() {
+		if cfg.onDone != nil {
+			cfg.onDone()
+		}
+		close(inst.outChan)
+	}()
+
+	var (
+		inFlight    int
+		inputClosed bool
+		idle        []ProcessFnErr // workers not processing any object
+		ready       []interface{}  // results waiting to be sent on
+
+		mu       sync.Mutex
+		finished []poolResult
+		notify   = make(chan struct{}, 1)
+	)
+	finish := func(r poolResult) {
+		mu.Lock()
+		finished = append(finished, r)
+		mu.Unlock()
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+
+	for !inputClosed || inFlight > 0 {
+		fanSize, paused, changed := cfg.control.state()
+		var in <-chan interface{}
+		if !inputClosed && !paused && uint64(inFlight) < fanSize {
+			in = inst.inChan
+		}
+		var out chan interface{}
+		var next interface{}
+		if len(ready) > 0 {
+			out, next = inst.outChan, ready[0]
+		}
+
+		select {
+		case inObj, ok := <-in:
+			if !ok {
+				inputClosed = true
+				continue
+			}
+			process := cfg.process
+			if cfg.newProcess != nil {
+				if n := len(idle); n > 0 {
+					process, idle = idle[n-1], idle[:n-1]
+				} else {
+					process = cfg.newProcess(cfg.run.ctx)
+				}
+			}
+
+			inFlight++
+			task := func() {
+				outObj, _ := cfg.handle(process, inObj)
+				finish(poolResult{outObj, process})
+			}
+			select {
+			case pool.tasks <- task:
+			case <-cfg.done:
+				return
+			}
+		case out <- next:
+			ready[0] = nil
+			ready = ready[1:]
+			inFlight--
+			atomic.AddUint64(&c.out, 1)
+			cfg.control.tapped(next)
+		case <-notify:
+			mu.Lock()
+			results := finished
+			finished = nil
+			mu.Unlock()
+			for _, r := range results {
+				if cfg.newProcess != nil {
+					idle = append(idle, r.process)
+				}
+				if r.outObj != nil {
+					ready = append(ready, r.outObj)
+				} else {
+					inFlight--
+				}
+			}
+		case <-changed:
+		case <-cfg.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"sort"
+)
+
+func ExamplePipeline_SetWorkerPool() {
+	// a single pool of 4 goroutines shared by the stages of two pipelines
+	pool := pipeline.NewWorkerPool(4)
+	defer pool.Close()
+
+	var results [2][]int
+	var done [2]chan struct{}
+	for i := range done {
+		i := i
+		p := pipeline.New()
+		p.SetWorkerPool(pool)
+		p.AddStageWithFanOut(squareStage, 3)
+		p.AddStage(func(inObj interface{}) interface{} {
+			results[i] = append(results[i], inObj.(int))
+			return inObj
+		})
+
+		in := make(chan interface{}, 5)
+		for n := 1; n <= 5; n++ {
+			in <- n * (i + 1)
+		}
+		close(in)
+		done[i] = p.Run(in)
+	}
+
+	for i := range done {
+		<-done[i]
+		sort.Ints(results[i])
+		fmt.Println(results[i])
+	}
+
+	// Output: [1 4 9 16 25]
+	// [4 16 36 64 100]
+}
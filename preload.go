@@ -0,0 +1,192 @@
+package pipeline
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Preloader is implemented by the stages backed by a cache, such as
+// enrichment or memoization stages, that can warm it up before the pipeline
+// takes traffic to avoid a latency spike on cold start. Stages added with
+// AddLifecycleStage that implement it are preloaded by Pipeline.Preload.
+type Preloader interface {
+	Preload(ctx context.Context) error
+}
+
+// WithPreload is a StageOption registering the function warming up the cache
+// of a stage, called by Pipeline.Preload:
+//
+//	cache := pipeline.NewLookupCache(lookupUser, 100000)
+//	p.AddStageErr(enrich(cache), 8, pipeline.WithPreload(func(ctx context.Context) error {
+//		return cache.Preload(ctx, hotUsers())
+//	}))
+func WithPreload(preload func(ctx context.Context) error) StageOption {
+	return func(s *stage) {
+		s.preload = preload
+	}
+}
+
+// Preload warms up the caches of the stages added with WithPreload or
+// implementing Preloader, concurrently, and returns the first error. It
+// should be called after Init and before the first run; Main does so before
+// reporting the process as ready.
+func (p *Pipeline) Preload(ctx context.Context) error {
+	var wg sync.WaitGroup
+	stages := p.stageList()
+	errs := make([]error, len(stages))
+	for i, s := range stages {
+		if s.preload == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, s *stage) {
+			defer wg.Done()
+			if err := s.preload(ctx); err != nil {
+				errs[i] = fmt.Errorf("pipeline: preload %s: %v", s.name, err)
+			}
+		}(i, s)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// KeyIterator iterates over the keys to preload into a cache, e.g. the hot
+// keys of yesterday read from a database:
+//
+//	for keys.Next() {
+//		load(keys.Key())
+//	}
+//	if err := keys.Err(); err != nil {
+//		...
+//	}
+type KeyIterator interface {
+	// Next advances to the next key. It returns false when there are no
+	// more keys or on error.
+	Next() bool
+	Key() string
+	Err() error
+}
+
+// SliceKeys returns a KeyIterator over a slice of keys.
+func SliceKeys(keys []string) KeyIterator {
+	return &sliceKeys{keys: keys, i: -1}
+}
+
+type sliceKeys struct {
+	keys []string
+	i    int
+}
+
+func (k *sliceKeys) Next() bool {
+	if k.i+1 >= len(k.keys) {
+		return false
+	}
+	k.i++
+	return true
+}
+
+func (k *sliceKeys) Key() string { return k.keys[k.i] }
+func (k *sliceKeys) Err() error  { return nil }
+
+// LookupCache memoizes the results of a lookup function, such as a database
+// query, for the stages enriching objects with them. Up to capacity results
+// are kept, the least recently used being evicted first; a capacity of zero
+// is unbounded. Failed lookups are not cached.
+//
+// It is safe for concurrent use.
+type LookupCache struct {
+	lookup   func(key string) (interface{}, error)
+	capacity int
+
+	mu     sync.Mutex
+	order  *list.List // of *cacheEntry, most recently used first
+	values map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key   string
+	value interface{}
+}
+
+// NewLookupCache creates a LookupCache in front of lookup.
+func NewLookupCache(lookup func(key string) (interface{}, error), capacity int) *LookupCache {
+	return &LookupCache{
+		lookup:   lookup,
+		capacity: capacity,
+		order:    list.New(),
+		values:   map[string]*list.Element{},
+	}
+}
+
+// Get returns the cached value of key, looking it up on a miss. Concurrent
+// misses on the same key may look it up more than once.
+func (c *LookupCache) Get(key string) (interface{}, error) {
+	c.mu.Lock()
+	if e, ok := c.values[key]; ok {
+		c.order.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*cacheEntry).value, nil
+	}
+	c.mu.Unlock()
+
+	value, err := c.lookup(key)
+	if err != nil {
+		return nil, err
+	}
+	c.put(key, value)
+	return value, nil
+}
+
+// Preload looks up the keys missing from the cache, until the iterator is
+// exhausted or ctx is done. It stops on the first lookup failing.
+func (c *LookupCache) Preload(ctx context.Context, keys KeyIterator) error {
+	for keys.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key := keys.Key()
+		c.mu.Lock()
+		_, ok := c.values[key]
+		c.mu.Unlock()
+		if ok {
+			continue
+		}
+		value, err := c.lookup(key)
+		if err != nil {
+			return fmt.Errorf("%s: %v", key, err)
+		}
+		c.put(key, value)
+	}
+	return keys.Err()
+}
+
+// Len returns the number of values cached.
+func (c *LookupCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LookupCache) put(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.values[key]; ok {
+		e.Value.(*cacheEntry).value = value
+		c.order.MoveToFront(e)
+		return
+	}
+	c.values[key] = c.order.PushFront(&cacheEntry{key: key, value: value})
+	if c.capacity > 0 && c.order.Len() > c.capacity {
+		e := c.order.Back()
+		c.order.Remove(e)
+		delete(c.values, e.Value.(*cacheEntry).key)
+	}
+}
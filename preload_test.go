@@ -0,0 +1,44 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"strings"
+)
+
+func ExamplePipeline_Preload() {
+	lookups := 0
+	cache := pipeline.NewLookupCache(func(key string) (interface{}, error) {
+		lookups++
+		return strings.ToUpper(key), nil
+	}, 100)
+
+	p := pipeline.New()
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		return cache.Get(inObj.(string))
+	}, 1, pipeline.WithPreload(func(ctx context.Context) error {
+		return cache.Preload(ctx, pipeline.SliceKeys([]string{"alice", "bob"}))
+	}))
+	p.AddStage(printStage)
+
+	if err := p.Preload(context.Background()); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println("lookups after preload:", lookups)
+
+	in := make(chan interface{}, 3)
+	in <- "alice"
+	in <- "bob"
+	in <- "carol"
+	close(in)
+	<-p.Run(in)
+	fmt.Println("lookups after run:", lookups)
+
+	// Output: lookups after preload: 2
+	// ALICE
+	// BOB
+	// CAROL
+	// lookups after run: 3
+}
@@ -0,0 +1,110 @@
+package pipeline
+
+import (
+	"container/heap"
+)
+
+// EnablePriorities makes the stages of the pipeline process the objects with
+// the highest Envelope.Priority first, so that urgent objects, such as alerts,
+// aren't stuck behind bulk traffic. Objects of the same priority keep their
+// order. It enables envelopes, see EnableEnvelopes; sources set the priority
+// of the envelopes they send into the pipeline.
+//
+// Up to buffer objects wait in a priority queue in front of every stage
+// except raw stages. The larger the buffer, the further urgent objects can
+// jump ahead, at the cost of memory. Objects waiting in a queue aren't counted
+// as in flight by Stats.
+func (p *Pipeline) EnablePriorities(buffer int) {
+	p.checkMutable()
+	p.priorities = buffer
+}
+
+// prioritize reorders the objects of inChan by priority, holding up to size
+// of them, until done is closed.
+func prioritize(inChan <-chan interface{}, size int, done <-chan struct{}) (outChan chan interface{}) {
+	outChan = make(chan interface{})
+	go func() {
+		defer close(outChan)
+		var q priorityQueue
+		var seq uint64
+		push := func(obj interface{}) {
+			item := prioritized{obj: obj, seq: seq}
+			if env, ok := obj.(*Envelope); ok {
+				item.priority = env.Priority
+			}
+			seq++
+			heap.Push(&q, item)
+		}
+
+		for inChan != nil || len(q) > 0 {
+			// take whatever is ready before choosing what goes next
+			for inChan != nil && len(q) < size {
+				select {
+				case obj, ok := <-inChan:
+					if !ok {
+						inChan = nil
+						continue
+					}
+					push(obj)
+					continue
+				default:
+				}
+				break
+			}
+
+			var in <-chan interface{}
+			if len(q) < size {
+				in = inChan
+			}
+			var out chan interface{}
+			var next interface{}
+			if len(q) > 0 {
+				out, next = outChan, q[0].obj
+			}
+
+			select {
+			case obj, ok := <-in:
+				if !ok {
+					inChan = nil
+					continue
+				}
+				push(obj)
+			case out <- next:
+				heap.Pop(&q)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return
+}
+
+type prioritized struct {
+	obj      interface{}
+	priority int
+	seq      uint64 // keeps objects of the same priority in order
+}
+
+// priorityQueue implements heap.Interface.
+type priorityQueue []prioritized
+
+func (q priorityQueue) Len() int { return len(q) }
+
+func (q priorityQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q priorityQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *priorityQueue) Push(x interface{}) { *q = append(*q, x.(prioritized)) }
+
+func (q *priorityQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	old[len(old)-1] = prioritized{}
+	*q = old[:len(old)-1]
+	return item
+}
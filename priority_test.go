@@ -0,0 +1,44 @@
+package pipeline_test
+
+import (
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_EnablePriorities() {
+	queued := make(chan struct{})
+	p := pipeline.New()
+	p.EnablePriorities(100)
+	// priorities don't apply in front of raw stages, so the queue of notify
+	// holds every object once this one sent them
+	p.AddRawStage(func(inChan <-chan interface{}) (outChan chan interface{}) {
+		outChan = make(chan interface{})
+		go func() {
+			defer close(outChan)
+			for obj := range inChan {
+				outChan <- obj
+			}
+			close(queued)
+		}()
+		return
+	}, pipeline.WithName("ingest"))
+	p.AddStage(printStage, pipeline.WithName("notify"))
+
+	in := make(chan interface{}, 4)
+	in <- &pipeline.Envelope{Payload: "backfill-1"}
+	in <- &pipeline.Envelope{Payload: "backfill-2"}
+	in <- &pipeline.Envelope{Payload: "backfill-3"}
+	in <- &pipeline.Envelope{Payload: "alert", Priority: 10}
+	close(in)
+
+	// hold the stage back until all the objects are waiting for it
+	p.PauseStage("notify")
+	done := p.Run(in)
+	<-queued
+	p.ResumeStage("notify")
+	<-done
+
+	// Output: alert
+	// backfill-1
+	// backfill-2
+	// backfill-3
+}
@@ -0,0 +1,27 @@
+package pipeline
+
+import "context"
+
+// ProcessFnCtx is a ProcessFnErr receiving the context of the run, so that
+// stages doing I/O can give up when the run is aborted, and can read the
+// labels of the run with LabelsFromContext.
+type ProcessFnCtx func(ctx context.Context, inObj interface{}) (outObj interface{}, err error)
+
+// AddStageCtx adds a fan-out stage whose function receives the context of the
+// run: it is canceled when the run is aborted, see RunContext, Run.Stop and
+// FailFast. The stage otherwise behaves exactly like AddStageErr.
+func (p *Pipeline) AddStageCtx(inFunc ProcessFnCtx, fanSize uint64, opts ...StageOption) {
+	p.addStage(&stage{processCtx: inFunc, control: newStageControl(fanSize)}, opts...)
+}
+
+// processFn returns the ProcessFnErr of a stage passing ctx on, which is the
+// context of the run or of a profiled goroutine of it.
+func (s *stage) processFn(ctx context.Context) ProcessFnErr {
+	if s.processCtx == nil {
+		return s.process
+	}
+	fn := s.processCtx
+	return func(inObj interface{}) (interface{}, error) {
+		return fn(ctx, inObj)
+	}
+}
@@ -0,0 +1,32 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExamplePipeline_AddStageCtx() {
+	p := pipeline.New()
+	p.AddStageCtx(func(ctx context.Context, inObj interface{}) (interface{}, error) {
+		// a request that gives up when the run is aborted
+		select {
+		case <-time.After(time.Duration(inObj.(int)) * time.Millisecond):
+			return fmt.Sprintf("%s: %d", pipeline.LabelsFromContext(ctx)["tenant"], inObj), nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}, 1)
+	p.AddStage(printStage)
+
+	in := make(chan interface{}, 2)
+	in <- 1
+	in <- 2
+	close(in)
+	ctx := pipeline.WithLabels(context.Background(), pipeline.Labels{"tenant": "acme"})
+	<-p.RunContext(ctx, in)
+
+	// Output: acme: 1
+	// acme: 2
+}
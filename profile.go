@@ -0,0 +1,366 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// SetProfiling makes the stage goroutines of the runs started afterwards
+// publish what they are doing, which StartProfiler, Phase, Snapshot and
+// StartWatchdog report on. It costs a few atomic operations per object, and
+// a goroutine profile every time a stage is sampled or found stalled. The
+// stages running on a WorkerPool aren't profiled.
+func (p *Pipeline) SetProfiling(enabled bool) {
+	p.checkMutable()
+	p.profiling = enabled
+}
+
+// The phases every stage goroutine goes through.
+const (
+	phaseIdle       = "idle"       // waiting for an object
+	phasePaused     = "paused"     // see PauseStage
+	phaseProcessing = "processing" // in the ProcessFn
+	phaseSending    = "sending"    // waiting for the next stage to take its output
+)
+
+// workerSlot publishes the phase of a stage goroutine to the profiler. The
+// goroutines of the runs that aren't profiled have none, and the methods of a
+// nil slot do nothing.
+type workerSlot struct {
+	phase int32 // index in phaseNames, updated atomically
+	owner *Pipeline
+	stage string
+	id    int64
+}
+
+// workers are the running stage goroutines of the profiled runs, by id.
+var workers = struct {
+	sync.Mutex
+	byID   map[int64]*workerSlot
+	lastID int64
+}{byID: map[int64]*workerSlot{}}
+
+// workerLabel is the profiler label carrying the id of a stage goroutine.
+const workerLabel = "pipeline_worker"
+
+// slotKey is the context key of the slot of a stage goroutine.
+type slotKey struct{}
+
+// phaseNames interns the names of the phases.
+var phaseNames = struct {
+	sync.Mutex
+	names []string
+	index map[string]int32
+}{index: map[string]int32{}}
+
+var (
+	idlePhase       = phaseIndex(phaseIdle)
+	pausedPhase     = phaseIndex(phasePaused)
+	processingPhase = phaseIndex(phaseProcessing)
+	sendingPhase    = phaseIndex(phaseSending)
+)
+
+func phaseIndex(name string) int32 {
+	phaseNames.Lock()
+	defer phaseNames.Unlock()
+	i, ok := phaseNames.index[name]
+	if !ok {
+		i = int32(len(phaseNames.names))
+		phaseNames.names = append(phaseNames.names, name)
+		phaseNames.index[name] = i
+	}
+	return i
+}
+
+func phaseName(i int32) string {
+	phaseNames.Lock()
+	defer phaseNames.Unlock()
+	return phaseNames.names[i]
+}
+
+// registerWorker registers the calling goroutine as a goroutine of a stage,
+// returning its slot along with ctx carrying it. The goroutine is labeled with
+// the id of the slot, so that its stack can be told apart in the goroutine
+// profile.
+func registerWorker(ctx context.Context, owner *Pipeline, stage string) (*workerSlot, context.Context) {
+	slot := &workerSlot{owner: owner, stage: stage}
+	workers.Lock()
+	workers.lastID++
+	slot.id = workers.lastID
+	workers.byID[slot.id] = slot
+	workers.Unlock()
+
+	ctx = pprof.WithLabels(context.WithValue(ctx, slotKey{}, slot), pprof.Labels(workerLabel, strconv.FormatInt(slot.id, 10)))
+	pprof.SetGoroutineLabels(ctx)
+	return slot, ctx
+}
+
+func (slot *workerSlot) unregister() {
+	if slot == nil {
+		return
+	}
+	workers.Lock()
+	delete(workers.byID, slot.id)
+	workers.Unlock()
+}
+
+func (slot *workerSlot) set(phase int32) {
+	if slot != nil {
+		atomic.StoreInt32(&slot.phase, phase)
+	}
+}
+
+// Phase marks the stage goroutine of ctx as being in the named phase, e.g.
+// "db query" or "decoding", until end is called, so that the profiler breaks
+// the processing time of the stage down by phase:
+//
+//	func enrich(ctx context.Context, inObj interface{}) (interface{}, error) {
+//		end := pipeline.Phase(ctx, "lookup")
+//		user, err := lookupUser(ctx, inObj.(*Event).UserID)
+//		end()
+//		...
+//	}
+//
+// ctx is the one passed to the stages added with AddStageCtx. Phase does
+// nothing unless profiling is enabled, see SetProfiling.
+func Phase(ctx context.Context, name string) (end func()) {
+	slot, _ := ctx.Value(slotKey{}).(*workerSlot)
+	if slot == nil {
+		return func() {}
+	}
+
+	prev := atomic.LoadInt32(&slot.phase)
+	slot.set(phaseIndex(name))
+	return func() {
+		slot.set(prev)
+	}
+}
+
+// Profile is the time breakdown of the stages of a pipeline measured by a
+// Profiler.
+type Profile struct {
+	Interval time.Duration
+	Stages   []StageProfile
+}
+
+// StageProfile is the time breakdown of a single stage: how many times its
+// goroutines were seen in each phase and, when busy, in each function.
+type StageProfile struct {
+	Name      string
+	Samples   int
+	Phases    map[string]int
+	Functions map[string]int // the innermost function outside of the runtime
+}
+
+// String formats the profile as a table of the share of time spent by every
+// stage in each of its phases and functions.
+func (p Profile) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "STAGE\tSAMPLES\tWHAT\tSHARE")
+	for _, s := range p.Stages {
+		for _, e := range sortedCounts(s.Phases) {
+			fmt.Fprintf(w, "%s\t%d\t%s\t%.1f%%\n", s.Name, s.Samples, e.name, 100*float64(e.n)/float64(s.Samples))
+		}
+		for _, e := range sortedCounts(s.Functions) {
+			fmt.Fprintf(w, "%s\t%d\t%s()\t%.1f%%\n", s.Name, s.Samples, e.name, 100*float64(e.n)/float64(s.Samples))
+		}
+	}
+	w.Flush()
+	return buf.String()
+}
+
+type namedCount struct {
+	name string
+	n    int
+}
+
+func sortedCounts(counts map[string]int) (sorted []namedCount) {
+	for name, n := range counts {
+		sorted = append(sorted, namedCount{name, n})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].n != sorted[j].n {
+			return sorted[i].n > sorted[j].n
+		}
+		return sorted[i].name < sorted[j].name
+	})
+	return
+}
+
+// Profiler periodically samples what the goroutines of the stages of a
+// pipeline are doing, which is cheaper and more targeted than a full CPU
+// profile when looking for the cause of a slow stage.
+type Profiler struct {
+	p        *Pipeline
+	interval time.Duration
+	stop     chan struct{}
+	stopped  chan struct{}
+
+	mu     sync.Mutex
+	stages map[string]*StageProfile
+}
+
+// StartProfiler starts sampling the stage goroutines of the pipeline every
+// interval until Stop is called. Only the runs with profiling enabled are
+// sampled, see SetProfiling. Sampling the functions being run takes a
+// profile of all the goroutines, so intervals should stay well above a
+// millisecond.
+func (p *Pipeline) StartProfiler(interval time.Duration) *Profiler {
+	pr := &Profiler{
+		p:        p,
+		interval: interval,
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+		stages:   map[string]*StageProfile{},
+	}
+	go pr.loop()
+	return pr
+}
+
+// Stop stops sampling and returns the final profile.
+func (pr *Profiler) Stop() Profile {
+	select {
+	case <-pr.stop:
+	default:
+		close(pr.stop)
+	}
+	<-pr.stopped
+	return pr.Profile()
+}
+
+// Profile returns the profile so far.
+func (pr *Profiler) Profile() Profile {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	profile := Profile{Interval: pr.interval}
+	for _, s := range pr.p.stageList() {
+		sp, ok := pr.stages[s.name]
+		if !ok {
+			continue
+		}
+		cp := StageProfile{Name: sp.Name, Samples: sp.Samples, Phases: map[string]int{}, Functions: map[string]int{}}
+		for k, v := range sp.Phases {
+			cp.Phases[k] = v
+		}
+		for k, v := range sp.Functions {
+			cp.Functions[k] = v
+		}
+		profile.Stages = append(profile.Stages, cp)
+	}
+	return profile
+}
+
+func (pr *Profiler) loop() {
+	defer close(pr.stopped)
+	ticker := time.NewTicker(pr.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pr.sample()
+		case <-pr.stop:
+			return
+		}
+	}
+}
+
+func (pr *Profiler) sample() {
+	type sampled struct {
+		stage string
+		id    int64
+		phase int32
+	}
+	var samples []sampled
+	busy := false
+	workers.Lock()
+	for id, slot := range workers.byID {
+		if slot.owner != pr.p {
+			continue
+		}
+		phase := atomic.LoadInt32(&slot.phase)
+		samples = append(samples, sampled{slot.stage, id, phase})
+		busy = busy || (phase != idlePhase && phase != pausedPhase && phase != sendingPhase)
+	}
+	workers.Unlock()
+
+	var functions map[int64]string
+	if busy {
+		functions = currentFunctions()
+	}
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	for _, s := range samples {
+		sp, ok := pr.stages[s.stage]
+		if !ok {
+			sp = &StageProfile{Name: s.stage, Phases: map[string]int{}, Functions: map[string]int{}}
+			pr.stages[s.stage] = sp
+		}
+		sp.Samples++
+		sp.Phases[phaseName(s.phase)]++
+		if fn, ok := functions[s.id]; ok && s.phase != idlePhase && s.phase != pausedPhase && s.phase != sendingPhase {
+			sp.Functions[fn]++
+		}
+	}
+}
+
+// currentFunctions returns the innermost function outside of the runtime
+// that every stage goroutine is running, by slot id.
+func currentFunctions() map[int64]string {
+	functions := map[int64]string{}
+	for id, stack := range workerStacks() {
+		// frames are lines of "#", pc, function+offset and file:line
+		for _, line := range bytes.Split(stack, []byte("\n")) {
+			fields := bytes.Split(line, []byte("\t"))
+			if len(fields) < 3 || string(fields[0]) != "#" {
+				continue
+			}
+			fn := fields[2]
+			if k := bytes.LastIndex(fn, []byte("+0x")); k > 0 {
+				fn = fn[:k]
+			}
+			if !bytes.HasPrefix(fn, []byte("runtime.")) && !bytes.HasPrefix(fn, []byte("runtime/pprof.")) {
+				functions[id] = string(fn)
+				break
+			}
+		}
+	}
+	return functions
+}
+
+// workerStacks returns the stack trace of every profiled stage goroutine, by
+// slot id, as found in the goroutine profile.
+func workerStacks() map[int64][]byte {
+	var buf bytes.Buffer
+	pprof.Lookup("goroutine").WriteTo(&buf, 1)
+
+	stacks := map[int64][]byte{}
+	prefix := []byte(`"` + workerLabel + `":"`)
+	// goroutines with the same stack and labels are grouped, and every stage
+	// goroutine has labels of its own
+	for _, g := range bytes.Split(buf.Bytes(), []byte("\n\n")) {
+		i := bytes.Index(g, prefix)
+		if i < 0 {
+			continue
+		}
+		label := g[i+len(prefix):]
+		if j := bytes.IndexByte(label, '"'); j >= 0 {
+			label = label[:j]
+		}
+		id, err := strconv.ParseInt(string(label), 10, 64)
+		if err != nil {
+			continue
+		}
+		stacks[id] = g
+	}
+	return stacks
+}
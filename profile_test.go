@@ -0,0 +1,34 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExamplePipeline_StartProfiler() {
+	p := pipeline.New()
+	p.SetProfiling(true)
+	p.AddStageCtx(func(ctx context.Context, inObj interface{}) (interface{}, error) {
+		end := pipeline.Phase(ctx, "lookup")
+		time.Sleep(20 * time.Millisecond)
+		end()
+		return inObj, nil
+	}, 1, pipeline.WithName("enrich"))
+
+	in := make(chan interface{}, 5)
+	for i := 0; i < 5; i++ {
+		in <- i
+	}
+	close(in)
+
+	profiler := p.StartProfiler(time.Millisecond)
+	<-p.Run(in)
+	profile := profiler.Stop()
+
+	for _, s := range profile.Stages {
+		fmt.Println(s.Name, s.Phases["lookup"] > 0, s.Functions["time.Sleep"] > 0)
+	}
+	// Output: enrich true true
+}
@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Progress is the progress of a run, as passed to the function set with
+// SetProgress.
+type Progress struct {
+	Elapsed time.Duration
+	Labels  Labels
+
+	// Processed counts the objects that left the run: out of the last
+	// stage, dropped or failed.
+	Processed uint64
+
+	// Stages holds the counters of every stage since the start of the run,
+	// including the objects of the overlapping runs as in a Report.
+	Stages []StageStats
+
+	// Done is set on the last call, once the run finished.
+	Done bool
+}
+
+type progressConfig struct {
+	fn       func(Progress)
+	every    uint64
+	interval time.Duration
+}
+
+// SetProgress sets a function receiving the Progress of every run every
+// `every` processed objects and every interval, either being zero to disable
+// it, and one last time when the run finishes, e.g. for a batch job to print
+// a progress bar:
+//
+//	p.SetProgress(func(pr pipeline.Progress) {
+//		fmt.Printf("\r%d/%d", pr.Processed, total)
+//	}, 1000, time.Second)
+//
+// The calls of a run don't overlap, but the function is called by the
+// goroutines of the pipeline and should return quickly.
+func (p *Pipeline) SetProgress(fn func(Progress), every uint64, interval time.Duration) {
+	p.checkMutable()
+	p.progress = &progressConfig{fn: fn, every: every, interval: interval}
+}
+
+// progressTracker reports the progress of a single run.
+type progressTracker struct {
+	processed uint64 // updated atomically
+
+	*progressConfig
+	p      *Pipeline
+	labels Labels
+	start  time.Time
+	before Stats
+
+	mu   sync.Mutex // serializes the calls
+	stop func()
+}
+
+// startProgress starts tracking the progress of a run, or returns nil if the
+// pipeline has no progress function.
+func (p *Pipeline) startProgress(run *runState) *progressTracker {
+	if p.progress == nil {
+		return nil
+	}
+	t := &progressTracker{progressConfig: p.progress, p: p, labels: run.labels, start: time.Now(), before: p.Stats()}
+	t.stop = func() {}
+	if t.interval > 0 {
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			ticker := time.NewTicker(t.interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					t.report(false)
+				case <-done:
+					return
+				}
+			}
+		}()
+		t.stop = func() {
+			close(done)
+			<-stopped
+		}
+	}
+	return t
+}
+
+// exited counts an object leaving the run.
+func (t *progressTracker) exited() {
+	if n := atomic.AddUint64(&t.processed, 1); t.every > 0 && n%t.every == 0 {
+		t.report(false)
+	}
+}
+
+// finish stops the periodic reports and reports the end of the run.
+func (t *progressTracker) finish() {
+	t.stop()
+	t.report(true)
+}
+
+func (t *progressTracker) report(done bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.fn(Progress{
+		Elapsed:   time.Since(t.start),
+		Labels:    t.labels,
+		Processed: atomic.LoadUint64(&t.processed),
+		Stages:    t.p.Stats().Sub(t.before).Stages,
+		Done:      done,
+	})
+}
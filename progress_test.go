@@ -0,0 +1,25 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_SetProgress() {
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	p.SetProgress(func(pr pipeline.Progress) {
+		fmt.Println(pr.Processed, pr.Done)
+	}, 4, 0)
+
+	in := make(chan interface{}, 10)
+	for i := 0; i < 10; i++ {
+		in <- i
+	}
+	close(in)
+	<-p.Run(in)
+
+	// Output: 4 false
+	// 8 false
+	// 10 true
+}
@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// defaultMaxRecordSize bounds the records of a ReaderSource by default.
+const defaultMaxRecordSize = 1 << 20
+
+// ReaderSource is a Source reading records from an io.Reader, such as a log
+// file or the standard input, e.g. for a log-processing Job:
+//
+//	src := pipeline.NewLineSource(os.Stdin)
+//	in, _ := src.Open(ctx)
+//	<-p.Run(in)
+//	if err := src.Err(); err != nil {
+//		log.Fatal(err)
+//	}
+type ReaderSource struct {
+	// MaxRecordSize bounds the size of a record, 1MiB if zero. Longer
+	// records fail the source.
+	MaxRecordSize int
+
+	r         io.Reader
+	split     bufio.SplitFunc
+	delimited bool // split with scanDelimited instead
+	text      bool
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewLineSource creates a ReaderSource emitting the lines of r as strings,
+// without their line endings.
+func NewLineSource(r io.Reader) *ReaderSource {
+	return &ReaderSource{r: r, split: bufio.ScanLines, text: true}
+}
+
+// NewRecordSource creates a ReaderSource emitting the length-delimited records
+// of r as []byte, each record being preceded by its length as a uvarint, as
+// written by protobuf's delimited writers.
+func NewRecordSource(r io.Reader) *ReaderSource {
+	return &ReaderSource{r: r, delimited: true}
+}
+
+// Open implements Source. The records are read from a goroutine of its own
+// until the end of the reader, an error or ctx being done, after which the
+// channel is closed, as is the reader if it is an io.Closer. A ReaderSource
+// can only be opened once.
+func (s *ReaderSource) Open(ctx context.Context) (<-chan interface{}, error) {
+	maxSize := s.MaxRecordSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxRecordSize
+	}
+	split := s.split
+	if s.delimited {
+		split = scanDelimited(maxSize)
+	}
+	scanner := bufio.NewScanner(s.r)
+	scanner.Buffer(nil, maxSize)
+	scanner.Split(split)
+
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+		if closer, ok := s.r.(io.Closer); ok {
+			defer closer.Close()
+		}
+		for scanner.Scan() {
+			var record interface{}
+			if s.text {
+				record = scanner.Text()
+			} else {
+				record = append([]byte(nil), scanner.Bytes()...)
+			}
+			select {
+			case ch <- record:
+			case <-ctx.Done():
+				return
+			}
+		}
+		s.mu.Lock()
+		s.err = scanner.Err()
+		s.mu.Unlock()
+	}()
+	return ch, nil
+}
+
+// Err returns the error that stopped the source, nil if it reached the end
+// of the reader. It must be called once the channel is closed.
+func (s *ReaderSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+var errTruncatedRecord = errors.New("pipeline: truncated record")
+
+// scanDelimited returns a bufio.SplitFunc for uvarint length-delimited
+// records of up to maxSize bytes.
+func scanDelimited(maxSize int) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		if atEOF && len(data) == 0 {
+			return
+		}
+		size, n := binary.Uvarint(data)
+		switch {
+		case n < 0:
+			return 0, nil, errors.New("pipeline: invalid record length")
+		case n == 0 && atEOF:
+			return 0, nil, errTruncatedRecord
+		case n == 0:
+			return // need more data
+		case size > uint64(maxSize):
+			// a corrupt length, which could overflow the end of the record
+			return 0, nil, fmt.Errorf("pipeline: record length %d exceeds %d bytes", size, maxSize)
+		}
+		if size <= uint64(len(data)-n) {
+			end := n + int(size)
+			return end, data[n:end], nil
+		}
+		if atEOF {
+			return 0, nil, errTruncatedRecord
+		}
+		return
+	}
+}
@@ -0,0 +1,47 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"strings"
+)
+
+func ExampleNewLineSource() {
+	p := pipeline.New()
+	p.AddStage(func(inObj interface{}) interface{} {
+		return strings.ToUpper(inObj.(string))
+	})
+	p.AddStage(printStage)
+
+	src := pipeline.NewLineSource(strings.NewReader("GET /\nPOST /login\r\nGET /cart"))
+	in, _ := src.Open(context.Background())
+	<-p.Run(in)
+	fmt.Println(src.Err())
+
+	// Output: GET /
+	// POST /LOGIN
+	// GET /CART
+	// <nil>
+}
+
+func ExampleNewRecordSource() {
+	p := pipeline.New()
+	p.AddStage(func(inObj interface{}) interface{} {
+		return string(inObj.([]byte))
+	})
+	p.AddStage(printStage)
+
+	// two records, then a corrupt length
+	data := []byte{5, 'h', 'e', 'l', 'l', 'o', 3, 'b', 'y', 'e'}
+	data = append(data, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01)
+	src := pipeline.NewRecordSource(bytes.NewReader(data))
+	in, _ := src.Open(context.Background())
+	<-p.Run(in)
+	fmt.Println(src.Err())
+
+	// Output: hello
+	// bye
+	// pipeline: record length 18446744073709551615 exceeds 1048576 bytes
+}
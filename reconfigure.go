@@ -0,0 +1,161 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// stagesMu guards the stages of all the pipelines against the changes made
+// while they run, see ReplaceStage and InsertStage. Like runsMu, it is global
+// since pipelines are values until they run.
+var stagesMu sync.RWMutex
+
+// stageList returns the stages of the pipeline. The returned slice is never
+// modified: ReplaceStage and InsertStage make a new one.
+func (p *Pipeline) stageList() []*stage {
+	stagesMu.RLock()
+	defer stagesMu.RUnlock()
+	return p.stages
+}
+
+// ReplaceStage replaces the function of a stage with fn, in the runs in
+// progress as well as in the ones started later, so that a long-running
+// service can roll out a new version of a transform without stopping its
+// input:
+//
+//	err := p.ReplaceStage(ctx, "enrich", enrichV2)
+//
+// The stage keeps its name, fan size, counters and options, such as its
+// retries or middleware. In every run, the objects the stage is processing
+// go through the previous function and are passed on first: the stage is
+// drained, then takes the next objects with fn, which back up in the
+// previous stage in the meantime. ReplaceStage returns once the stage of all
+// the runs is replaced, or with the error of ctx if it is done first, in
+// which case the replacement carries on regardless.
+//
+// Only the stages of a function can be replaced, not the raw, lifecycle,
+// worker, weighted or sink ones, nor the stages of a pipeline on a
+// WorkerPool. The synchronous runs in progress keep the previous function.
+func (p *Pipeline) ReplaceStage(ctx context.Context, name string, fn ProcessFnErr) error {
+	if p.pool != nil {
+		return errPooledReconfiguration
+	}
+	stagesMu.Lock()
+	i, err := p.controlledIndex(name)
+	if err != nil {
+		stagesMu.Unlock()
+		return err
+	}
+	prev := p.stages[i]
+	if prev.lifecycle != nil || prev.newWorker != nil || prev.weighted != nil || prev.flusher != nil {
+		stagesMu.Unlock()
+		return fmt.Errorf("pipeline: stage %s can't be replaced, only the stages of a function can", name)
+	}
+	s := *prev
+	s.process, s.processCtx, s.funcName = fn, nil, ""
+	stages := append([]*stage(nil), p.stages...)
+	stages[i] = &s
+	p.stages = stages
+	instances := prev.control.running()
+	stagesMu.Unlock()
+
+	return drain(ctx, instances, func(inst *stageInstance) {
+		run := inst.cfg.run
+		inst.cfg.process = p.wrapProcessFn(&s, run, s.processFn(run.ctx))
+		// only the profiled stages of a context have a function per
+		// goroutine among the ones that can be replaced
+		inst.cfg.newProcess = nil
+	})
+}
+
+// InsertStage adds a stage of fn after the stage named after, in the runs in
+// progress as well as in the ones started later. It takes the same options as
+// AddStageWithFanOut.
+//
+// In every run, the objects the stage after is processing are passed on to
+// the stage that followed it, then the next ones go through the new stage, so
+// that no object is dropped by the insertion. InsertStage returns once the
+// stage is inserted in all the runs, or with the error of ctx if it is done
+// first, in which case the insertion carries on regardless.
+//
+// The stage after can't be a raw one, and stages can't be inserted in a
+// pipeline on a WorkerPool. The synchronous runs in progress keep the
+// previous stages. Since the inserted stage only counts the objects it
+// processes, strict accounting may report the runs in progress during the
+// insertion, see SetStrictAccounting.
+func (p *Pipeline) InsertStage(ctx context.Context, after string, fn ProcessFnErr, fanSize uint64, opts ...StageOption) error {
+	if fanSize < 1 {
+		return fmt.Errorf("pipeline: invalid fan size %d", fanSize)
+	}
+	if p.pool != nil {
+		return errPooledReconfiguration
+	}
+	stagesMu.Lock()
+	i, err := p.controlledIndex(after)
+	if err != nil {
+		stagesMu.Unlock()
+		return err
+	}
+	s := p.newStage(&stage{process: fn, control: newStageControl(fanSize)}, opts)
+	stages := make([]*stage, 0, len(p.stages)+1)
+	stages = append(stages, p.stages[:i+1]...)
+	stages = append(stages, s)
+	p.stages = append(stages, p.stages[i+1:]...)
+	instances := p.stages[i].control.running()
+	stagesMu.Unlock()
+
+	return drain(ctx, instances, func(inst *stageInstance) {
+		if inst.closed {
+			// the run is over for the stage after
+			return
+		}
+		run := inst.cfg.run
+		spliced := make(chan interface{})
+		var inChan <-chan interface{} = spliced
+		if p.priorities > 0 {
+			inChan = prioritize(inChan, p.priorities, run.done)
+		}
+		p.startStage(s, run, inChan, inst.outChan)
+		inst.outChan = spliced
+	})
+}
+
+var errPooledReconfiguration = fmt.Errorf("pipeline: the stages of a pipeline on a WorkerPool can't be changed while it runs")
+
+// controlledIndex returns the index of the stage with the given name if it
+// isn't raw. It must be called with stagesMu held.
+func (p *Pipeline) controlledIndex(name string) (int, error) {
+	for i, s := range p.stages {
+		if s.name != name {
+			continue
+		}
+		if s.raw != nil {
+			return 0, fmt.Errorf("pipeline: %s is a raw stage", name)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("pipeline: no stage named %q", name)
+}
+
+// drain calls splice with every instance once its goroutines are done with
+// the objects they hold, holding off the next objects meanwhile. It returns
+// once all the instances are spliced, or with the error of ctx if it is done
+// first.
+func drain(ctx context.Context, instances []*stageInstance, splice func(inst *stageInstance)) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, inst := range instances {
+			inst.mu.Lock()
+			splice(inst)
+			inst.mu.Unlock()
+		}
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
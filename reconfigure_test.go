@@ -0,0 +1,50 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_ReplaceStage() {
+	ctx := context.Background()
+	out := make(chan interface{})
+	p := pipeline.New()
+	p.AddStage(func(inObj interface{}) interface{} {
+		return inObj.(int) * 2
+	}, pipeline.WithName("transform"))
+	p.AddStage(func(inObj interface{}) interface{} {
+		out <- inObj
+		return nil
+	}, pipeline.WithName("output"))
+
+	in := make(chan interface{})
+	done := p.Run(in)
+	in <- 1
+	fmt.Println(<-out)
+
+	// roll out a new version of the transform, then add a stage after it
+	p.ReplaceStage(ctx, "transform", func(inObj interface{}) (interface{}, error) {
+		return inObj.(int) * 10, nil
+	})
+	in <- 2
+	fmt.Println(<-out)
+	p.InsertStage(ctx, "transform", func(inObj interface{}) (interface{}, error) {
+		return inObj.(int) + 1, nil
+	}, 1, pipeline.WithName("adjust"))
+	in <- 3
+	fmt.Println(<-out)
+
+	close(in)
+	<-done
+	for _, s := range p.Stats().Stages {
+		fmt.Println(s.Name, s.In)
+	}
+
+	// Output: 2
+	// 20
+	// 31
+	// transform 3
+	// adjust 1
+	// output 3
+}
@@ -0,0 +1,253 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline/segment"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// recordedObject is a record of a recording: an object and the time it
+// entered the pipeline.
+type recordedObject struct {
+	Time   time.Time
+	Object interface{}
+}
+
+// Recorder writes the objects entering the runs of a pipeline, along with the
+// time they did, to a segment file with the default codec, see SetRecorder.
+// A RecordingSource replays them, e.g. to reproduce a production incident
+// locally:
+//
+//	rec, err := pipeline.NewRecorder("incident.rec", keys)
+//	...
+//	p.SetRecorder(rec)
+//	<-p.Run(in)
+//	rec.Close()
+//
+//	// later, on a laptop
+//	src := pipeline.NewRecordingSource("incident.rec")
+//	src.Keys = keys
+//	src.Speed = 10
+//	in, err := src.Open(ctx)
+//	...
+//	<-p.Run(in)
+type Recorder struct {
+	codec Codec
+	keys  segment.KeyProvider
+
+	mu  sync.Mutex
+	f   *os.File
+	w   *segment.Writer
+	err error
+}
+
+// NewRecorder creates the file at path and a Recorder writing to it. It fails
+// if the file exists, rather than overwriting an earlier recording. The
+// objects are encrypted at rest with keys, unless nil.
+func NewRecorder(path string, keys segment.KeyProvider) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return nil, err
+	}
+	codec := DefaultCodec()
+	w, err := segment.NewWriter(f, segment.Header{Kind: "recording", Codec: codec.Name(), Encrypted: keys != nil})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &Recorder{codec: codec, keys: keys, f: f, w: w}, nil
+}
+
+// SetRecorder makes the pipeline record the objects entering its runs with
+// rec, the payloads of the envelopes only, at the time of the Clock of the
+// pipeline. Objects are written one at a time as they come in, so a
+// recording is complete up to a crash of the process. Recording stops at the
+// first error, which Close returns; the runs carry on regardless.
+func (p *Pipeline) SetRecorder(rec *Recorder) {
+	p.checkMutable()
+	p.recorder = rec
+}
+
+// intake records the objects of inChan as they go through.
+func (r *Recorder) intake(inChan <-chan interface{}, clock Clock, done <-chan struct{}) <-chan interface{} {
+	outChan := make(chan interface{})
+	go func() {
+		defer close(outChan)
+		for {
+			select {
+			case obj, ok := <-inChan:
+				if !ok {
+					return
+				}
+				r.record(obj, clock.Now())
+				select {
+				case outChan <- obj:
+				case <-done:
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return outChan
+}
+
+func (r *Recorder) record(obj interface{}, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil || r.err != nil {
+		return
+	}
+	data, err := r.codec.Marshal(recordedObject{Time: t, Object: payload(obj)})
+	if err == nil && r.keys != nil {
+		data, err = segment.Seal(r.keys, data)
+	}
+	if err == nil {
+		err = r.w.Append(data)
+	}
+	r.err = err
+}
+
+// Close closes the file of the recording and returns the first error the
+// Recorder ran into, if any. Objects entering the pipeline afterwards aren't
+// recorded.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f != nil {
+		if err := r.f.Close(); r.err == nil {
+			r.err = err
+		}
+		r.f = nil
+	}
+	return r.err
+}
+
+// RecordingSource is a Source replaying the objects recorded by a Recorder,
+// in order.
+type RecordingSource struct {
+	// Speed paces the objects Speed times faster than they were recorded:
+	// 1 replays them at their original pace, 10 ten times faster. Zero
+	// replays them as fast as the pipeline takes them.
+	Speed float64
+
+	// New, if set, returns a pointer to a new value to decode each object
+	// into, which is what the source emits, e.g. new(Event). Otherwise the
+	// objects are decoded into interface{} values as the codec of the
+	// recording does it, JSON objects becoming maps for instance.
+	New func() interface{}
+
+	// Clock, if set, paces the objects instead of the system clock.
+	Clock Clock
+
+	// Keys decrypts the recordings encrypted at rest, see NewRecorder.
+	Keys segment.KeyProvider
+
+	path string
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewRecordingSource creates a RecordingSource replaying the recording at
+// path.
+func NewRecordingSource(path string) *RecordingSource {
+	return &RecordingSource{path: path}
+}
+
+// Open implements Source. It fails if the recording can't be opened. The
+// objects are read from a goroutine of its own until the end of the recording,
+// an error or ctx being done, after which the channel is closed.
+func (s *RecordingSource) Open(ctx context.Context) (<-chan interface{}, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := segment.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	codec, ok := LookupCodec(r.Header().Codec)
+	if !ok {
+		f.Close()
+		return nil, fmt.Errorf("pipeline: unknown codec %q", r.Header().Codec)
+	}
+	encrypted := r.Header().Encrypted
+	if encrypted && s.Keys == nil {
+		f.Close()
+		return nil, fmt.Errorf("pipeline: recording %q is encrypted and no keys are set", s.path)
+	}
+	clock := clockOrSystem(s.Clock)
+
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+		defer f.Close()
+		var first, start time.Time
+		for {
+			data, err := r.Next()
+			if err != nil {
+				if err != io.EOF {
+					s.setErr(err)
+				}
+				return
+			}
+			if encrypted {
+				if data, err = segment.Open(s.Keys, data); err != nil {
+					s.setErr(err)
+					return
+				}
+			}
+			rec := recordedObject{}
+			if s.New != nil {
+				rec.Object = s.New()
+			}
+			if err = codec.Unmarshal(data, &rec); err != nil {
+				s.setErr(err)
+				return
+			}
+
+			if s.Speed > 0 {
+				if first.IsZero() {
+					first, start = rec.Time, clock.Now()
+				}
+				due := start.Add(time.Duration(float64(rec.Time.Sub(first)) / s.Speed))
+				if d := due.Sub(clock.Now()); d > 0 {
+					t := clock.NewTimer(d)
+					select {
+					case <-t.C():
+					case <-ctx.Done():
+						t.Stop()
+						return
+					}
+				}
+			}
+			select {
+			case ch <- rec.Object:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+func (s *RecordingSource) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// Err returns the error that stopped the source, nil if it reached the end
+// of the recording. It must be called once the channel is closed.
+func (s *RecordingSource) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
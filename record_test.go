@@ -0,0 +1,70 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/segment"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func ExampleRecordingSource() {
+	dir, _ := ioutil.TempDir("", "recording")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "incident.rec")
+
+	// record the objects of a run, encrypted at rest
+	keys := segment.StaticKey("k1", make([]byte, 32))
+	rec, err := pipeline.NewRecorder(path, keys)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	p.SetRecorder(rec)
+	<-p.Run(pipeline.FromFunc(sequence(3)))
+	fmt.Println(rec.Close())
+
+	// and replay them, ten times faster
+	src := pipeline.NewRecordingSource(path)
+	src.Keys = keys
+	src.Speed = 10
+	src.New = func() interface{} {
+		return new(int)
+	}
+	in, err := src.Open(context.Background())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	replay := pipeline.New()
+	replay.AddStage(func(inObj interface{}) interface{} {
+		return squareStage(*inObj.(*int))
+	})
+	replay.AddStage(printStage)
+	<-replay.Run(in)
+	fmt.Println(src.Err())
+
+	// the recording isn't overwritten by the next one
+	_, err = pipeline.NewRecorder(path, keys)
+	fmt.Println(os.IsExist(err))
+
+	// Output: <nil>
+	// 1
+	// 4
+	// 9
+	// <nil>
+	// true
+}
+
+// sequence returns a function returning 1 to n, for FromFunc.
+func sequence(n int) func() (interface{}, bool) {
+	i := 0
+	return func() (interface{}, bool) {
+		i++
+		return i, i <= n
+	}
+}
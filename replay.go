@@ -0,0 +1,136 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// replayTick is how often Replay estimates the capacity of the pipeline once
+// a stage processed an object.
+const replayTick = time.Second
+
+// Replay merges a live channel with a backlog of historical objects so that
+// both can be fed into the pipeline, which is useful for reprocessing
+// campaigns that must not disturb production traffic:
+//
+//	<-p.Run(p.Replay(live, backlog, 0.1))
+//
+// Live objects always take priority: a backlog object is only forwarded when no
+// live object is waiting. In addition, backlog objects never take more than
+// share of the capacity of the pipeline, e.g. 0.1 for 10%. The capacity is the
+// throughput of the slowest stage, estimated from its fan size and the average
+// time its ProcessFn takes, see Stats, and raw stages aren't accounted for.
+// Until a stage processed an object, backlog objects are only held back by the
+// live ones. A share outside of (0, 1) removes the limit and only keeps the
+// live priority. The capacity is estimated again every second, and time is
+// measured on the Clock of the pipeline, see SetClock.
+//
+// The returned channel is closed once both live and backlog are closed. Either
+// of them may be nil.
+func (p *Pipeline) Replay(live, backlog <-chan interface{}, share float64) (outChan chan interface{}) {
+	return p.ReplayContext(context.Background(), live, backlog, share)
+}
+
+// ReplayContext is like Replay but stops once ctx is done, e.g. when the run
+// reading the returned channel is aborted, and closes the returned channel.
+func (p *Pipeline) ReplayContext(ctx context.Context, live, backlog <-chan interface{}, share float64) (outChan chan interface{}) {
+	clock := clockOrSystem(p.clock)
+	limited := share > 0 && share < 1
+
+	outChan = make(chan interface{})
+	go func() {
+		defer close(outChan)
+
+		next := clock.Now()
+		var capacity float64
+		var estimated time.Time
+		timer := clock.NewTimer(0)
+		timer.Stop() // armed only while the backlog is held back
+		defer timer.Stop()
+
+		for live != nil || backlog != nil {
+			// always drain whatever live traffic is ready before the backlog
+			select {
+			case obj, ok := <-live:
+				if !ok {
+					live = nil
+					continue
+				}
+				select {
+				case outChan <- obj:
+				case <-ctx.Done():
+					return
+				}
+				continue
+			default:
+			}
+
+			var backlogReady <-chan interface{}
+			var wait <-chan time.Time
+			if backlog != nil {
+				if d := next.Sub(clock.Now()); d > 0 {
+					resetTimer(timer, d)
+					wait = timer.C()
+				} else {
+					backlogReady = backlog
+				}
+			}
+
+			select {
+			case obj, ok := <-live:
+				if !ok {
+					live = nil
+					continue
+				}
+				select {
+				case outChan <- obj:
+				case <-ctx.Done():
+					return
+				}
+			case obj, ok := <-backlogReady:
+				if !ok {
+					backlog = nil
+					continue
+				}
+				now := clock.Now()
+				if now.After(next) {
+					next = now
+				}
+				if limited && (capacity == 0 || !now.Before(estimated.Add(replayTick))) {
+					capacity, estimated = p.capacity(), now
+				}
+				if limited && capacity > 0 {
+					next = next.Add(time.Duration(float64(time.Second) / (share * capacity)))
+				}
+				select {
+				case outChan <- obj:
+				case <-ctx.Done():
+					return
+				}
+			case <-wait:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return
+}
+
+// capacity estimates the throughput of the pipeline in objects per second as
+// the one of its slowest stage, or returns zero if no stage processed an
+// object yet.
+func (p *Pipeline) capacity() (perSecond float64) {
+	stages := p.stageList()
+	stats := statsOf(stages)
+	for i, s := range stages {
+		latency := stats.Stages[i].AvgLatency()
+		if s.raw != nil || latency <= 0 {
+			continue
+		}
+		c := float64(s.control.getFanSize()) / latency.Seconds()
+		if perSecond == 0 || c < perSecond {
+			perSecond = c
+		}
+	}
+	return
+}
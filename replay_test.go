@@ -0,0 +1,30 @@
+package pipeline_test
+
+import (
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_Replay() {
+	live := make(chan interface{}, 10)
+	live <- "live-1"
+	live <- "live-2"
+	close(live)
+
+	backlog := make(chan interface{}, 10)
+	backlog <- "old-1"
+	backlog <- "old-2"
+	backlog <- "old-3"
+	close(backlog)
+
+	p := pipeline.New()
+	p.AddStage(printStage)
+
+	// the backlog takes at most half of the capacity of the pipeline
+	<-p.Run(p.Replay(live, backlog, 0.5))
+
+	// Output: live-1
+	// live-2
+	// old-1
+	// old-2
+	// old-3
+}
@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// Report summarizes a finished run, e.g. for an orchestration system to gate
+// downstream tasks on data quality. It marshals to JSON as is.
+type Report struct {
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Duration float64       `json:"duration_seconds"`
+	Status   string        `json:"status"`          // "succeeded" or "aborted"
+	Error    string        `json:"error,omitempty"` // why the run was aborted
+	Labels   Labels        `json:"labels,omitempty"`
+	Stages   []StageReport `json:"stages"`
+}
+
+// StageReport holds the counters of a stage for a single run, along with a
+// sample of the errors it ran into.
+type StageReport struct {
+	Name           string   `json:"name"`
+	In             uint64   `json:"in"`
+	Out            uint64   `json:"out"`
+	Dropped        uint64   `json:"dropped"`
+	Errors         uint64   `json:"errors"`
+	DeadLettered   uint64   `json:"dead_lettered"`
+	ProcessingTime float64  `json:"processing_seconds"`
+	ErrorSamples   []string `json:"error_samples,omitempty"`
+}
+
+// WriteFile writes the report as indented JSON to path.
+func (r *Report) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+type reportConfig struct {
+	fn         func(*Report)
+	maxSamples int
+}
+
+// SetReport sets a function that receives a Report at the end of every run,
+// once its done channel is about to be closed. Up to maxErrorSamples errors
+// are kept per stage. For instance:
+//
+//	p.SetReport(func(r *pipeline.Report) {
+//		if err := r.WriteFile("report.json"); err != nil {
+//			log.Print(err)
+//		}
+//	}, 10)
+//
+// The counters of a report are the difference of the pipeline's Stats between
+// the start and the end of the run, so they include the objects of the other
+// runs of the pipeline that overlap with it.
+func (p *Pipeline) SetReport(fn func(*Report), maxErrorSamples int) {
+	p.checkMutable()
+	p.report = &reportConfig{fn: fn, maxSamples: maxErrorSamples}
+}
+
+// defaultErrorSamples is the number of error samples kept per stage for the
+// reports that aren't set up through SetReport.
+const defaultErrorSamples = 10
+
+// startReport prepares the report of a run and returns the function that
+// completes it, or nil if the run is not reported. The report goes to the
+// function set with SetReport and to onReport if not nil.
+func (p *Pipeline) startReport(run *runState, onReport func(*Report)) func(abortErr error) {
+	cfg := p.report
+	if cfg == nil && onReport == nil {
+		return nil
+	}
+	maxSamples := defaultErrorSamples
+	if cfg != nil {
+		maxSamples = cfg.maxSamples
+	}
+	run.samples = &errorSamples{max: maxSamples, byStage: map[string][]string{}}
+	start, before := time.Now(), p.Stats()
+
+	return func(abortErr error) {
+		end := time.Now()
+		r := &Report{
+			Start:    start,
+			End:      end,
+			Duration: end.Sub(start).Seconds(),
+			Status:   "succeeded",
+			Labels:   run.labels,
+		}
+		if abortErr != nil {
+			r.Status, r.Error = "aborted", abortErr.Error()
+		}
+		for _, st := range p.Stats().Sub(before).Stages {
+			r.Stages = append(r.Stages, StageReport{
+				Name:           st.Name,
+				In:             st.In,
+				Out:            st.Out,
+				Dropped:        st.Dropped,
+				Errors:         st.Errors,
+				DeadLettered:   st.DeadLettered,
+				ProcessingTime: st.ProcessingTime.Seconds(),
+				ErrorSamples:   run.samples.get(st.Name),
+			})
+		}
+		if cfg != nil {
+			cfg.fn(r)
+		}
+		if onReport != nil {
+			onReport(r)
+		}
+	}
+}
+
+// errorSamples keeps the first errors of every stage of a run.
+type errorSamples struct {
+	mu      sync.Mutex
+	max     int
+	byStage map[string][]string
+}
+
+func (e *errorSamples) add(err *ItemError) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.byStage[err.Stage]) < e.max {
+		e.byStage[err.Stage] = append(e.byStage[err.Stage], err.Error())
+	}
+}
+
+func (e *errorSamples) get(stage string) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.byStage[stage]
+}
@@ -0,0 +1,33 @@
+package pipeline_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_SetReport() {
+	p := pipeline.New()
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		if inObj.(int) < 0 {
+			return nil, errors.New("negative value")
+		}
+		return inObj, nil
+	}, 1, pipeline.WithName("validate"))
+	p.SetDeadLetter(func(*pipeline.ItemError) {})
+
+	p.SetReport(func(r *pipeline.Report) {
+		for _, s := range r.Stages {
+			fmt.Println(r.Status, s.Name, s.In, s.Out, s.Errors, s.DeadLettered, s.ErrorSamples)
+		}
+	}, 1)
+
+	in := make(chan interface{}, 4)
+	for _, i := range []int{1, -2, 3, -4} {
+		in <- i
+	}
+	close(in)
+	<-p.Run(in)
+
+	// Output: succeeded validate 4 2 2 2 [pipeline: validate failed: negative value]
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff computes the delay between the attempts of a retry. Delays grow
+// exponentially from Initial by Multiplier, are capped at Max and randomly
+// shortened by up to Jitter (a fraction between 0 and 1) so that failing
+// workers don't retry in lockstep.
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration // zero means no cap
+	Multiplier float64       // zero means 2
+	Jitter     float64
+}
+
+// Delay returns how long to wait after the given failed attempt, starting at 1.
+func (b Backoff) Delay(attempt int) time.Duration {
+	multiplier := b.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+
+	d := float64(b.Initial)
+	for i := 1; i < attempt; i++ {
+		d *= multiplier
+		if b.Max > 0 && d >= float64(b.Max) {
+			break
+		}
+	}
+	if b.Max > 0 && d > float64(b.Max) {
+		d = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		d -= d * b.Jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// WithRetry is a StageOption making a stage added with AddStageErr try each
+// object up to maxAttempts times, waiting according to backoff between the
+// attempts. Objects that still fail are sent to the dead-letter function.
+//
+// Stages that can't fail are unaffected.
+func WithRetry(maxAttempts int, backoff Backoff) StageOption {
+	return func(s *stage) {
+		s.retry = &retryPolicy{maxAttempts: maxAttempts, backoff: backoff}
+	}
+}
+
+type retryPolicy struct {
+	maxAttempts int
+	backoff     Backoff
+}
+
+// retriesExhausted is the error of an object that failed every attempt.
+type retriesExhausted struct {
+	attempts int
+	err      error
+}
+
+func (e *retriesExhausted) Error() string {
+	return e.err.Error()
+}
+
+// wrap retries fn, waiting on clock between the attempts. An object fails
+// with its last error should done be closed while waiting.
+func (r *retryPolicy) wrap(fn ProcessFnErr, clock Clock, done <-chan struct{}) ProcessFnErr {
+	return func(inObj interface{}) (outObj interface{}, err error) {
+		for attempt := 1; ; attempt++ {
+			if outObj, err = fn(inObj); err == nil {
+				return
+			}
+			if attempt >= r.maxAttempts {
+				if attempt > 1 {
+					err = &retriesExhausted{attempts: attempt, err: err}
+				}
+				return
+			}
+			if !sleep(clock, r.backoff.Delay(attempt), done) {
+				return
+			}
+		}
+	}
+}
+
+// retryCall calls fn up to maxAttempts times, until it succeeds or fails with
+// an error that retryable, if not nil, rejects. It waits on clock according to
+// backoff between the attempts, or for longer if asked to by a
+// RetryAfterError, and gives up with the last error should done be closed
+// meanwhile.
+func retryCall(maxAttempts int, backoff Backoff, retryable func(error) bool, clock Clock, done <-chan struct{}, fn func() error) (err error) {
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || attempt >= maxAttempts || retryable != nil && !retryable(err) {
+			return
+		}
+		delay := backoff.Delay(attempt)
+		if retryAfter, ok := err.(*RetryAfterError); ok && retryAfter.Delay > delay {
+			delay = retryAfter.Delay
+		}
+		if !sleep(clock, delay, done) {
+			return
+		}
+	}
+}
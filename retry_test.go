@@ -0,0 +1,47 @@
+package pipeline_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/pipelinetest"
+	"time"
+)
+
+func ExampleWithRetry() {
+	attempts := map[interface{}]int{}
+	lookup := func(inObj interface{}) (interface{}, error) {
+		attempts[inObj]++
+		if inObj == "missing" || attempts[inObj] < 2 {
+			return nil, errors.New("service unavailable")
+		}
+		return inObj, nil
+	}
+
+	clock := pipelinetest.NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	p := pipeline.New()
+	p.SetClock(clock)
+	p.AddStageErr(lookup, 1, pipeline.WithRetry(3, pipeline.Backoff{
+		Initial: time.Second,
+		Max:     time.Minute,
+		Jitter:  0.5,
+	}))
+	p.AddStage(printStage)
+	p.SetDeadLetter(func(err *pipeline.ItemError) {
+		fmt.Println(err.Obj, err)
+	})
+
+	ch := make(chan interface{}, 2)
+	ch <- "found"
+	ch <- "missing"
+	close(ch)
+	done := p.Run(ch)
+	for i := 0; i < 3; i++ {
+		clock.WaitTimers(1) // a retry is backing off
+		clock.Advance(time.Minute)
+	}
+	<-done
+
+	// Output: found
+	// missing pipeline: stage0 failed after 3 attempts: service unavailable
+}
@@ -0,0 +1,72 @@
+package pipeline
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// RetryAfterError is returned by a stage, typically a sink, when the service
+// it talks to asked for a pause, e.g. with an HTTP 429 or 503 response. All
+// the goroutines of the stage then hold off for Delay before processing their
+// next object, in every run of the pipeline, and so do the retries of
+// WithRetry.
+type RetryAfterError struct {
+	Delay time.Duration
+	Err   error
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%v (retry after %v)", e.Err, e.Delay)
+}
+
+// RetryAfter returns a *RetryAfterError if resp asks the client to slow down,
+// i.e. if its status is 429 Too Many Requests or 503 Service Unavailable, and
+// nil otherwise. The delay is taken from the Retry-After header, given either
+// in seconds or as a date; it is zero if the header is missing or invalid.
+func RetryAfter(resp *http.Response) error {
+	return RetryAfterWithClock(resp, SystemClock())
+}
+
+// RetryAfterWithClock is RetryAfter measuring the delay until a date on clock.
+func RetryAfterWithClock(resp *http.Response, clock Clock) error {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil
+	}
+
+	err := &RetryAfterError{Err: fmt.Errorf("pipeline: %s", resp.Status)}
+	header := resp.Header.Get("Retry-After")
+	if seconds, perr := strconv.Atoi(header); perr == nil && seconds > 0 {
+		err.Delay = time.Duration(seconds) * time.Second
+	} else if date, perr := http.ParseTime(header); perr == nil {
+		if d := date.Sub(clock.Now()); d > 0 {
+			err.Delay = d
+		}
+	}
+	return err
+}
+
+// holdProcessFn makes fn wait on clock while the stage is held off by a
+// RetryAfterError, and hold the stage off when fn returns one. It gives up
+// waiting when done is closed.
+func (ctl *stageControl) holdProcessFn(fn ProcessFnErr, clock Clock, done <-chan struct{}) ProcessFnErr {
+	return func(inObj interface{}) (outObj interface{}, err error) {
+		if until := atomic.LoadInt64(&ctl.holdUntil); until != 0 {
+			sleep(clock, time.Unix(0, until).Sub(clock.Now()), done)
+		}
+
+		outObj, err = fn(inObj)
+		if retryAfter, ok := err.(*RetryAfterError); ok && retryAfter.Delay > 0 {
+			until := clock.Now().Add(retryAfter.Delay).UnixNano()
+			for {
+				cur := atomic.LoadInt64(&ctl.holdUntil)
+				if cur >= until || atomic.CompareAndSwapInt64(&ctl.holdUntil, cur, until) {
+					break
+				}
+			}
+		}
+		return
+	}
+}
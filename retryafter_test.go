@@ -0,0 +1,43 @@
+package pipeline_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"net/http"
+	"time"
+)
+
+func ExampleRetryAfter() {
+	resp := &http.Response{
+		Status:     "429 Too Many Requests",
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": {"30"}},
+	}
+	fmt.Println(pipeline.RetryAfter(resp))
+
+	// Output: pipeline: 429 Too Many Requests (retry after 30s)
+}
+
+func ExampleRetryAfterError() {
+	var calls int
+	var lastCall time.Time
+	p := pipeline.New()
+	p.AddStageErr(func(inObj interface{}) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			// e.g. return pipeline.RetryAfter(resp) for an HTTP response
+			lastCall = time.Now()
+			return nil, &pipeline.RetryAfterError{Delay: 50 * time.Millisecond, Err: errors.New("slow down")}
+		}
+		fmt.Println("waited:", time.Since(lastCall) >= 50*time.Millisecond)
+		return inObj, nil
+	}, 1, pipeline.WithRetry(2, pipeline.Backoff{}))
+
+	in := make(chan interface{}, 1)
+	in <- "request"
+	close(in)
+	<-p.Run(in)
+
+	// Output: waited: true
+}
@@ -0,0 +1,209 @@
+package pipeline
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// SyncPolicy is when a file sink calls fsync on its files, trading
+// throughput for durability.
+type SyncPolicy int
+
+const (
+	// SyncNever leaves it to the operating system.
+	SyncNever SyncPolicy = iota
+	// SyncOnClose syncs files when they are rotated or closed.
+	SyncOnClose
+	// SyncOnFlush also syncs the current file when the sink is flushed.
+	SyncOnFlush
+	// SyncAlways syncs the current file after every record.
+	SyncAlways
+)
+
+// Rotation configures the files written by the file sinks, such as
+// NewCSVFileSink and NewJSONLinesFileSink. Sinks writing to a single writer
+// only use Sync.
+type Rotation struct {
+	// MaxSize, if set, starts a new file before a record would take the
+	// current one over MaxSize bytes. Files hold at least one record.
+	MaxSize int64
+
+	// MaxAge, if set, starts a new file for the first record written once
+	// the current one is older than MaxAge.
+	MaxAge time.Duration
+
+	// Compress gzips the files once they are complete, adding ".gz" to
+	// their names.
+	Compress bool
+
+	// Sync is when the files are synced to disk.
+	Sync SyncPolicy
+
+	// Clock, if set, measures MaxAge instead of the system clock.
+	Clock Clock
+}
+
+// rotatingFiles writes the records of a sink, buffered, to a single writer or
+// to a series of files rotated by size or age, each starting with a header.
+type rotatingFiles struct {
+	w       io.Writer // single writer, nil for files
+	pattern string    // of the file names, given their sequence number
+	header  func() ([]byte, error)
+
+	seq    int       // of the current file
+	file   io.Writer // current file, nil until the first record
+	out    *bufio.Writer
+	size   int64 // of the current file
+	count  int   // records in the current file
+	opened time.Time
+}
+
+// write writes a record, first starting a new file if the current one is
+// full or too old. Files hold at least one record.
+func (f *rotatingFiles) write(record []byte, r Rotation) error {
+	if f.file == nil || f.w == nil && f.count > 0 && (r.MaxSize > 0 && f.size+int64(len(record)) > r.MaxSize ||
+		r.MaxAge > 0 && clockOrSystem(r.Clock).Now().Sub(f.opened) >= r.MaxAge) {
+		if err := f.rotate(r); err != nil {
+			return err
+		}
+	}
+	if _, err := f.out.Write(record); err != nil {
+		return err
+	}
+	f.size += int64(len(record))
+	f.count++
+	if r.Sync >= SyncAlways {
+		return f.sync()
+	}
+	return nil
+}
+
+// rotate closes the current file, if any, and starts the next one with the
+// header.
+func (f *rotatingFiles) rotate(r Rotation) error {
+	if err := f.close(r); err != nil {
+		return err
+	}
+	if f.w != nil {
+		f.file = f.w
+	} else {
+		file, err := f.create(r)
+		if err != nil {
+			return err
+		}
+		f.file = file
+	}
+	f.out = bufio.NewWriter(f.file)
+	f.size, f.count, f.opened = 0, 0, clockOrSystem(r.Clock).Now()
+	if f.header == nil {
+		return nil
+	}
+	header, err := f.header()
+	if err == nil && len(header) > 0 {
+		_, err = f.out.Write(header)
+		f.size += int64(len(header))
+	}
+	return err
+}
+
+// create creates the file with the next sequence number not taken yet, so
+// that the files of earlier sinks, e.g. before a restart, are left alone.
+func (f *rotatingFiles) create(r Rotation) (*os.File, error) {
+	for ; ; f.seq++ {
+		name := fmt.Sprintf(f.pattern, f.seq)
+		if r.Compress {
+			if _, err := os.Stat(name + ".gz"); err == nil {
+				continue
+			}
+		}
+		file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		// unless the pattern has no sequence number and names a single file
+		if os.IsExist(err) && name != fmt.Sprintf(f.pattern, f.seq+1) {
+			continue
+		}
+		return file, err
+	}
+}
+
+// flush writes out the buffered records.
+func (f *rotatingFiles) flush(r Rotation) error {
+	if f.out == nil {
+		return nil
+	}
+	if err := f.out.Flush(); err != nil {
+		return err
+	}
+	if r.Sync >= SyncOnFlush {
+		return f.sync()
+	}
+	return nil
+}
+
+func (f *rotatingFiles) sync() error {
+	if err := f.out.Flush(); err != nil {
+		return err
+	}
+	if file, ok := f.file.(*os.File); ok && f.w == nil {
+		return file.Sync()
+	}
+	return nil
+}
+
+// close flushes the current file and, unless it is the single writer, closes
+// it and compresses it if needed.
+func (f *rotatingFiles) close(r Rotation) (err error) {
+	if f.file == nil {
+		return nil
+	}
+	defer func() { f.file, f.out = nil, nil }()
+	if err = f.out.Flush(); err != nil || f.w != nil {
+		return
+	}
+
+	file := f.file.(*os.File)
+	if r.Sync >= SyncOnClose {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	f.seq++
+	if err == nil && r.Compress {
+		err = gzipFile(file.Name(), r.Sync >= SyncOnClose)
+	}
+	return
+}
+
+// gzipFile replaces a file with its gzip compressed version, name.gz.
+func gzipFile(name string, sync bool) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(name + ".gz")
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && sync {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return err
+	}
+	return os.Remove(name)
+}
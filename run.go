@@ -0,0 +1,226 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Run is a handle on a single run of a pipeline, telling how it finished
+// rather than just that it did. See Pipeline.Start.
+type Run struct {
+	id     uint64
+	labels Labels
+	state  *runState
+	p      *Pipeline
+	done   chan struct{}
+	cancel context.CancelFunc
+	start  time.Time
+	before Stats
+
+	stopping chan struct{} // closed to stop taking objects
+	stopOnce sync.Once
+
+	mu    sync.Mutex
+	err   error
+	end   time.Time
+	stats []StageStats // frozen once the run finished
+}
+
+// RunStats holds the counters of every stage since the start of a run. As in
+// a Report, they include the objects of the other runs of the pipeline that
+// overlap with it.
+type RunStats struct {
+	Start    time.Time
+	Duration time.Duration // so far, or of the whole run once it finished
+	Stages   []StageStats
+	Labels   Labels // labels of the run, see WithLabels
+}
+
+// Start is like Run but returns a handle on the run.
+func (p *Pipeline) Start(inChan <-chan interface{}) *Run {
+	return p.StartContext(context.Background(), inChan)
+}
+
+// StartContext is like RunContext but returns a handle on the run.
+func (p *Pipeline) StartContext(ctx context.Context, inChan <-chan interface{}) *Run {
+	return p.runContext(ctx, inChan, nil)
+}
+
+// ID returns the identifier of the run, unique within its pipeline.
+func (r *Run) ID() uint64 {
+	return r.id
+}
+
+// Labels returns the labels of the run, see WithLabels.
+func (r *Run) Labels() Labels {
+	return r.labels
+}
+
+// Done returns a channel closed once the run finished, like the one returned
+// by Run.
+func (r *Run) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err returns the error the run was aborted with once it finished: the error
+// of its context, context.Canceled if Stop gave up on draining it, a
+// *RunLimitError, or the *ItemError it failed with, see FailFast. It returns
+// nil while the run is in progress and once it completed, including when it
+// was stopped and drained, even if some objects failed; see Stats for those.
+func (r *Run) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// Errors returns the errors of the run so far if its pipeline collects them,
+// see CollectErrors, or the error it failed with, see FailFast.
+func (r *Run) Errors() []*ItemError {
+	r.state.errMu.Lock()
+	defer r.state.errMu.Unlock()
+	return append([]*ItemError(nil), r.state.errors...)
+}
+
+// Stats returns the counters of the run so far, or of the whole run once it
+// finished.
+func (r *Run) Stats() RunStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.end.IsZero() {
+		return RunStats{Start: r.start, Duration: r.end.Sub(r.start), Stages: r.stats, Labels: r.labels}
+	}
+	return RunStats{Start: r.start, Duration: time.Since(r.start), Stages: r.p.Stats().Sub(r.before).Stages, Labels: r.labels}
+}
+
+// errRunStopped nacks the object taken from the input of a run that was
+// stopped before it could go into the first stage.
+var errRunStopped = errors.New("pipeline: run stopped")
+
+// Stop stops the run without affecting the other runs of the pipeline: it
+// stops reading the input of the run, lets the objects in flight go through
+// the stages and waits for the run to finish. If ctx is done first, the run is
+// aborted as if its context was canceled, see RunContext, and Stop returns the
+// error of ctx once it finished.
+func (r *Run) Stop(ctx context.Context) error {
+	r.stopOnce.Do(func() {
+		close(r.stopping)
+	})
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		<-r.done
+		return ctx.Err()
+	}
+}
+
+// intake forwards the objects of inChan until the run is stopped or aborted.
+func (r *Run) intake(inChan <-chan interface{}, done <-chan struct{}) <-chan interface{} {
+	outChan := make(chan interface{})
+	go func() {
+		defer close(outChan)
+		for {
+			select {
+			case obj, ok := <-inChan:
+				if !ok {
+					return
+				}
+				select {
+				case outChan <- obj:
+				case <-r.stopping:
+					settle(obj, errRunStopped)
+					return
+				case <-done:
+					return
+				}
+			case <-r.stopping:
+				return
+			case <-done:
+				return
+			}
+		}
+	}()
+	return outChan
+}
+
+// finish records how the run finished.
+func (r *Run) finish(abortErr error) {
+	r.mu.Lock()
+	r.err, r.end, r.stats = abortErr, time.Now(), r.p.Stats().Sub(r.before).Stages
+	r.mu.Unlock()
+	r.cancel()
+	r.p.removeRun(r)
+}
+
+// runsMu guards the runs of all the pipelines, which are values until they
+// run and so can't hold a mutex of their own.
+var runsMu sync.Mutex
+
+func (p *Pipeline) addRun(r *Run) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	if p.runs == nil {
+		p.runs = map[uint64]*Run{}
+	}
+	p.lastRunID++
+	r.id = p.lastRunID
+	p.runs[r.id] = r
+}
+
+func (p *Pipeline) removeRun(r *Run) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	delete(p.runs, r.id)
+}
+
+// runLabels returns the labels of the runs in progress that have some, by ID,
+// or nil if none has.
+func (p *Pipeline) runLabels() (labels map[uint64]Labels) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	for id, r := range p.runs {
+		if len(r.labels) == 0 {
+			continue
+		}
+		if labels == nil {
+			labels = map[uint64]Labels{}
+		}
+		labels[id] = r.labels
+	}
+	return
+}
+
+// ActiveRuns returns the runs of the pipeline in progress, started with Run,
+// RunContext, Start or StartContext, in the order in which they started.
+func (p *Pipeline) ActiveRuns() []*Run {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	runs := make([]*Run, 0, len(p.runs))
+	for _, r := range p.runs {
+		runs = append(runs, r)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].id < runs[j].id })
+	return runs
+}
+
+// LookupRun returns the run in progress with the given ID.
+func (p *Pipeline) LookupRun(id uint64) (*Run, bool) {
+	runsMu.Lock()
+	defer runsMu.Unlock()
+	r, ok := p.runs[id]
+	return r, ok
+}
+
+// StopRun stops the run in progress with the given ID, see Run.Stop.
+func (p *Pipeline) StopRun(ctx context.Context, id uint64) error {
+	r, ok := p.LookupRun(id)
+	if !ok {
+		return fmt.Errorf("pipeline: no run %d in progress", id)
+	}
+	return r.Stop(ctx)
+}
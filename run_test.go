@@ -0,0 +1,83 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExamplePipeline_Start() {
+	p := pipeline.New()
+	p.AddStage(squareStage, pipeline.WithName("square"))
+
+	in := make(chan interface{}, 3)
+	in <- 1
+	in <- 2
+	in <- 3
+	close(in)
+	run := p.Start(in)
+	<-run.Done()
+	fmt.Println(run.Err(), run.Stats().Stages[0].Out)
+
+	// the input of this run is never closed
+	run = p.Start(make(chan interface{}))
+	fmt.Println(run.Stop(context.Background()), run.Err())
+
+	// Output: <nil> 3
+	// <nil> <nil>
+}
+
+func ExamplePipeline_ActiveRuns() {
+	p := pipeline.New()
+	p.AddStage(squareStage)
+
+	ctx := context.Background()
+	for _, tenant := range []string{"acme", "globex"} {
+		p.StartContext(pipeline.WithLabels(ctx, pipeline.Labels{"tenant": tenant}), make(chan interface{}))
+	}
+	for _, run := range p.ActiveRuns() {
+		fmt.Println(run.ID(), run.Labels()["tenant"])
+	}
+
+	p.StopRun(ctx, 1)
+	fmt.Println(len(p.ActiveRuns()))
+	p.StopRun(ctx, 2)
+
+	// Output: 1 acme
+	// 2 globex
+	// 1
+}
+
+func ExampleRun_Stop() {
+	entered := make(chan struct{}, 3)
+	p := pipeline.New()
+	p.AddStage(func(inObj interface{}) interface{} {
+		entered <- struct{}{}
+		time.Sleep(50 * time.Millisecond)
+		return inObj
+	}, pipeline.WithName("slow"))
+
+	in := make(chan interface{}, 1)
+	in <- 0
+	stopped := p.Start(in)
+	<-entered
+
+	in = make(chan interface{}, 2)
+	in <- 1
+	in <- 2
+	close(in)
+	other := p.Start(in)
+
+	// not enough time to drain the object in flight
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	fmt.Println(stopped.Stop(ctx), stopped.Err())
+
+	// the other run wasn't affected
+	<-other.Done()
+	fmt.Println(other.Err())
+
+	// Output: context deadline exceeded context canceled
+	// <nil>
+}
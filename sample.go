@@ -0,0 +1,34 @@
+package pipeline
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+)
+
+// AddSampleStage adds a stage passing on a random fraction of the objects,
+// given by rate between 0 and 1, e.g. to thin out high-volume telemetry.
+// Every object is kept independently with probability rate. The Stats of the
+// stage count the sampled objects as Out and the others as Dropped.
+func (p *Pipeline) AddSampleStage(rate float64, opts ...StageOption) {
+	p.AddStage(func(inObj interface{}) interface{} {
+		if rand.Float64() >= rate {
+			return nil
+		}
+		return inObj
+	}, opts...)
+}
+
+// AddSystematicSampleStage is like AddSampleStage but keeps objects at
+// regular intervals rather than randomly: with a rate of 0.25, every 4th
+// object is kept. Exactly rate of the objects are kept, give or take one.
+func (p *Pipeline) AddSystematicSampleStage(rate float64, opts ...StageOption) {
+	var n uint64
+	p.AddStage(func(inObj interface{}) interface{} {
+		i := float64(atomic.AddUint64(&n, 1))
+		if math.Floor(i*rate) == math.Floor((i-1)*rate) {
+			return nil
+		}
+		return inObj
+	}, opts...)
+}
@@ -0,0 +1,26 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_AddSystematicSampleStage() {
+	p := pipeline.New()
+	p.AddSystematicSampleStage(0.25, pipeline.WithName("sample"))
+	p.AddStage(printStage)
+
+	in := make(chan interface{}, 10)
+	for i := 1; i <= 10; i++ {
+		in <- i
+	}
+	close(in)
+	<-p.Run(in)
+
+	s := p.Stats().Stages[0]
+	fmt.Println("sampled:", s.Out, "dropped:", s.Dropped)
+
+	// Output: 4
+	// 8
+	// sampled: 2 dropped: 8
+}
@@ -0,0 +1,105 @@
+package segment
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// KeyProvider supplies the AES keys used to encrypt segments at rest. Keys
+// are identified so that they can be rotated: records are always sealed with
+// the current key and opened with the key they were sealed with.
+type KeyProvider interface {
+	// CurrentKey returns the key to encrypt new records with.
+	CurrentKey() (id string, key []byte, err error)
+
+	// Key returns a key by id.
+	Key(id string) (key []byte, err error)
+}
+
+// StaticKey returns a KeyProvider with a single key, which must be 16, 24 or
+// 32 bytes long to select AES-128, AES-192 or AES-256.
+func StaticKey(id string, key []byte) KeyProvider {
+	return staticKey{id: id, key: key}
+}
+
+type staticKey struct {
+	id  string
+	key []byte
+}
+
+func (k staticKey) CurrentKey() (string, []byte, error) {
+	return k.id, k.key, nil
+}
+
+func (k staticKey) Key(id string) ([]byte, error) {
+	if id != k.id {
+		return nil, fmt.Errorf("segment: unknown key %q", id)
+	}
+	return k.key, nil
+}
+
+// Seal encrypts and authenticates a record with AES-GCM using the current key
+// of kp. The sealed record is laid out as:
+//
+//	key id len uint8 | key id | nonce | ciphertext and tag
+func Seal(kp KeyProvider, record []byte) ([]byte, error) {
+	id, key, err := kp.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+	if len(id) > 255 {
+		return nil, errors.New("segment: key id too long")
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := make([]byte, 1+len(id)+aead.NonceSize(), 1+len(id)+aead.NonceSize()+len(record)+aead.Overhead())
+	sealed[0] = byte(len(id))
+	copy(sealed[1:], id)
+	nonce := sealed[1+len(id):]
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(sealed, nonce, record, nil), nil
+}
+
+// Open decrypts a record sealed by Seal, returning ErrCorrupt if it was
+// tampered with.
+func Open(kp KeyProvider, sealed []byte) ([]byte, error) {
+	if len(sealed) < 1 || len(sealed) < 1+int(sealed[0]) {
+		return nil, ErrCorrupt
+	}
+	id := string(sealed[1 : 1+sealed[0]])
+	sealed = sealed[1+len(id):]
+
+	key, err := kp.Key(id)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrCorrupt
+	}
+	record, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return record, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,25 @@
+package segment_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hyfather/pipeline/segment"
+)
+
+func ExampleSeal() {
+	keys := segment.StaticKey("2026-10", bytes.Repeat([]byte{7}, 32))
+
+	sealed, _ := segment.Seal(keys, []byte("ssn=078-05-1120"))
+	fmt.Println(bytes.Contains(sealed, []byte("078-05-1120")))
+
+	record, _ := segment.Open(keys, sealed)
+	fmt.Println(string(record))
+
+	sealed[len(sealed)-1] ^= 1
+	_, err := segment.Open(keys, sealed)
+	fmt.Println(err)
+
+	// Output: false
+	// ssn=078-05-1120
+	// segment: corrupt data
+}
@@ -0,0 +1,453 @@
+package segment
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LogOptions configure a Log.
+type LogOptions struct {
+	// MaxSegmentSize is the size after which the current segment is sealed
+	// and a new one started. Zero means 64MB.
+	MaxSegmentSize int64
+
+	// MaxBytes bounds the total size of the sealed segments. The oldest ones
+	// are removed first. Zero means no size limit.
+	MaxBytes int64
+
+	// MaxAge is how long sealed segments are kept after their last write.
+	// Zero means no age limit.
+	MaxAge time.Duration
+
+	// Keys, if set, encrypts the records at rest with AES-GCM. Reading
+	// encrypted segments requires the keys they were written with.
+	Keys KeyProvider
+}
+
+// LogStats are the counters of a Log.
+type LogStats struct {
+	Segments          int
+	Bytes             int64
+	ReclaimedSegments uint64
+	ReclaimedBytes    uint64
+}
+
+// Log is an append-only sequence of segment files in a directory, the storage
+// underlying disk-backed queues. Segments are sealed once they reach a size,
+// and sealed segments are subject to size- and age-based retention as well as
+// compaction of individual records.
+type Log struct {
+	dir    string
+	header Header
+	opts   LogOptions
+
+	mu      sync.Mutex
+	seq     uint64
+	file    *os.File
+	writer  *Writer
+	written int64
+
+	// maintenance is held by retention and compaction, so that they don't
+	// work on the same segments at once
+	maintenance sync.Mutex
+
+	// reclaimed counters are updated atomically
+	reclaimedSegments uint64
+	reclaimedBytes    uint64
+}
+
+const segmentExt = ".seg"
+
+// OpenLog opens the log in dir, creating the directory if needed. Appends
+// always go to a new segment, leaving the existing ones untouched.
+func OpenLog(dir string, h Header, opts LogOptions) (*Log, error) {
+	if opts.MaxSegmentSize <= 0 {
+		opts.MaxSegmentSize = 64 << 20
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	h.Encrypted = opts.Keys != nil
+
+	l := &Log{dir: dir, header: h, opts: opts}
+	seqs, err := l.sealed()
+	if err != nil {
+		return nil, err
+	}
+	if len(seqs) > 0 {
+		l.seq = seqs[len(seqs)-1]
+	}
+	if err = l.rotate(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Append writes a record to the current segment, sealing it first if it is
+// full.
+func (l *Log) Append(record []byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return os.ErrClosed
+	}
+	if l.written >= l.opts.MaxSegmentSize {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+	if l.opts.Keys != nil {
+		var err error
+		if record, err = Seal(l.opts.Keys, record); err != nil {
+			return err
+		}
+	}
+	if err := l.writer.Append(record); err != nil {
+		return err
+	}
+	l.written += int64(8 + len(record))
+	return nil
+}
+
+// Sync commits the current segment to stable storage.
+func (l *Log) Sync() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return os.ErrClosed
+	}
+	return l.file.Sync()
+}
+
+// Close closes the current segment.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file, l.writer = nil, nil
+	return err
+}
+
+// Iterate calls fn with every record of the log, oldest first, stopping at the
+// first error. Truncated trailing records, as left by a crash, are skipped.
+func (l *Log) Iterate(fn func(record []byte) error) error {
+	return l.IterateWithHeader(func(h Header, record []byte) error {
+		return fn(record)
+	})
+}
+
+// IterateWithHeader is like Iterate, also passing fn the header of the segment
+// of every record, e.g. to decode it with the codec it was written with.
+func (l *Log) IterateWithHeader(fn func(h Header, record []byte) error) error {
+	l.mu.Lock()
+	seqs, err := l.sealed()
+	if l.file != nil {
+		seqs = append(seqs, l.seq)
+	}
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, seq := range seqs {
+		var h Header
+		err = l.iterateRaw(seq, func(sh Header) error {
+			h = sh
+			return nil
+		}, func(record, raw []byte) error {
+			return fn(h, record)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyRetention removes the sealed segments exceeding MaxAge or MaxBytes,
+// oldest first.
+func (l *Log) ApplyRetention() error {
+	l.maintenance.Lock()
+	defer l.maintenance.Unlock()
+	seqs, err := l.sealedLocked()
+	if err != nil {
+		return err
+	}
+
+	var infos []os.FileInfo
+	var total int64
+	for _, seq := range seqs {
+		info, err := os.Stat(l.path(seq))
+		if err != nil {
+			return err
+		}
+		infos = append(infos, info)
+		total += info.Size()
+	}
+
+	for i, info := range infos {
+		expired := l.opts.MaxAge > 0 && time.Since(info.ModTime()) > l.opts.MaxAge
+		oversized := l.opts.MaxBytes > 0 && total > l.opts.MaxBytes
+		if !expired && !oversized {
+			break
+		}
+		if err = os.Remove(l.path(seqs[i])); err != nil {
+			return err
+		}
+		total -= info.Size()
+		atomic.AddUint64(&l.reclaimedSegments, 1)
+		atomic.AddUint64(&l.reclaimedBytes, uint64(info.Size()))
+	}
+	return nil
+}
+
+// Compact rewrites the sealed segments keeping only the records for which keep
+// returns true, e.g. dropping dead letters that were successfully redriven.
+// Segments left empty are removed. Records are kept sealed, or not, as they
+// were, even if encryption was turned on or off since. Compacted segments keep
+// the time of their last write, which MaxAge is measured from.
+func (l *Log) Compact(keep func(record []byte) bool) error {
+	l.maintenance.Lock()
+	defer l.maintenance.Unlock()
+	seqs, err := l.sealedLocked()
+	if err != nil {
+		return err
+	}
+	for _, seq := range seqs {
+		if err = l.compactSegment(seq, keep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StartRetention applies retention every interval in the background, until
+// the returned function is called. If keep is not nil, the sealed segments
+// are compacted with it beforehand, see Compact. Errors are passed to onErr if
+// not nil.
+func (l *Log) StartRetention(interval time.Duration, keep func(record []byte) bool, onErr func(error)) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if keep != nil {
+					if err := l.Compact(keep); err != nil && onErr != nil {
+						onErr(err)
+					}
+				}
+				if err := l.ApplyRetention(); err != nil && onErr != nil {
+					onErr(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// Stats returns the current size of the log and how much was reclaimed by
+// retention and compaction so far.
+func (l *Log) Stats() (stats LogStats, err error) {
+	l.mu.Lock()
+	seqs, err := l.sealed()
+	if l.file != nil {
+		seqs = append(seqs, l.seq)
+	}
+	l.mu.Unlock()
+	if err != nil {
+		return
+	}
+
+	for _, seq := range seqs {
+		info, err := os.Stat(l.path(seq))
+		if err != nil {
+			return stats, err
+		}
+		stats.Segments++
+		stats.Bytes += info.Size()
+	}
+	stats.ReclaimedSegments = atomic.LoadUint64(&l.reclaimedSegments)
+	stats.ReclaimedBytes = atomic.LoadUint64(&l.reclaimedBytes)
+	return
+}
+
+// compactSegment compacts a sealed segment. It must be called with
+// maintenance held.
+func (l *Log) compactSegment(seq uint64, keep func(record []byte) bool) error {
+	path := l.path(seq)
+	before, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(l.dir, "compact")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	var w *Writer
+	var kept, dropped int
+	err = l.iterateRaw(seq, func(h Header) (err error) {
+		// the records are copied as stored, so is the header telling whether
+		// they are encrypted
+		w, err = NewWriter(tmp, h)
+		return
+	}, func(record, raw []byte) error {
+		if !keep(record) {
+			dropped++
+			return nil
+		}
+		kept++
+		return w.Append(raw)
+	})
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil || dropped == 0 {
+		return err
+	}
+
+	if kept == 0 {
+		if err = os.Remove(path); err != nil {
+			return err
+		}
+		atomic.AddUint64(&l.reclaimedSegments, 1)
+		atomic.AddUint64(&l.reclaimedBytes, uint64(before.Size()))
+		return nil
+	}
+	after, err := os.Stat(tmp.Name())
+	if err != nil {
+		return err
+	}
+	// the records are as old as they were, as far as MaxAge is concerned
+	if err = os.Chtimes(tmp.Name(), before.ModTime(), before.ModTime()); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	atomic.AddUint64(&l.reclaimedBytes, uint64(before.Size()-after.Size()))
+	return nil
+}
+
+// iterateRaw calls fn with both the decrypted and the stored form of every
+// record of a segment, after calling header, if set, with its header.
+func (l *Log) iterateRaw(seq uint64, header func(h Header) error, fn func(record, raw []byte) error) error {
+	f, err := os.Open(l.path(seq))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := NewReader(f)
+	if err != nil {
+		return fmt.Errorf("%s: %v", f.Name(), err)
+	}
+	encrypted := r.Header().Encrypted
+	if encrypted && l.opts.Keys == nil {
+		return fmt.Errorf("%s: encrypted segment and no keys", f.Name())
+	}
+	if header != nil {
+		if err = header(r.Header()); err != nil {
+			return err
+		}
+	}
+	for {
+		raw, err := r.Next()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %v", f.Name(), err)
+		}
+		record := raw
+		if encrypted {
+			if record, err = Open(l.opts.Keys, raw); err != nil {
+				return fmt.Errorf("%s: %v", f.Name(), err)
+			}
+		}
+		if err = fn(record, raw); err != nil {
+			return err
+		}
+	}
+}
+
+// rotate seals the current segment, if any, and starts a new one. It must be
+// called with mu held.
+func (l *Log) rotate() error {
+	if l.file != nil {
+		if err := l.file.Close(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(l.path(l.seq+1), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	w, err := NewWriter(f, l.header)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.seq++
+	l.file, l.writer, l.written = f, w, 0
+	return nil
+}
+
+func (l *Log) sealedLocked() ([]uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sealed()
+}
+
+// sealed lists the sequence numbers of the sealed segments in order. It must
+// be called with mu held.
+func (l *Log) sealed() (seqs []uint64, err error) {
+	infos, err := ioutil.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range infos {
+		name := info.Name()
+		if !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil || (l.file != nil && seq == l.seq) {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return
+}
+
+func (l *Log) path(seq uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
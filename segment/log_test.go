@@ -0,0 +1,122 @@
+package segment_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hyfather/pipeline/segment"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func ExampleLog_ApplyRetention() {
+	dir, _ := ioutil.TempDir("", "deadletters")
+	defer os.RemoveAll(dir)
+
+	// every record fills a segment, and at most two sealed segments are kept
+	l, _ := segment.OpenLog(dir, segment.Header{Kind: "deadletter", Codec: "json"},
+		segment.LogOptions{MaxSegmentSize: 1, MaxBytes: 72})
+	defer l.Close()
+
+	for _, r := range []string{"a", "b", "c", "d", "e"} {
+		l.Append([]byte(r))
+	}
+	l.ApplyRetention()
+
+	var records []string
+	l.Iterate(func(record []byte) error {
+		records = append(records, string(record))
+		return nil
+	})
+	stats, _ := l.Stats()
+	fmt.Println(records)
+	fmt.Printf("%+v\n", stats)
+
+	// Output: [c d e]
+	// {Segments:3 Bytes:108 ReclaimedSegments:2 ReclaimedBytes:72}
+}
+
+func ExampleLog_Compact() {
+	dir, _ := ioutil.TempDir("", "deadletters")
+	defer os.RemoveAll(dir)
+	h := segment.Header{Kind: "deadletter", Codec: "json"}
+
+	// records written in plain text, then encryption turned on
+	l, _ := segment.OpenLog(dir, h, segment.LogOptions{})
+	l.Append([]byte("a"))
+	l.Append([]byte("b"))
+	l.Close()
+	keys := segment.StaticKey("2026-10", bytes.Repeat([]byte{7}, 32))
+	l, _ = segment.OpenLog(dir, h, segment.LogOptions{Keys: keys})
+	defer l.Close()
+	l.Append([]byte("c"))
+
+	// the plain text segment stays in plain text once compacted
+	fmt.Println(l.Compact(func(record []byte) bool {
+		return string(record) != "a"
+	}))
+	var records []string
+	err := l.Iterate(func(record []byte) error {
+		records = append(records, string(record))
+		return nil
+	})
+	fmt.Println(records, err)
+
+	// Output: <nil>
+	// [b c] <nil>
+}
+
+func ExampleLog_Compact_maxAge() {
+	dir, _ := ioutil.TempDir("", "deadletters")
+	defer os.RemoveAll(dir)
+	h := segment.Header{Kind: "deadletter", Codec: "json"}
+	opts := segment.LogOptions{MaxAge: time.Hour}
+
+	// a segment last written two hours ago
+	l, _ := segment.OpenLog(dir, h, opts)
+	l.Append([]byte("a"))
+	l.Append([]byte("b"))
+	l.Close()
+	sealed, _ := filepath.Glob(filepath.Join(dir, "*.seg"))
+	old := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(sealed[0], old, old)
+	l, _ = segment.OpenLog(dir, h, opts)
+	defer l.Close()
+	l.Append([]byte("c"))
+
+	// compacting it doesn't make it any younger
+	l.Compact(func(record []byte) bool {
+		return string(record) != "a"
+	})
+	l.ApplyRetention()
+	var records []string
+	l.Iterate(func(record []byte) error {
+		records = append(records, string(record))
+		return nil
+	})
+	fmt.Println(records)
+
+	// Output: [c]
+}
+
+func ExampleLog_IterateWithHeader() {
+	dir, _ := ioutil.TempDir("", "events")
+	defer os.RemoveAll(dir)
+
+	// records written with one codec, then another
+	l, _ := segment.OpenLog(dir, segment.Header{Kind: "events", Codec: "json"}, segment.LogOptions{})
+	l.Append([]byte("a"))
+	l.Close()
+	l, _ = segment.OpenLog(dir, segment.Header{Kind: "events", Codec: "gob"}, segment.LogOptions{})
+	defer l.Close()
+	l.Append([]byte("b"))
+
+	l.IterateWithHeader(func(h segment.Header, record []byte) error {
+		fmt.Println(h.Codec, string(record))
+		return nil
+	})
+
+	// Output: json a
+	// gob b
+}
@@ -0,0 +1,229 @@
+// Package segment implements the versioned, CRC-checked file format shared by
+// all the disk-backed features of the pipeline package (dead letters,
+// checkpoints, recordings...).
+//
+// A segment starts with a header identifying the format version, the kind of
+// data it holds and the codec its records were serialized with:
+//
+//	magic "PLSG" | version uint16 | flags uint8 | kind len uint8 | kind | codec len uint8 | codec | crc32 uint32
+//
+// The flags byte only exists from version 2 on; its lowest bit tells whether
+// the records are encrypted (see Seal).
+//
+// followed by any number of records:
+//
+//	length uint32 | crc32 uint32 | payload
+//
+// All integers are big endian and checksums are CRC-32C (Castagnoli). A record
+// that was only partially written, say because the process crashed, is
+// reported as io.ErrUnexpectedEOF so that it can be told apart from corruption.
+package segment
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// Version is the version of the format written by this package. Segments of
+// older versions are still read.
+const Version = 2
+
+const flagEncrypted = 1 << 0
+
+// MaxRecordSize is the largest record accepted. Larger lengths are considered
+// corruption rather than an attempt to allocate them.
+const MaxRecordSize = 64 << 20
+
+var magic = [4]byte{'P', 'L', 'S', 'G'}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrBadMagic is returned when a file is not a segment at all.
+	ErrBadMagic = errors.New("segment: not a segment file")
+
+	// ErrUnsupportedVersion is returned for segments written by a newer
+	// version of the format.
+	ErrUnsupportedVersion = errors.New("segment: unsupported version")
+
+	// ErrCorrupt is returned when a checksum doesn't match its data.
+	ErrCorrupt = errors.New("segment: corrupt data")
+)
+
+// Header describes the content of a segment.
+type Header struct {
+	Version   uint16 // set by the Writer
+	Kind      string // e.g. "deadletter" or "checkpoint"
+	Codec     string // name of the pipeline.Codec the records are encoded with
+	Encrypted bool   // whether the records are sealed with Seal
+}
+
+// Writer appends records to a segment.
+type Writer struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewWriter writes the segment header to w and returns a Writer appending
+// records after it. Kind and codec names are limited to 255 bytes.
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	if len(h.Kind) > 255 || len(h.Codec) > 255 {
+		return nil, errors.New("segment: header field too long")
+	}
+
+	buf := append([]byte{}, magic[:]...)
+	buf = append(buf, 0, 0)
+	binary.BigEndian.PutUint16(buf[4:], Version)
+	var flags byte
+	if h.Encrypted {
+		flags |= flagEncrypted
+	}
+	buf = append(buf, flags)
+	buf = append(buf, byte(len(h.Kind)))
+	buf = append(buf, h.Kind...)
+	buf = append(buf, byte(len(h.Codec)))
+	buf = append(buf, h.Codec...)
+	buf = appendUint32(buf, crc32.Checksum(buf, crcTable))
+
+	if _, err := w.Write(buf); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w}, nil
+}
+
+// Append writes a single record. Each record is written with a single Write
+// call on the underlying writer.
+func (w *Writer) Append(record []byte) error {
+	if len(record) > MaxRecordSize {
+		return errors.New("segment: record too large")
+	}
+	w.buf = appendUint32(w.buf[:0], uint32(len(record)))
+	w.buf = appendUint32(w.buf, crc32.Checksum(record, crcTable))
+	w.buf = append(w.buf, record...)
+	_, err := w.w.Write(w.buf)
+	return err
+}
+
+// Reader reads the records of a segment.
+type Reader struct {
+	r      io.Reader
+	header Header
+}
+
+// NewReader reads and validates the segment header from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	fixed := make([]byte, 6)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrBadMagic
+		}
+		return nil, err
+	}
+	if fixed[0] != magic[0] || fixed[1] != magic[1] || fixed[2] != magic[2] || fixed[3] != magic[3] {
+		return nil, ErrBadMagic
+	}
+	version := binary.BigEndian.Uint16(fixed[4:])
+	if version > Version {
+		return nil, ErrUnsupportedVersion
+	}
+
+	raw := fixed
+	var flags byte
+	var err error
+	if version >= 2 {
+		if flags, raw, err = readByte(r, raw); err != nil {
+			return nil, err
+		}
+	}
+	kindLen, raw, err := readByte(r, raw)
+	if err != nil {
+		return nil, err
+	}
+	kind, raw, err := readString(r, raw, int(kindLen))
+	if err != nil {
+		return nil, err
+	}
+	codecLen, raw, err := readByte(r, raw)
+	if err != nil {
+		return nil, err
+	}
+	codec, raw, err := readString(r, raw, int(codecLen))
+	if err != nil {
+		return nil, err
+	}
+
+	sum := make([]byte, 4)
+	if _, err = io.ReadFull(r, sum); err != nil {
+		return nil, noEOF(err)
+	}
+	if binary.BigEndian.Uint32(sum) != crc32.Checksum(raw, crcTable) {
+		return nil, ErrCorrupt
+	}
+
+	return &Reader{r: r, header: Header{
+		Version:   version,
+		Kind:      kind,
+		Codec:     codec,
+		Encrypted: flags&flagEncrypted != 0,
+	}}, nil
+}
+
+// Header returns the header of the segment.
+func (r *Reader) Header() Header {
+	return r.header
+}
+
+// Next returns the next record. It returns io.EOF at the end of the segment,
+// io.ErrUnexpectedEOF for a truncated trailing record and ErrCorrupt when a
+// record doesn't match its checksum.
+func (r *Reader) Next() (record []byte, err error) {
+	prefix := make([]byte, 8)
+	if _, err = io.ReadFull(r.r, prefix); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(prefix)
+	if length > MaxRecordSize {
+		return nil, ErrCorrupt
+	}
+
+	record = make([]byte, length)
+	if _, err = io.ReadFull(r.r, record); err != nil {
+		return nil, noEOF(err)
+	}
+	if binary.BigEndian.Uint32(prefix[4:]) != crc32.Checksum(record, crcTable) {
+		return nil, ErrCorrupt
+	}
+	return record, nil
+}
+
+func readByte(r io.Reader, raw []byte) (b byte, _ []byte, err error) {
+	buf := make([]byte, 1)
+	if _, err = io.ReadFull(r, buf); err != nil {
+		return 0, raw, noEOF(err)
+	}
+	return buf[0], append(raw, buf[0]), nil
+}
+
+func readString(r io.Reader, raw []byte, n int) (s string, _ []byte, err error) {
+	b := make([]byte, n)
+	if _, err = io.ReadFull(r, b); err != nil {
+		return "", raw, noEOF(err)
+	}
+	return string(b), append(raw, b...), nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var tmp [4]byte
+	binary.BigEndian.PutUint32(tmp[:], v)
+	return append(b, tmp[:]...)
+}
+
+// noEOF turns a clean EOF in the middle of a structure into an unexpected one.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
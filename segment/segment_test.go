@@ -0,0 +1,37 @@
+package segment_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/hyfather/pipeline/segment"
+	"io"
+)
+
+func Example() {
+	var buf bytes.Buffer
+	w, _ := segment.NewWriter(&buf, segment.Header{Kind: "deadletter", Codec: "json"})
+	w.Append([]byte(`{"id":1}`))
+	w.Append([]byte(`{"id":2}`))
+
+	// flip a bit in the payload of the last record
+	data := buf.Bytes()
+	data[len(data)-2] ^= 1
+
+	r, _ := segment.NewReader(bytes.NewReader(data))
+	fmt.Printf("%+v\n", r.Header())
+	for {
+		record, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Println(err)
+			break
+		}
+		fmt.Println(string(record))
+	}
+
+	// Output: {Version:2 Kind:deadletter Codec:json Encrypted:false}
+	// {"id":1}
+	// segment: corrupt data
+}
@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrShed is the error with which the objects shed by a pipeline are
+// negatively acknowledged, see SetLoadShedding.
+var ErrShed = errors.New("pipeline: load shed")
+
+// LoadShedding configures a pipeline to shed load rather than queue it when it
+// is saturated, see SetLoadShedding.
+type LoadShedding struct {
+	// MaxInFlight, if set, sheds the objects arriving while that many
+	// objects are in the stages of the pipeline, across its runs. The
+	// objects held by raw stages aren't counted.
+	MaxInFlight int
+
+	// MaxLatency, if set, sheds the objects arriving while the end-to-end
+	// latency of the pipeline, averaged over the last objects that left it,
+	// is above MaxLatency. It enables envelopes.
+	MaxLatency time.Duration
+
+	// OnShed, if set, is called with every object shed.
+	OnShed func(obj interface{})
+}
+
+// SetLoadShedding makes the pipeline drop the objects arriving in a run while
+// it is saturated, as defined by ls, so that it degrades gracefully rather
+// than piling objects up in its input. Objects are still taken from the input
+// as fast as they come, and the shed ones are negatively acknowledged with
+// ErrShed, see Acknowledger, so that e.g. a broker redelivers them later, and
+// passed to ls.OnShed. Shed objects don't reach the first stage, so they
+// don't appear in Stats.
+//
+// Unlike SetMaxInFlight, which makes the input wait, load shedding never
+// blocks the producer of the input.
+func (p *Pipeline) SetLoadShedding(ls LoadShedding) {
+	p.checkMutable()
+	p.shedding = &ls
+	if ls.MaxLatency > 0 {
+		p.envelopes = true
+	}
+}
+
+// shedder decides which objects of a run to shed.
+type shedder struct {
+	LoadShedding
+	p     *Pipeline
+	clock Clock
+
+	mu      sync.Mutex
+	latency float64 // moving average, in nanoseconds
+}
+
+// latencyWeight is the weight of the latest object in the moving average.
+const latencyWeight = 0.1
+
+// shed sheds the objects of inChan while the pipeline is saturated.
+func (sh *shedder) shed(inChan <-chan interface{}, done <-chan struct{}) <-chan interface{} {
+	outChan := make(chan interface{})
+	go func() {
+		defer close(outChan)
+		for {
+			var obj interface{}
+			select {
+			case o, ok := <-inChan:
+				if !ok {
+					return
+				}
+				obj = o
+			case <-done:
+				return
+			}
+
+			if sh.saturated() {
+				if sh.OnShed != nil {
+					sh.OnShed(payload(obj))
+				}
+				settle(obj, ErrShed)
+				continue
+			}
+			select {
+			case outChan <- obj:
+			case <-done:
+				return
+			}
+		}
+	}()
+	return outChan
+}
+
+func (sh *shedder) saturated() bool {
+	inFlight := sh.p.inFlight()
+	if sh.MaxInFlight > 0 && inFlight >= uint64(sh.MaxInFlight) {
+		return true
+	}
+	if sh.MaxLatency <= 0 || inFlight == 0 {
+		// with nothing in flight, the latency can't come down: let objects
+		// in to measure it again
+		return false
+	}
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return time.Duration(sh.latency) > sh.MaxLatency
+}
+
+// observe records the latency of an object leaving the run.
+func (sh *shedder) observe(obj interface{}) {
+	env, ok := obj.(*Envelope)
+	if !ok || sh.MaxLatency <= 0 || env.Ingested.IsZero() {
+		return
+	}
+	latency := float64(sh.clock.Now().Sub(env.Ingested))
+	sh.mu.Lock()
+	if sh.latency == 0 {
+		sh.latency = latency
+	} else {
+		sh.latency += latencyWeight * (latency - sh.latency)
+	}
+	sh.mu.Unlock()
+}
+
+// inFlight returns the number of objects in the stages of the pipeline,
+// except the raw ones.
+func (p *Pipeline) inFlight() (n uint64) {
+	for _, s := range p.stageList() {
+		c := s.counters
+		done := atomic.LoadUint64(&c.errors) + atomic.LoadUint64(&c.dropped) + atomic.LoadUint64(&c.out)
+		if in := atomic.LoadUint64(&c.in); in > done {
+			n += in - done
+		}
+	}
+	return
+}
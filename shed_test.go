@@ -0,0 +1,37 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExamplePipeline_SetLoadShedding() {
+	release := make(chan struct{})
+	p := pipeline.New()
+	p.AddStageWithFanOut(func(inObj interface{}) interface{} {
+		<-release // a slow dependency
+		return inObj
+	}, 2)
+	p.SetLoadShedding(pipeline.LoadShedding{
+		MaxInFlight: 2,
+		OnShed: func(obj interface{}) {
+			fmt.Println("shed", obj)
+		},
+	})
+
+	ch := make(chan interface{})
+	done := p.Run(ch)
+	for i := 1; i <= 4; i++ {
+		ch <- i
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(ch)
+	close(release)
+	<-done
+	fmt.Println("processed", p.Stats().Stages[0].Out)
+
+	// Output: shed 3
+	// shed 4
+	// processed 2
+}
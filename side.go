@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"fmt"
+)
+
+// sideObject is an object sent to a side output, possibly along with the
+// object passed on to the next stage.
+type sideObject struct {
+	name string
+	obj  interface{}
+	main interface{}
+}
+
+// Side makes a ProcessFn send obj to the named side output of the pipeline
+// rather than to the next stage, e.g. the objects failing validation:
+//
+//	func validate(inObj interface{}) interface{} {
+//		if err := check(inObj); err != nil {
+//			return pipeline.Side("invalid", inObj)
+//		}
+//		return inObj
+//	}
+//
+// For the next stage, and in Stats, the object is dropped. Objects sent to a
+// side output without a pipeline attached are dropped.
+func Side(name string, obj interface{}) interface{} {
+	return &sideObject{name: name, obj: obj}
+}
+
+// WithSide makes a ProcessFn send obj to the named side output in addition to
+// passing main on to the next stage, e.g. for auditing. Calls can be nested to
+// send objects to several side outputs.
+func WithSide(main interface{}, name string, obj interface{}) interface{} {
+	return &sideObject{name: name, obj: obj, main: main}
+}
+
+// AttachSideOutput attaches a pipeline processing the objects sent to the
+// named side output by the stages. Every run of the pipeline runs sub once
+// with the objects sent to the side output during the run, with the same
+// context; the run is done once sub is.
+func (p *Pipeline) AttachSideOutput(name string, sub *Pipeline) {
+	p.checkMutable()
+	if p.sideOutputs == nil {
+		p.sideOutputs = map[string]*Pipeline{}
+	}
+	p.sideOutputs[name] = sub
+}
+
+// startSides runs the side output pipelines of a run.
+func (p *Pipeline) startSides(run *runState) {
+	if len(p.sideOutputs) == 0 {
+		return
+	}
+	run.sides = map[string]chan interface{}{}
+	for name, sub := range p.sideOutputs {
+		ch := make(chan interface{})
+		run.sides[name] = ch
+		run.sidesDone = append(run.sidesDone, sub.RunContext(run.ctx, ch))
+	}
+}
+
+// finishSides waits for the side output pipelines once the stages stopped.
+func (run *runState) finishSides() {
+	for _, ch := range run.sides {
+		close(ch)
+	}
+	for _, done := range run.sidesDone {
+		<-done
+	}
+}
+
+// routeSides sends the objects returned with Side or WithSide by fn to their
+// side outputs.
+func (run *runState) routeSides(fn ProcessFnErr) ProcessFnErr {
+	return func(inObj interface{}) (interface{}, error) {
+		outObj, err := fn(inObj)
+		for err == nil {
+			side, ok := outObj.(*sideObject)
+			if !ok {
+				break
+			}
+			if ch, ok := run.sides[side.name]; ok {
+				select {
+				case ch <- side.obj:
+				case <-run.done:
+					return nil, fmt.Errorf("pipeline: side output %s: %v", side.name, run.ctx.Err())
+				}
+			}
+			outObj = side.main
+		}
+		return outObj, err
+	}
+}
@@ -0,0 +1,36 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_AttachSideOutput() {
+	invalid := pipeline.New()
+	invalid.AddStage(func(inObj interface{}) interface{} {
+		fmt.Println("invalid:", inObj)
+		return inObj
+	})
+
+	p := pipeline.New()
+	p.AddStage(func(inObj interface{}) interface{} {
+		if inObj.(int) < 0 {
+			return pipeline.Side("invalid", inObj)
+		}
+		return inObj
+	})
+	p.AddStage(squareStage)
+	p.AddStage(printStage)
+	p.AttachSideOutput("invalid", &invalid)
+
+	in := make(chan interface{}, 3)
+	in <- 2
+	in <- -1
+	in <- 3
+	close(in)
+	<-p.Run(in)
+
+	// Unordered output: 4
+	// invalid: -1
+	// 9
+}
@@ -0,0 +1,235 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Sink is a destination for the objects coming out of a pipeline, such as a
+// database, a search index or a cache.
+type Sink interface {
+	Write(obj interface{}) error
+}
+
+// SinkFunc adapts an ordinary function to the Sink interface.
+type SinkFunc func(obj interface{}) error
+
+// Write calls f(obj).
+func (f SinkFunc) Write(obj interface{}) error {
+	return f(obj)
+}
+
+// ContextSink is implemented by the sinks that can give up on a write once ctx
+// is done. The stages added with AddSink pass them the context of the run.
+type ContextSink interface {
+	Sink
+	WriteContext(ctx context.Context, obj interface{}) error
+}
+
+// Flushable is implemented by the sinks that buffer objects internally, e.g.
+// to write them in batches. Flush writes out the buffered objects, giving up
+// once ctx is done.
+type Flushable interface {
+	Flush(ctx context.Context) error
+}
+
+// AddSink adds a stage that writes every object to the given Sink. Objects
+// that are written successfully are passed on, the others are sent to the
+// dead-letter function. See AddStageWithFanOut for the meaning of fanSize and
+// opts.
+//
+// If the sink is a ContextSink, it is passed the context of the run, which is
+// canceled when the run is aborted. If the sink is Flushable, it is flushed by
+// Pipeline.Flush.
+func (p *Pipeline) AddSink(s Sink, fanSize uint64, opts ...StageOption) {
+	st := &stage{control: newStageControl(fanSize)}
+	if cs, ok := s.(ContextSink); ok {
+		st.processCtx = func(ctx context.Context, inObj interface{}) (interface{}, error) {
+			if err := cs.WriteContext(ctx, inObj); err != nil {
+				return nil, err
+			}
+			return inObj, nil
+		}
+	} else {
+		st.process = func(inObj interface{}) (interface{}, error) {
+			if err := s.Write(inObj); err != nil {
+				return nil, err
+			}
+			return inObj, nil
+		}
+	}
+	st.flusher, _ = s.(Flushable)
+	p.addStage(st, opts...)
+}
+
+// Flush flushes the Flushable sinks of the pipeline in order, so that buffers
+// are not lost on shutdown. It should be called once the runs are done; Main
+// does so. All the sinks are flushed even if some fail; the first error is
+// returned.
+func (p *Pipeline) Flush(ctx context.Context) (err error) {
+	for _, s := range p.stageList() {
+		if s.flusher == nil {
+			continue
+		}
+		if ferr := s.flusher.Flush(ctx); ferr != nil && err == nil {
+			err = fmt.Errorf("pipeline: flush %s: %v", s.name, ferr)
+		}
+	}
+	return
+}
+
+// FanoutTarget is one of the destinations of a SinkFanout.
+type FanoutTarget struct {
+	Name string
+	Sink Sink
+
+	// MaxAttempts is the number of times a write is tried before the object
+	// is given up on for this target. Values below 1 mean a single attempt.
+	MaxAttempts int
+
+	// Backoff is how long to wait between two attempts.
+	Backoff time.Duration
+
+	// Clock, if set, measures the Backoff instead of the system clock.
+	Clock Clock
+
+	// DeadLetter, if set, is called with the objects that exhausted their
+	// attempts along with the last error.
+	DeadLetter func(obj interface{}, err error)
+
+	// delivered and deadLettered are updated atomically
+	delivered    uint64
+	deadLettered uint64
+}
+
+// FanoutTargetStats holds the counters of a single FanoutTarget.
+type FanoutTargetStats struct {
+	Name         string
+	Delivered    uint64
+	DeadLettered uint64
+}
+
+// SinkFanout is a Sink that delivers every object to several destinations
+// (say a database, a search index and a cache). Each destination retries and
+// dead-letters independently of the others, and a write only succeeds once
+// a quorum of destinations accepted the object.
+type SinkFanout struct {
+	quorum  int
+	targets []*FanoutTarget
+}
+
+// NewSinkFanout creates a SinkFanout delivering to targets. A quorum of zero
+// or less requires every target to succeed.
+func NewSinkFanout(quorum int, targets ...FanoutTarget) *SinkFanout {
+	f := &SinkFanout{quorum: quorum}
+	for i := range targets {
+		t := targets[i]
+		f.targets = append(f.targets, &t)
+	}
+	if f.quorum <= 0 || f.quorum > len(f.targets) {
+		f.quorum = len(f.targets)
+	}
+	return f
+}
+
+// Write delivers obj to all the targets concurrently and waits for them.
+// It returns an error if fewer than the quorum of targets succeeded.
+func (f *SinkFanout) Write(obj interface{}) error {
+	return f.WriteContext(context.Background(), obj)
+}
+
+// WriteContext is like Write, but the targets stop retrying once ctx is done,
+// failing with the last error of their sink.
+func (f *SinkFanout) WriteContext(ctx context.Context, obj interface{}) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(f.targets))
+
+	wg.Add(len(f.targets))
+	for i, t := range f.targets {
+		go func(i int, t *FanoutTarget) {
+			defer wg.Done()
+			errs[i] = t.deliver(ctx, obj)
+		}(i, t)
+	}
+	wg.Wait()
+
+	var succeeded int
+	var lastErr error
+	for _, err := range errs {
+		if err == nil {
+			succeeded++
+		} else {
+			lastErr = err
+		}
+	}
+	if succeeded < f.quorum {
+		return fmt.Errorf("pipeline: %d of %d sinks succeeded, quorum is %d: %v",
+			succeeded, len(f.targets), f.quorum, lastErr)
+	}
+	return nil
+}
+
+// Flush flushes the targets whose Sink is Flushable, concurrently.
+func (f *SinkFanout) Flush(ctx context.Context) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(f.targets))
+	for i, t := range f.targets {
+		flusher, ok := t.Sink.(Flushable)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, t *FanoutTarget) {
+			defer wg.Done()
+			if err := flusher.Flush(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s: %v", t.Name, err)
+			}
+		}(i, t)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns the counters of every target.
+func (f *SinkFanout) Stats() (stats []FanoutTargetStats) {
+	for _, t := range f.targets {
+		stats = append(stats, FanoutTargetStats{
+			Name:         t.Name,
+			Delivered:    atomic.LoadUint64(&t.delivered),
+			DeadLettered: atomic.LoadUint64(&t.deadLettered),
+		})
+	}
+	return
+}
+
+func (t *FanoutTarget) deliver(ctx context.Context, obj interface{}) (err error) {
+	for attempt := 1; ; attempt++ {
+		if cs, ok := t.Sink.(ContextSink); ok {
+			err = cs.WriteContext(ctx, obj)
+		} else {
+			err = t.Sink.Write(obj)
+		}
+		if err == nil {
+			atomic.AddUint64(&t.delivered, 1)
+			return nil
+		}
+		if attempt >= t.MaxAttempts || !sleep(clockOrSystem(t.Clock), t.Backoff, ctx.Done()) {
+			break
+		}
+	}
+
+	atomic.AddUint64(&t.deadLettered, 1)
+	if t.DeadLetter != nil {
+		t.DeadLetter(obj, err)
+	}
+	return fmt.Errorf("%s: %v", t.Name, err)
+}
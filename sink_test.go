@@ -0,0 +1,109 @@
+package pipeline_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"sync"
+	"time"
+)
+
+func ExampleSinkFanout() {
+	var failures int
+	flaky := pipeline.SinkFunc(func(obj interface{}) error {
+		if failures < 1 {
+			failures++
+			return errors.New("connection reset")
+		}
+		return nil
+	})
+	broken := pipeline.SinkFunc(func(obj interface{}) error {
+		return errors.New("index unavailable")
+	})
+
+	fanout := pipeline.NewSinkFanout(2,
+		pipeline.FanoutTarget{Name: "db", Sink: flaky, MaxAttempts: 3},
+		pipeline.FanoutTarget{Name: "cache", Sink: pipeline.SinkFunc(func(interface{}) error { return nil })},
+		pipeline.FanoutTarget{Name: "search", Sink: broken, MaxAttempts: 2},
+	)
+
+	fmt.Println(fanout.Write("record"))
+	for _, s := range fanout.Stats() {
+		fmt.Println(s.Name, s.Delivered, s.DeadLettered)
+	}
+
+	// Output: <nil>
+	// db 1 0
+	// cache 1 0
+	// search 0 1
+}
+
+func ExampleSinkFanout_WriteContext() {
+	fanout := pipeline.NewSinkFanout(1, pipeline.FanoutTarget{
+		Name:        "db",
+		Sink:        pipeline.SinkFunc(func(interface{}) error { return errors.New("connection refused") }),
+		MaxAttempts: 5,
+		Backoff:     time.Hour,
+	})
+
+	// the target gives up rather than waiting out its backoff
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fmt.Println(fanout.WriteContext(ctx, "record"))
+	for _, s := range fanout.Stats() {
+		fmt.Println(s.Name, s.Delivered, s.DeadLettered)
+	}
+
+	// Output: pipeline: 0 of 1 sinks succeeded, quorum is 1: db: connection refused
+	// db 0 1
+}
+
+// batchSink writes objects in batches of three.
+type batchSink struct {
+	mu    sync.Mutex
+	batch []interface{}
+}
+
+func (s *batchSink) Write(obj interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batch = append(s.batch, obj)
+	if len(s.batch) == 3 {
+		fmt.Println("writing", s.batch)
+		s.batch = nil
+	}
+	return nil
+}
+
+func (s *batchSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.batch) > 0 {
+		fmt.Println("flushing", s.batch)
+		s.batch = nil
+	}
+	return nil
+}
+
+func ExamplePipeline_Flush() {
+	p := pipeline.New()
+	p.AddSink(&batchSink{}, 1)
+
+	in := make(chan interface{}, 4)
+	for i := 1; i <= 4; i++ {
+		in <- i
+	}
+	close(in)
+	<-p.Run(in)
+
+	// the last object would be lost without flushing
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := p.Flush(ctx); err != nil {
+		fmt.Println(err)
+	}
+
+	// Output: writing [1 2 3]
+	// flushing [4]
+}
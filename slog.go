@@ -0,0 +1,78 @@
+//go:build go1.21
+// +build go1.21
+
+package pipeline
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// SetLogger makes the pipeline log the lifecycle of its stages with structured
+// attributes: stages starting and stopping and objects being dropped are
+// logged at debug level, failed objects and objects taking longer than
+// slowThreshold to process at warn level and worker panics at error level. A
+// slowThreshold of zero disables the slow object warnings.
+//
+// Every record carries a "stage" attribute with the name of the stage, and a
+// "labels" group with the labels of the run if any, see WithLabels. Raw stages
+// are not logged.
+func (p *Pipeline) SetLogger(logger *slog.Logger, slowThreshold time.Duration) {
+	p.checkMutable()
+	p.logger = slogLogger{logger: logger, threshold: slowThreshold}
+}
+
+type slogLogger struct {
+	logger    *slog.Logger
+	threshold time.Duration
+}
+
+func (l slogLogger) stageStarted(stage string, fanSize uint64) {
+	l.logger.LogAttrs(context.Background(), slog.LevelDebug, "stage started",
+		slog.String("stage", stage), slog.Uint64("fan_size", fanSize))
+}
+
+func (l slogLogger) stageStopped(stage string) {
+	l.logger.LogAttrs(context.Background(), slog.LevelDebug, "stage stopped",
+		slog.String("stage", stage))
+}
+
+func (l slogLogger) workerPanicked(stage string, recovered interface{}) {
+	l.logger.LogAttrs(context.Background(), slog.LevelError, "worker panicked",
+		slog.String("stage", stage), slog.Any("panic", recovered))
+}
+
+func (l slogLogger) dropped(stage string) {
+	l.logger.LogAttrs(context.Background(), slog.LevelDebug, "object dropped",
+		slog.String("stage", stage))
+}
+
+func (l slogLogger) itemFailed(stage string, err error) {
+	l.logger.LogAttrs(context.Background(), slog.LevelWarn, "object failed",
+		slog.String("stage", stage), slog.Any("error", err))
+}
+
+func (l slogLogger) slowItem(stage string, d time.Duration) {
+	l.logger.LogAttrs(context.Background(), slog.LevelWarn, "slow object",
+		slog.String("stage", stage), slog.Duration("duration", d))
+}
+
+func (l slogLogger) slowThreshold() time.Duration {
+	return l.threshold
+}
+
+func (l slogLogger) withLabels(labels Labels) stageLogger {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]interface{}, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, slog.String(k, labels[k]))
+	}
+	l.logger = l.logger.With(slog.Group("labels", attrs...))
+	return l
+}
@@ -0,0 +1,35 @@
+//go:build go1.21
+// +build go1.21
+
+package pipeline_test
+
+import (
+	"github.com/hyfather/pipeline"
+	"log/slog"
+	"os"
+)
+
+func ExamplePipeline_SetLogger() {
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	p.SetLogger(logger, 0)
+
+	ch := make(chan interface{}, 1)
+	ch <- "not a number"
+	close(ch)
+	<-p.Run(ch)
+
+	// Output: level=DEBUG msg="stage started" stage=stage0 fan_size=1
+	// level=DEBUG msg="object dropped" stage=stage0
+	// level=DEBUG msg="stage stopped" stage=stage0
+}
@@ -0,0 +1,97 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot is a serializable view of the state of a pipeline at a point in
+// time, across all its runs: what every stage has done so far and what its
+// goroutines are doing right now. It is meant for dashboards and for finding
+// out where a stuck pipeline is stuck.
+type Snapshot struct {
+	Time   time.Time
+	Stages []StageSnapshot
+}
+
+// StageSnapshot is the state of a single stage in a Snapshot.
+type StageSnapshot struct {
+	StageStats
+	FanSize uint64
+	Paused  bool
+
+	// InFlight is the number of objects being processed by the stage or
+	// waiting for the next stage to take them, its queue.
+	InFlight uint64
+
+	// Workers is the number of running goroutines of the stage, Busy the
+	// ones in the ProcessFn and Blocked the ones waiting for the next stage.
+	// They are only counted if profiling is enabled, see SetProfiling, and
+	// the goroutines of a WorkerPool aren't.
+	Workers int
+	Busy    int
+	Blocked int
+
+	// Utilization is the share of the goroutines that are busy.
+	Utilization float64
+
+	// AvgLatency is the average time the ProcessFn took per object.
+	AvgLatency time.Duration
+}
+
+// Snapshot returns the current state of the pipeline. It is safe to call
+// while the pipeline is running.
+func (p *Pipeline) Snapshot() Snapshot {
+	snap := Snapshot{Time: time.Now()}
+	type phases struct{ workers, busy, blocked int }
+	byStage := map[string]*phases{}
+	workers.Lock()
+	for _, slot := range workers.byID {
+		if slot.owner != p {
+			continue
+		}
+		ph := byStage[slot.stage]
+		if ph == nil {
+			ph = &phases{}
+			byStage[slot.stage] = ph
+		}
+		ph.workers++
+		switch atomic.LoadInt32(&slot.phase) {
+		case idlePhase, pausedPhase:
+		case sendingPhase:
+			ph.blocked++
+		default:
+			ph.busy++
+		}
+	}
+	workers.Unlock()
+
+	stages := p.stageList()
+	stats := statsOf(stages)
+	for i, s := range stages {
+		ss := StageSnapshot{
+			StageStats: stats.Stages[i],
+			FanSize:    s.control.getFanSize(),
+			Paused:     s.control.isPaused(),
+			InFlight:   stats.Stages[i].InFlight(),
+			AvgLatency: stats.Stages[i].AvgLatency(),
+		}
+		if ph := byStage[s.name]; ph != nil {
+			ss.Workers, ss.Busy, ss.Blocked = ph.workers, ph.busy, ph.blocked
+			ss.Utilization = float64(ph.busy) / float64(ph.workers)
+		}
+		snap.Stages = append(snap.Stages, ss)
+	}
+	return snap
+}
+
+// SnapshotHandler returns an http.Handler serving the Snapshot of the
+// pipeline as JSON.
+func (p *Pipeline) SnapshotHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(p.Snapshot())
+	})
+}
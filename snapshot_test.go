@@ -0,0 +1,35 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExamplePipeline_Snapshot() {
+	release := make(chan struct{})
+	p := pipeline.New()
+	p.SetProfiling(true)
+	p.AddStageWithFanOut(func(inObj interface{}) interface{} {
+		<-release // a stuck dependency
+		return inObj
+	}, 2, pipeline.WithName("enrich"))
+	p.AddStage(func(inObj interface{}) interface{} { return nil }, pipeline.WithName("store"))
+
+	ch := make(chan interface{}, 3)
+	ch <- 1
+	ch <- 2
+	ch <- 3
+	close(ch)
+	done := p.Run(ch)
+	time.Sleep(50 * time.Millisecond)
+
+	for _, s := range p.Snapshot().Stages {
+		fmt.Printf("%s: in flight %d, %d/%d busy, utilization %.0f%%\n", s.Name, s.InFlight, s.Busy, s.Workers, 100*s.Utilization)
+	}
+	close(release)
+	<-done
+
+	// Output: enrich: in flight 2, 2/2 busy, utilization 100%
+	// store: in flight 0, 0/1 busy, utilization 0%
+}
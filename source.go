@@ -0,0 +1,31 @@
+package pipeline
+
+// FromFunc returns an input channel fed with the objects returned by next,
+// which is called until it returns false; the channel is then closed:
+//
+//	rows, _ := db.Query("SELECT id FROM users")
+//	<-p.Run(pipeline.FromFunc(func() (interface{}, bool) {
+//		var id int
+//		if !rows.Next() || rows.Scan(&id) != nil {
+//			rows.Close()
+//			return nil, false
+//		}
+//		return id, true
+//	}))
+//
+// next is called from a goroutine of its own, which returns once the channel
+// is closed: the run must read it to the end, i.e. not be aborted.
+func FromFunc(next func() (interface{}, bool)) <-chan interface{} {
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+		for {
+			obj, ok := next()
+			if !ok {
+				return
+			}
+			ch <- obj
+		}
+	}()
+	return ch
+}
@@ -0,0 +1,20 @@
+//go:build go1.18
+// +build go1.18
+
+package pipeline
+
+// FromSlice returns a closed input channel holding the items, for small
+// inputs and tests:
+//
+//	<-p.Run(pipeline.FromSlice([]int{1, 2, 3}))
+//
+// The items are buffered in the channel, so no goroutine is left behind if
+// the run doesn't read them all.
+func FromSlice[T any](items []T) <-chan interface{} {
+	ch := make(chan interface{}, len(items))
+	for _, item := range items {
+		ch <- item
+	}
+	close(ch)
+	return ch
+}
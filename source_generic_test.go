@@ -0,0 +1,20 @@
+//go:build go1.18
+// +build go1.18
+
+package pipeline_test
+
+import (
+	"github.com/hyfather/pipeline"
+)
+
+func ExampleFromSlice() {
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	p.AddStage(printStage)
+
+	<-p.Run(pipeline.FromSlice([]int{1, 2, 3}))
+
+	// Output: 1
+	// 4
+	// 9
+}
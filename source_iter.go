@@ -0,0 +1,22 @@
+//go:build go1.23
+// +build go1.23
+
+package pipeline
+
+import (
+	"iter"
+)
+
+// FromSeq returns an input channel fed with the values of seq, closed once
+// the sequence ends. As with FromFunc, the run must read the channel to the
+// end.
+func FromSeq[T any](seq iter.Seq[T]) <-chan interface{} {
+	ch := make(chan interface{})
+	go func() {
+		defer close(ch)
+		for v := range seq {
+			ch <- v
+		}
+	}()
+	return ch
+}
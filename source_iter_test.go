@@ -0,0 +1,21 @@
+//go:build go1.23
+// +build go1.23
+
+package pipeline_test
+
+import (
+	"github.com/hyfather/pipeline"
+	"maps"
+	"slices"
+)
+
+func ExampleFromSeq() {
+	p := pipeline.New()
+	p.AddStage(printStage)
+
+	ages := map[string]int{"alice": 31, "bob": 42}
+	<-p.Run(pipeline.FromSeq(slices.Values(slices.Sorted(maps.Keys(ages)))))
+
+	// Output: alice
+	// bob
+}
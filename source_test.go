@@ -0,0 +1,20 @@
+package pipeline_test
+
+import (
+	"github.com/hyfather/pipeline"
+)
+
+func ExampleFromFunc() {
+	p := pipeline.New()
+	p.AddStage(printStage)
+
+	i := 0
+	<-p.Run(pipeline.FromFunc(func() (interface{}, bool) {
+		i++
+		return i, i <= 3
+	}))
+
+	// Output: 1
+	// 2
+	// 3
+}
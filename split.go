@@ -0,0 +1,39 @@
+package pipeline
+
+// Split is the inverse of MergeChannels: it spreads the objects of inChan
+// over n channels, round-robin, and closes them once inChan is closed. Since
+// an object waits for its channel to be read, all the channels must be read
+// and the slowest one sets the pace.
+//
+// Together with MergeChannels, it lets parts of a stream go through different
+// functions before the results are merged back.
+func Split(inChan <-chan interface{}, n int) (outChans []chan interface{}) {
+	var next int
+	return SplitBy(inChan, n, func(interface{}) int {
+		i := next
+		next = (next + 1) % n
+		return i
+	})
+}
+
+// SplitBy is Split sending every object to the channel at the index returned
+// by partition, which must be between 0 and n-1, e.g. a hash of a key so that
+// all the objects with the same key go to the same channel, in order.
+// partition is called from a single goroutine.
+func SplitBy(inChan <-chan interface{}, n int, partition func(obj interface{}) int) (outChans []chan interface{}) {
+	outChans = make([]chan interface{}, n)
+	for i := range outChans {
+		outChans[i] = make(chan interface{})
+	}
+	go func() {
+		defer func() {
+			for _, ch := range outChans {
+				close(ch)
+			}
+		}()
+		for obj := range inChan {
+			outChans[partition(obj)] <- obj
+		}
+	}()
+	return
+}
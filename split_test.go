@@ -0,0 +1,41 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"sync"
+)
+
+func ExampleSplitBy() {
+	in := make(chan interface{}, 6)
+	for _, word := range []string{"apple", "kiwi", "fig", "banana", "plum", "pear"} {
+		in <- word
+	}
+	close(in)
+
+	// by length: short words in the first channel, long ones in the second
+	outs := pipeline.SplitBy(in, 2, func(obj interface{}) int {
+		if len(obj.(string)) <= 4 {
+			return 0
+		}
+		return 1
+	})
+
+	words := make([][]interface{}, len(outs))
+	var wg sync.WaitGroup
+	for i, out := range outs {
+		wg.Add(1)
+		go func(i int, out chan interface{}) {
+			defer wg.Done()
+			for obj := range out {
+				words[i] = append(words[i], obj)
+			}
+		}(i, out)
+	}
+	wg.Wait()
+	fmt.Println(words[0])
+	fmt.Println(words[1])
+
+	// Output: [kiwi fig plum pear]
+	// [apple banana]
+}
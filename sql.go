@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SQLSink is a Sink inserting objects as rows of a table in batches, each
+// batch with a single INSERT statement run in its own transaction.
+//
+// A write returns once the batch of its object was committed, so that
+// objects only complete, and their envelopes are only acknowledged, once
+// stored, and each object fails with its batch. Batches fill up with the
+// objects written concurrently: the sink should be added with a fan size of
+// at least BatchSize.
+type SQLSink struct {
+	db      *sql.DB
+	table   string
+	columns []string
+	values  func(obj interface{}) ([]interface{}, error)
+
+	// BatchSize is the number of rows inserted at a time, 100 if zero.
+	BatchSize int
+
+	// FlushInterval is how long a batch waits to fill up before it is
+	// inserted anyway, 100ms if zero.
+	FlushInterval time.Duration
+
+	// Clock, if set, measures the FlushInterval instead of the system clock.
+	Clock Clock
+
+	// OnConflict, if set, is appended to the statements to make them
+	// upserts, e.g. "ON CONFLICT (id) DO UPDATE SET total = excluded.total"
+	// for PostgreSQL and SQLite or "ON DUPLICATE KEY UPDATE total =
+	// VALUES(total)" for MySQL.
+	OnConflict string
+
+	// Placeholder returns the placeholder of the n-th argument of a
+	// statement, starting at 1: "?" if nil, see PostgresPlaceholder.
+	Placeholder func(n int) string
+
+	// MaxAttempts is the number of times a batch is tried before its
+	// objects fail, 3 if zero, waiting according to Backoff in between.
+	MaxAttempts int
+	Backoff     Backoff
+
+	// Transient, if set, reports whether an error is worth retrying. By
+	// default every error is.
+	Transient func(err error) bool
+
+	batcher batcher
+}
+
+// NewSQLSink creates a SQLSink inserting into the given columns of table the
+// values returned by the values function for every object, in the order of
+// the columns.
+func NewSQLSink(db *sql.DB, table string, columns []string, values func(obj interface{}) ([]interface{}, error)) *SQLSink {
+	s := &SQLSink{db: db, table: table, columns: columns, values: values,
+		Backoff: Backoff{Initial: 100 * time.Millisecond, Max: 5 * time.Second, Jitter: 0.2}}
+	s.batcher.commit = s.insert
+	return s
+}
+
+// PostgresPlaceholder returns the n-th placeholder of PostgreSQL, $n.
+func PostgresPlaceholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// Write implements Sink.
+func (s *SQLSink) Write(obj interface{}) error {
+	row, err := s.values(obj)
+	if err != nil {
+		return err
+	}
+	if len(row) != len(s.columns) {
+		return fmt.Errorf("pipeline: %d values for the %d columns of %s", len(row), len(s.columns), s.table)
+	}
+
+	batchSize := s.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	interval := s.FlushInterval
+	if interval <= 0 {
+		interval = 100 * time.Millisecond
+	}
+
+	return s.batcher.add(row, batchSize, interval, clockOrSystem(s.Clock))
+}
+
+// insert inserts a batch of rows, retrying.
+func (s *SQLSink) insert(rows []interface{}) error {
+	maxAttempts := s.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+	query, args := s.statement(rows)
+	return retryCall(maxAttempts, s.Backoff, s.Transient, clockOrSystem(s.Clock), nil, func() error {
+		return s.exec(query, args)
+	})
+}
+
+func (s *SQLSink) exec(query string, args []interface{}) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err = tx.Exec(query, args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// statement returns the INSERT statement of rows along with its arguments.
+func (s *SQLSink) statement(rows []interface{}) (string, []interface{}) {
+	placeholder := s.Placeholder
+	if placeholder == nil {
+		placeholder = func(int) string { return "?" }
+	}
+
+	var b bytes.Buffer
+	args := make([]interface{}, 0, len(rows)*len(s.columns))
+	fmt.Fprintf(&b, "INSERT INTO %s (%s) VALUES ", s.table, strings.Join(s.columns, ", "))
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteByte('(')
+		for j, v := range row.([]interface{}) {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			args = append(args, v)
+			b.WriteString(placeholder(len(args)))
+		}
+		b.WriteByte(')')
+	}
+	if s.OnConflict != "" {
+		b.WriteString(" ")
+		b.WriteString(s.OnConflict)
+	}
+	return b.String(), args
+}
+
+// Flush implements Flushable, inserting the batch filling up right away.
+func (s *SQLSink) Flush(ctx context.Context) error {
+	return s.batcher.flush(ctx)
+}
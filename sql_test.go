@@ -0,0 +1,99 @@
+package pipeline_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"sort"
+	"sync"
+	"time"
+)
+
+// printDriver is a database/sql driver printing the statements it runs. The
+// first one fails.
+type printDriver struct {
+	mu     sync.Mutex
+	failed bool
+}
+
+func (d *printDriver) Open(name string) (driver.Conn, error) { return printConn{d}, nil }
+
+type printConn struct{ d *printDriver }
+
+func (c printConn) Prepare(query string) (driver.Stmt, error) { return printStmt{c.d, query}, nil }
+func (c printConn) Close() error                              { return nil }
+func (c printConn) Begin() (driver.Tx, error)                 { return printTx{}, nil }
+
+type printTx struct{}
+
+func (printTx) Commit() error   { fmt.Println("commit"); return nil }
+func (printTx) Rollback() error { fmt.Println("rollback"); return nil }
+
+type printStmt struct {
+	d     *printDriver
+	query string
+}
+
+func (s printStmt) Close() error  { return nil }
+func (s printStmt) NumInput() int { return -1 }
+
+func (s printStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	if !s.d.failed {
+		s.d.failed = true
+		fmt.Println("deadlock detected")
+		return nil, errors.New("deadlock detected")
+	}
+	values := make([]string, len(args))
+	for i, arg := range args {
+		values[i] = fmt.Sprint(arg)
+	}
+	sort.Strings(values)
+	fmt.Println(s.query, values)
+	return driver.RowsAffected(len(args) / 2), nil
+}
+
+func (s printStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func init() {
+	sql.Register("print", &printDriver{})
+}
+
+func ExampleNewSQLSink() {
+	db, _ := sql.Open("print", "")
+	defer db.Close()
+
+	sink := pipeline.NewSQLSink(db, "orders", []string{"id", "total"}, func(obj interface{}) ([]interface{}, error) {
+		id := obj.(int)
+		return []interface{}{id, id * 10}, nil
+	})
+	sink.BatchSize = 2
+	sink.FlushInterval = 10 * time.Millisecond
+	sink.Backoff = pipeline.Backoff{Initial: time.Millisecond}
+	sink.Placeholder = pipeline.PostgresPlaceholder
+	sink.OnConflict = "ON CONFLICT (id) DO UPDATE SET total = excluded.total"
+
+	var wg sync.WaitGroup
+	for _, id := range []int{1, 2} {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			sink.Write(id)
+		}(id)
+	}
+	wg.Wait()
+	fmt.Println(sink.Write(3))
+
+	// Output: deadlock detected
+	// rollback
+	// INSERT INTO orders (id, total) VALUES ($1, $2), ($3, $4) ON CONFLICT (id) DO UPDATE SET total = excluded.total [1 10 2 20]
+	// commit
+	// INSERT INTO orders (id, total) VALUES ($1, $2) ON CONFLICT (id) DO UPDATE SET total = excluded.total [3 30]
+	// commit
+	// <nil>
+}
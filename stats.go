@@ -0,0 +1,78 @@
+package pipeline
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a point-in-time view of the counters of every stage in a Pipeline.
+// Counters are cumulative across all the runs of the pipeline.
+type Stats struct {
+	Stages []StageStats
+
+	// Runs holds the labels of the runs in progress that have some, by run
+	// ID, to attribute the counters to them, see WithLabels.
+	Runs map[uint64]Labels `json:",omitempty"`
+}
+
+// StageStats holds the counters of a single stage.
+type StageStats struct {
+	Name    string
+	In      uint64 // objects read from the previous stage
+	Out     uint64 // objects passed on to the next stage
+	Dropped uint64 // objects for which the ProcessFn returned nil
+	Errors  uint64 // objects for which the ProcessFn returned an error
+
+	// DeadLettered counts the failed objects handed to the dead-letter
+	// function, see SetDeadLetter.
+	DeadLettered uint64
+
+	// ProcessingTime is the total time spent in the ProcessFn, across all
+	// the goroutines of the stage.
+	ProcessingTime time.Duration
+}
+
+// InFlight returns the number of objects currently being processed by the
+// stage or waiting for the next stage to take them. Since the counters aren't
+// read at once, it is zero rather than negative should an object be counted as
+// done but not yet as read.
+func (s StageStats) InFlight() uint64 {
+	done := s.Out + s.Dropped + s.Errors
+	if done > s.In {
+		return 0
+	}
+	return s.In - done
+}
+
+// AvgLatency returns the average time the ProcessFn took per object.
+func (s StageStats) AvgLatency() time.Duration {
+	if s.In == 0 {
+		return 0
+	}
+	return s.ProcessingTime / time.Duration(s.In)
+}
+
+// Stats returns a snapshot of the pipeline's stage counters. It is safe to call
+// while the pipeline is running.
+func (p *Pipeline) Stats() Stats {
+	stats := statsOf(p.stageList())
+	stats.Runs = p.runLabels()
+	return stats
+}
+
+// statsOf returns a snapshot of the counters of the given stages. The
+// counters of the objects done with are read before the one of the objects
+// read, so that the latter is never behind.
+func statsOf(stages []*stage) (stats Stats) {
+	for _, s := range stages {
+		st := StageStats{Name: s.name}
+		st.Errors = atomic.LoadUint64(&s.counters.errors)
+		st.Dropped = atomic.LoadUint64(&s.counters.dropped)
+		st.Out = atomic.LoadUint64(&s.counters.out)
+		st.In = atomic.LoadUint64(&s.counters.in)
+		st.DeadLettered = atomic.LoadUint64(&s.counters.deadLettered)
+		st.ProcessingTime = time.Duration(atomic.LoadUint64(&s.counters.nanos))
+		stats.Stages = append(stats.Stages, st)
+	}
+	return
+}
@@ -0,0 +1,103 @@
+package pipeline
+
+import "sync/atomic"
+
+// stealQueueSize is the number of objects a WorkStealing lane queues.
+const stealQueueSize = 32
+
+// queue queues obj in ln, the least loaded lane, unless its queue is full.
+func (d *dispatcher) queue(ln *lane, obj interface{}) bool {
+	ln.mu.Lock()
+	full := len(ln.queue) >= stealQueueSize
+	if !full {
+		ln.queue = append(ln.queue, obj)
+	}
+	ln.mu.Unlock()
+	if !full {
+		notify(d.queued)
+	}
+	return !full
+}
+
+// take returns the next object of a WorkStealing lane: the oldest one of its
+// own queue or, if it is empty, the newest one of the longest queue of the
+// other lanes. It returns false once the lane is retired and drained, or the
+// input of the stage is closed and all the queues are drained.
+func (d *dispatcher) take(ln *lane, done <-chan struct{}) (interface{}, bool) {
+	for {
+		// nothing is queued anymore once the lane is retired or the input
+		// is closed, so checking before dequeuing doesn't miss objects
+		retired, finished := isClosed(ln.retired), isClosed(d.finished)
+		obj, ok := ln.dequeue()
+		if !ok && !retired {
+			obj, ok = d.steal(ln)
+		}
+		if ok {
+			atomic.StoreInt32(&ln.busy, 1)
+			notify(d.dequeued)
+			// other goroutines may be idle while objects are queued
+			notify(d.queued)
+			return obj, true
+		}
+		if retired || finished {
+			return nil, false
+		}
+
+		select {
+		case <-d.queued:
+		case <-ln.retired:
+		case <-d.finished:
+		case <-done:
+			return nil, false
+		}
+	}
+}
+
+// dequeue takes the oldest object of the queue of a lane.
+func (ln *lane) dequeue() (obj interface{}, ok bool) {
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+	if len(ln.queue) == 0 {
+		return nil, false
+	}
+	obj = ln.queue[0]
+	ln.queue[0] = nil
+	ln.queue = ln.queue[1:]
+	return obj, true
+}
+
+// steal takes the newest object of the longest queue of the lanes other than
+// thief. The lanes retired are left to their own goroutines.
+func (d *dispatcher) steal(thief *lane) (obj interface{}, ok bool) {
+	d.mu.Lock()
+	lanes := append([]*lane(nil), d.lanes...)
+	d.mu.Unlock()
+
+	var victim *lane
+	longest := 0
+	for _, ln := range lanes {
+		if ln == thief {
+			continue
+		}
+		ln.mu.Lock()
+		n := len(ln.queue)
+		ln.mu.Unlock()
+		if n > longest {
+			victim, longest = ln, n
+		}
+	}
+	if victim == nil {
+		return nil, false
+	}
+
+	victim.mu.Lock()
+	defer victim.mu.Unlock()
+	n := len(victim.queue)
+	if n == 0 {
+		return nil, false
+	}
+	obj = victim.queue[n-1]
+	victim.queue[n-1] = nil
+	victim.queue = victim.queue[:n-1]
+	return obj, true
+}
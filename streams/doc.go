@@ -0,0 +1,7 @@
+// Package streams provides generic, functional combinators such as Map,
+// Filter or Chunk on top of the pipeline package. Every combinator adds a
+// stage to the underlying Pipeline, so streams run on the same runtime and
+// benefit from its stats, logging, tracing and controls.
+//
+// The package requires Go 1.18 or later.
+package streams
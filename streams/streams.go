@@ -0,0 +1,218 @@
+//go:build go1.18
+// +build go1.18
+
+package streams
+
+import (
+	"github.com/hyfather/pipeline"
+	"reflect"
+	"sync"
+)
+
+// Stream is a typed view of the objects coming out of the last stage of a
+// pipeline. Combinators add stages to the pipeline and return a Stream of
+// their output type.
+type Stream[T any] struct {
+	p       *pipeline.Pipeline
+	fanSize uint64
+}
+
+// From returns a Stream of the objects coming out of p, which must be of type
+// T: the input of the pipeline if it has no stage yet.
+func From[T any](p *pipeline.Pipeline) Stream[T] {
+	return Stream[T]{p: p, fanSize: 1}
+}
+
+// Parallel returns a Stream whose Map, Filter and Distinct stages run with
+// the given fan size. Objects lose their order in such stages.
+func (s Stream[T]) Parallel(fanSize uint64) Stream[T] {
+	s.fanSize = fanSize
+	return s
+}
+
+// Pipeline returns the pipeline the stream adds stages to.
+func (s Stream[T]) Pipeline() *pipeline.Pipeline {
+	return s.p
+}
+
+// Source converts a typed channel into the input of a pipeline.
+func Source[T any](in <-chan T) <-chan interface{} {
+	outChan := make(chan interface{})
+	go func() {
+		defer close(outChan)
+		for obj := range in {
+			outChan <- obj
+		}
+	}()
+	return outChan
+}
+
+// Map transforms every object with fn. Results that are nil interfaces or nil
+// pointers are dropped.
+func Map[T, U any](s Stream[T], fn func(T) U, opts ...pipeline.StageOption) Stream[U] {
+	s.p.AddStageWithFanOut(func(inObj interface{}) interface{} {
+		outObj := interface{}(fn(inObj.(T)))
+		// a nil pointer makes a non-nil interface, which the stage would pass on
+		if v := reflect.ValueOf(outObj); v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil
+		}
+		return outObj
+	}, s.fanSize, opts...)
+	return Stream[U]{p: s.p, fanSize: s.fanSize}
+}
+
+// Filter drops the objects for which keep returns false.
+func Filter[T any](s Stream[T], keep func(T) bool, opts ...pipeline.StageOption) Stream[T] {
+	s.p.AddStageWithFanOut(func(inObj interface{}) interface{} {
+		if !keep(inObj.(T)) {
+			return nil
+		}
+		return inObj
+	}, s.fanSize, opts...)
+	return s
+}
+
+// Distinct drops the objects equal to an object seen before. It remembers
+// every object it saw, so the number of distinct objects must be bounded.
+func Distinct[T comparable](s Stream[T], opts ...pipeline.StageOption) Stream[T] {
+	var seen sync.Map
+	return Filter(s, func(obj T) bool {
+		_, loaded := seen.LoadOrStore(obj, struct{}{})
+		return !loaded
+	}, opts...)
+}
+
+// FlatMap replaces every object with the objects fn returns for it, if any.
+//
+// FlatMap and the other combinators emitting objects other than the ones they
+// receive, i.e. Chunk and Reduce, acknowledge the envelope of every object
+// they receive once they emitted the objects derived from it, see
+// pipeline.Acknowledger, or reject it if the run is aborted first. The objects
+// they emit don't carry envelopes.
+func FlatMap[T, U any](s Stream[T], fn func(T) []U, opts ...pipeline.StageOption) Stream[U] {
+	s.p.AddRawStage(func(inChan <-chan interface{}) (outChan chan interface{}) {
+		ctx := pipeline.RawStageContext(inChan)
+		outChan = make(chan interface{})
+		go func() {
+			defer close(outChan)
+			for inObj := range inChan {
+				obj, env := unwrap[T](inObj)
+				for _, out := range fn(obj) {
+					select {
+					case outChan <- out:
+					case <-ctx.Done():
+						nack(ctx.Err(), env)
+						return
+					}
+				}
+				ack(env)
+			}
+		}()
+		return
+	}, opts...)
+	return Stream[U]{p: s.p, fanSize: s.fanSize}
+}
+
+// Chunk groups the objects in slices of size objects, the last one being
+// shorter if the number of objects isn't a multiple of size.
+func Chunk[T any](s Stream[T], size int, opts ...pipeline.StageOption) Stream[[]T] {
+	s.p.AddRawStage(func(inChan <-chan interface{}) (outChan chan interface{}) {
+		ctx := pipeline.RawStageContext(inChan)
+		outChan = make(chan interface{})
+		go func() {
+			defer close(outChan)
+			var chunk []T
+			var envs []*pipeline.Envelope
+			flush := func() bool {
+				select {
+				case outChan <- chunk:
+				case <-ctx.Done():
+					nack(ctx.Err(), envs...)
+					return false
+				}
+				for _, env := range envs {
+					ack(env)
+				}
+				chunk, envs = nil, nil
+				return true
+			}
+			for inObj := range inChan {
+				obj, env := unwrap[T](inObj)
+				chunk = append(chunk, obj)
+				if env != nil {
+					envs = append(envs, env)
+				}
+				if len(chunk) >= size && !flush() {
+					return
+				}
+			}
+			if len(chunk) > 0 {
+				flush()
+			}
+		}()
+		return
+	}, opts...)
+	return Stream[[]T]{p: s.p, fanSize: s.fanSize}
+}
+
+// Reduce folds all the objects into a single one with fn, starting from
+// initial, and emits it once the input of the run is closed.
+func Reduce[T, U any](s Stream[T], initial U, fn func(U, T) U, opts ...pipeline.StageOption) Stream[U] {
+	s.p.AddRawStage(func(inChan <-chan interface{}) (outChan chan interface{}) {
+		ctx := pipeline.RawStageContext(inChan)
+		outChan = make(chan interface{})
+		go func() {
+			defer close(outChan)
+			acc := initial
+			var envs []*pipeline.Envelope
+			for inObj := range inChan {
+				obj, env := unwrap[T](inObj)
+				acc = fn(acc, obj)
+				if env != nil {
+					envs = append(envs, env)
+				}
+			}
+			select {
+			case outChan <- acc:
+			case <-ctx.Done():
+				nack(ctx.Err(), envs...)
+				return
+			}
+			for _, env := range envs {
+				ack(env)
+			}
+		}()
+		return
+	}, opts...)
+	return Stream[U]{p: s.p, fanSize: s.fanSize}
+}
+
+// ForEach calls fn with every object, e.g. to collect the results.
+func ForEach[T any](s Stream[T], fn func(T), opts ...pipeline.StageOption) {
+	s.p.AddStage(func(inObj interface{}) interface{} {
+		fn(inObj.(T))
+		return inObj
+	}, opts...)
+}
+
+func unwrap[T any](obj interface{}) (T, *pipeline.Envelope) {
+	if env, ok := obj.(*pipeline.Envelope); ok {
+		return env.Payload.(T), env
+	}
+	return obj.(T), nil
+}
+
+func ack(env *pipeline.Envelope) {
+	if env != nil && env.Acker != nil {
+		env.Acker.Ack()
+	}
+}
+
+// nack rejects the envelopes of the objects dropped on the abort of the run.
+func nack(err error, envs ...*pipeline.Envelope) {
+	for _, env := range envs {
+		if env != nil && env.Acker != nil {
+			env.Acker.Nack(err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+//go:build go1.18
+// +build go1.18
+
+package streams_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"github.com/hyfather/pipeline/streams"
+	"strings"
+	"time"
+)
+
+func Example() {
+	p := pipeline.New()
+	words := streams.FlatMap(streams.From[string](&p), strings.Fields)
+	long := streams.Filter(words, func(w string) bool { return len(w) > 3 })
+	upper := streams.Map(streams.Distinct(long), strings.ToUpper)
+	chunks := streams.Chunk(upper, 2)
+	streams.ForEach(chunks, func(chunk []string) {
+		fmt.Println(chunk)
+	})
+
+	in := make(chan string, 2)
+	in <- "the quick brown fox"
+	in <- "jumps over the quick dog"
+	close(in)
+	<-p.Run(streams.Source(in))
+
+	// Output: [QUICK BROWN]
+	// [JUMPS OVER]
+}
+
+type user struct{ name string }
+
+func ExampleMap() {
+	users := map[string]*user{"ada": {"Ada Lovelace"}}
+	p := pipeline.New()
+	// the unknown ids map to nil pointers, which are dropped
+	found := streams.Map(streams.From[string](&p), func(id string) *user { return users[id] })
+	streams.ForEach(found, func(u *user) {
+		fmt.Println(u.name)
+	})
+
+	in := make(chan string, 2)
+	in <- "bob"
+	in <- "ada"
+	close(in)
+	<-p.Run(streams.Source(in))
+
+	// Output: Ada Lovelace
+}
+
+func ExampleReduce() {
+	p := pipeline.New()
+	lengths := streams.Map(streams.From[string](&p), func(s string) int { return len(s) })
+	total := streams.Reduce(lengths, 0, func(sum, n int) int { return sum + n })
+	streams.ForEach(total, func(sum int) {
+		fmt.Println(sum)
+	})
+
+	in := make(chan string, 3)
+	in <- "a"
+	in <- "bb"
+	in <- "ccc"
+	close(in)
+	<-p.Run(streams.Source(in))
+
+	// Output: 6
+}
+
+// stalled adds a stage reading nothing until the run is aborted.
+func stalled[T any](s streams.Stream[T]) {
+	s.Pipeline().AddRawStage(func(inChan <-chan interface{}) (outChan chan interface{}) {
+		ctx := pipeline.RawStageContext(inChan)
+		outChan = make(chan interface{})
+		go func() {
+			<-ctx.Done()
+			close(outChan)
+		}()
+		return
+	})
+}
+
+// rejected wraps n in an envelope whose rejection is sent on nacked.
+func rejected(n int, nacked chan<- error) *pipeline.Envelope {
+	return &pipeline.Envelope{
+		Payload: n,
+		Acker:   pipeline.NewAcknowledger(nil, func(err error) { nacked <- err }),
+	}
+}
+
+func printRejection(nacked <-chan error) {
+	select {
+	case err := <-nacked:
+		fmt.Println(err)
+	case <-time.After(time.Second):
+		fmt.Println("the stage is still sending")
+	}
+}
+
+func ExampleFlatMap_abort() {
+	received, nacked := make(chan struct{}), make(chan error, 1)
+	p := pipeline.New()
+	stalled(streams.FlatMap(streams.From[int](&p), func(n int) []int {
+		close(received)
+		return []int{n, n}
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan interface{}, 1)
+	in <- rejected(1, nacked)
+	done := p.RunContext(ctx, in)
+	<-received
+	cancel() // the stage gives up sending and rejects the object
+	printRejection(nacked)
+	close(in)
+	<-done
+
+	// Output: context canceled
+}
+
+func ExampleChunk_abort() {
+	nacked := make(chan error, 1)
+	p := pipeline.New()
+	stalled(streams.Chunk(streams.From[int](&p), 1))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan interface{})
+	done := p.RunContext(ctx, in)
+	in <- rejected(1, nacked)
+	in <- 2 // the chunk of 1 is being sent once 2 is read
+	cancel()
+	printRejection(nacked)
+	close(in)
+	<-done
+
+	// Output: context canceled
+}
+
+func ExampleReduce_abort() {
+	received, nacked := make(chan struct{}), make(chan error, 1)
+	p := pipeline.New()
+	stalled(streams.Reduce(streams.From[int](&p), 0, func(sum, n int) int {
+		close(received)
+		return sum + n
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan interface{}, 1)
+	in <- rejected(1, nacked)
+	close(in)
+	done := p.RunContext(ctx, in)
+	<-received
+	cancel()
+	printRejection(nacked)
+	<-done
+
+	// Output: context canceled
+}
@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// SetSynchronous makes Run, RunContext, Start and StartContext pass every
+// object through all the stages before taking the next one, on the calling
+// goroutine, and return once the input is closed and processed or the run is
+// aborted. The stages run with a fan size of 1 whatever theirs, weighted
+// stages handing the objects to their workers in turn, and priorities don't
+// apply. The same stage code runs in a deterministic order, which makes for
+// reproducible tests that are easy on the race detector:
+//
+//	p.SetSynchronous(true)
+//	in := make(chan interface{}, 3)
+//	in <- 1; in <- 2; in <- 3
+//	close(in)
+//	p.Run(in) // the stages processed 1, 2 and 3, in order
+//
+// The input must be closed or buffered since nothing else reads it while the
+// stages run. Raw stages can't run synchronously: Run panics if the pipeline
+// has any. Side output pipelines still run on their own goroutines and
+// mustn't be synchronous themselves.
+func (p *Pipeline) SetSynchronous(synchronous bool) {
+	p.checkMutable()
+	p.synchronous = synchronous
+}
+
+// checkSynchronous panics unless the stages of the pipeline can run
+// synchronously.
+func (p *Pipeline) checkSynchronous() {
+	for _, s := range p.stageList() {
+		if s.raw != nil {
+			panic(fmt.Sprintf("pipeline: raw stage %s can't run synchronously", s.name))
+		}
+	}
+}
+
+// syncStage is a stage running on the goroutine of a synchronous run.
+type syncStage struct {
+	cfg       *stageConfig
+	process   ProcessFnErr
+	processes *dispatcher // picks the worker of weighted stages
+}
+
+// runSync passes the objects of inChan through the stages one at a time until
+// it is closed or the run is aborted.
+func (p *Pipeline) runSync(run *runState, inChan <-chan interface{}) {
+	list := p.stageList()
+	stages := make([]*syncStage, len(list))
+	for i, s := range list {
+		ss := &syncStage{cfg: p.stageConfig(s, run)}
+		switch cfg := ss.cfg; {
+		case cfg.processes != nil:
+			ss.processes = newDispatcher(Shared, cfg.weights)
+			for i := range cfg.processes {
+				ss.processes.lanes = append(ss.processes.lanes, &lane{index: i})
+			}
+		case cfg.newProcess != nil:
+			ss.process = cfg.newProcess(run.ctx)
+		default:
+			ss.process = cfg.process
+		}
+		stages[i] = ss
+		if run.logger != nil {
+			run.logger.stageStarted(s.name, 1)
+		}
+	}
+	defer func() {
+		if run.logger != nil {
+			for _, s := range list {
+				run.logger.stageStopped(s.name)
+			}
+		}
+	}()
+
+	for {
+		select {
+		case obj, ok := <-inChan:
+			if !ok {
+				return
+			}
+			if !runThrough(stages, obj, run) {
+				return
+			}
+		case <-run.done:
+			return
+		}
+	}
+}
+
+// runThrough passes obj through the stages. It returns false if the run was
+// aborted first.
+func runThrough(stages []*syncStage, obj interface{}, run *runState) bool {
+	for _, s := range stages {
+		if isClosed(run.done) {
+			return false
+		}
+		process := s.process
+		if s.processes != nil {
+			process = s.cfg.processes[s.processes.pickWeighted().index]
+		}
+		outObj, ok := s.cfg.handle(process, obj)
+		if !ok {
+			return true
+		}
+		atomic.AddUint64(&s.cfg.counters.out, 1)
+		s.cfg.control.tapped(outObj)
+		obj = outObj
+	}
+	endTrace(obj)
+	run.exit(obj, nil)
+	return true
+}
@@ -0,0 +1,30 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExamplePipeline_SetSynchronous() {
+	p := pipeline.New()
+	p.AddStageWithFanOut(squareStage, 8)
+	p.AddStageWithFanOut(printStage, 8)
+	p.SetSynchronous(true)
+
+	in := make(chan interface{}, 5)
+	for i := 1; i <= 5; i++ {
+		in <- i
+	}
+	close(in)
+	r := p.Start(in)
+
+	// the run completed by the time Start returned
+	fmt.Println(r.Err(), len(p.ActiveRuns()))
+
+	// Output: 1
+	// 4
+	// 9
+	// 16
+	// 25
+	// <nil> 0
+}
@@ -0,0 +1,33 @@
+package pipeline
+
+// Tee sends every object of inChan to each of n channels, and closes them
+// once inChan is closed. The next object is only read once all the channels
+// accepted the current one, so the slowest reader sets the pace and a channel
+// that is not read blocks the others. The channels receive the same objects,
+// which their readers must not modify unless they are safe for concurrent use.
+func Tee(inChan <-chan interface{}, n int) (outChans []chan interface{}) {
+	return TeeBuffered(inChan, n, 0)
+}
+
+// TeeBuffered is Tee with channels buffering up to bufferSize objects each, so
+// that a reader falling behind only slows the others down once its buffer is
+// full.
+func TeeBuffered(inChan <-chan interface{}, n int, bufferSize int) (outChans []chan interface{}) {
+	outChans = make([]chan interface{}, n)
+	for i := range outChans {
+		outChans[i] = make(chan interface{}, bufferSize)
+	}
+	go func() {
+		defer func() {
+			for _, ch := range outChans {
+				close(ch)
+			}
+		}()
+		for obj := range inChan {
+			for _, ch := range outChans {
+				ch <- obj
+			}
+		}
+	}()
+	return
+}
@@ -0,0 +1,37 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"sync"
+)
+
+func ExampleTee() {
+	in := make(chan interface{}, 4)
+	for i := 1; i <= 4; i++ {
+		in <- i
+	}
+	close(in)
+
+	outs := pipeline.Tee(in, 2)
+	var sum, product int
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for obj := range outs[0] {
+			sum += obj.(int)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		product = 1
+		for obj := range outs[1] {
+			product *= obj.(int)
+		}
+	}()
+	wg.Wait()
+	fmt.Println(sum, product)
+
+	// Output: 10 24
+}
@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"time"
+)
+
+// Throttle forwards the objects of inChan, waiting at least minInterval
+// between two of them to smooth bursty sources out. Objects are delayed, never
+// dropped. The returned channel is closed once inChan is closed.
+//
+// To throttle the objects between two stages, wrap it in a raw stage:
+//
+//	p.AddRawStage(func(inChan <-chan interface{}) chan interface{} {
+//		return pipeline.Throttle(inChan, 10*time.Millisecond)
+//	})
+func Throttle(inChan <-chan interface{}, minInterval time.Duration) (outChan chan interface{}) {
+	return ThrottleWithClock(inChan, minInterval, SystemClock())
+}
+
+// ThrottleWithClock is Throttle measuring time on clock.
+func ThrottleWithClock(inChan <-chan interface{}, minInterval time.Duration, clock Clock) (outChan chan interface{}) {
+	outChan = make(chan interface{})
+	go func() {
+		defer close(outChan)
+		var next time.Time
+		for obj := range inChan {
+			sleep(clock, next.Sub(clock.Now()), nil)
+			outChan <- obj
+			next = clock.Now().Add(minInterval)
+		}
+	}()
+	return
+}
+
+// Debounce forwards an object of inChan only once no other object came in for
+// quietPeriod, the earlier objects of a burst being dropped, e.g. to react
+// once to a series of file change events. The last object is forwarded when
+// inChan is closed, after which the returned channel is closed.
+func Debounce(inChan <-chan interface{}, quietPeriod time.Duration) (outChan chan interface{}) {
+	return DebounceWithClock(inChan, quietPeriod, SystemClock())
+}
+
+// DebounceWithClock is Debounce measuring time on clock.
+func DebounceWithClock(inChan <-chan interface{}, quietPeriod time.Duration, clock Clock) (outChan chan interface{}) {
+	outChan = make(chan interface{})
+	go func() {
+		defer close(outChan)
+		timer := clock.NewTimer(quietPeriod)
+		timer.Stop()
+		defer timer.Stop()
+
+		var pending interface{}
+		var hasPending bool
+		for {
+			var quiet <-chan time.Time
+			if hasPending {
+				quiet = timer.C()
+			}
+			select {
+			case obj, ok := <-inChan:
+				if !ok {
+					if hasPending {
+						outChan <- pending
+					}
+					return
+				}
+				pending, hasPending = obj, true
+				resetTimer(timer, quietPeriod)
+			case <-quiet:
+				outChan <- pending
+				pending, hasPending = nil, false
+			}
+		}
+	}()
+	return
+}
@@ -0,0 +1,46 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExampleThrottle() {
+	in := make(chan interface{}, 3)
+	in <- 1
+	in <- 2
+	in <- 3
+	close(in)
+
+	start := time.Now()
+	for obj := range pipeline.Throttle(in, 20*time.Millisecond) {
+		fmt.Println(obj)
+	}
+	fmt.Println(time.Since(start) >= 40*time.Millisecond)
+
+	// Output: 1
+	// 2
+	// 3
+	// true
+}
+
+func ExampleDebounce() {
+	in := make(chan interface{})
+	go func() {
+		defer close(in)
+		// two bursts of events
+		for _, event := range []string{"a.go changed", "a.go changed", "b.go changed"} {
+			in <- event
+		}
+		time.Sleep(100 * time.Millisecond)
+		in <- "c.go changed"
+	}()
+
+	for obj := range pipeline.Debounce(in, 50*time.Millisecond) {
+		fmt.Println(obj)
+	}
+
+	// Output: b.go changed
+	// c.go changed
+}
@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"context"
+)
+
+// Tracer starts spans. It is a subset of OpenTelemetry's trace.Tracer, which
+// keeps this package free of dependencies; an adapter is only a few lines:
+//
+//	type otelTracer struct{ trace.Tracer }
+//	type otelSpan struct{ trace.Span }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, pipeline.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+//	func (s otelSpan) End() { s.Span.End() }
+type Tracer interface {
+	Start(ctx context.Context, spanName string) (context.Context, Span)
+}
+
+// Span is a single traced operation started by a Tracer.
+type Span interface {
+	End()
+}
+
+// EnableTracing makes the pipeline trace every object as it flows through the
+// stages. Each object gets a root "pipeline" span for its whole journey, with a
+// child span per stage named after the stage, so that a single record can be
+// followed end-to-end in Jaeger, Tempo and the like.
+//
+// Objects are wrapped in an Envelope carrying the trace context while in the
+// pipeline, see EnableEnvelopes. Envelopes whose Trace context is already set
+// before entering the pipeline, e.g. with WithSpanContext, continue the trace
+// of that context.
+func (p *Pipeline) EnableTracing(t Tracer) {
+	p.checkMutable()
+	p.tracer = t
+}
+
+// WithSpanContext attaches a parent trace context to an object before it is
+// sent into a pipeline with tracing enabled, so that its spans continue an
+// upstream trace (e.g. one propagated through message headers). It is a
+// shorthand for an Envelope with only the Trace field set.
+func WithSpanContext(ctx context.Context, obj interface{}) interface{} {
+	return &Envelope{Payload: obj, Trace: ctx}
+}
+
+// traceProcessFn wraps a ProcessFnErr so that each call on an Envelope runs
+// within a span.
+func traceProcessFn(t Tracer, name string, fn ProcessFnErr) ProcessFnErr {
+	return func(inObj interface{}) (interface{}, error) {
+		env, ok := inObj.(*Envelope)
+		if !ok || env.Trace == nil {
+			return fn(inObj)
+		}
+
+		_, span := t.Start(env.Trace, name)
+		outObj, err := fn(inObj)
+		span.End()
+
+		if outObj == nil || err != nil {
+			endTrace(env)
+		}
+		return outObj, err
+	}
+}
+
+// endTrace ends the root span of an Envelope, if any.
+func endTrace(obj interface{}) {
+	if env, ok := obj.(*Envelope); ok && env.rootSpan != nil {
+		env.rootSpan.End()
+		env.rootSpan = nil
+	}
+}
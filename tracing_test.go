@@ -0,0 +1,36 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+type printTracer struct{}
+
+type printSpan string
+
+func (printTracer) Start(ctx context.Context, name string) (context.Context, pipeline.Span) {
+	fmt.Println("start", name)
+	return ctx, printSpan(name)
+}
+
+func (s printSpan) End() {
+	fmt.Println("end", string(s))
+}
+
+func ExamplePipeline_EnableTracing() {
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	p.EnableTracing(printTracer{})
+
+	ch := make(chan interface{}, 1)
+	ch <- 3
+	close(ch)
+	<-p.Run(ch)
+
+	// Output: start pipeline
+	// start stage0
+	// end stage0
+	// end pipeline
+}
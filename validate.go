@@ -0,0 +1,69 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// WithTypes is a StageOption declaring the type of the objects a stage takes
+// and of the ones it passes on, for Validate to check that the stages fit
+// together. Either can be nil if unknown or if the stage takes any object.
+// For the stages taking envelopes, they are the types of the payloads.
+// Unlike the other options, WithTypes applies to raw stages too.
+func WithTypes(in, out reflect.Type) StageOption {
+	return func(s *stage) {
+		s.inType, s.outType = in, out
+	}
+}
+
+// WithTypesOf is like WithTypes with the types of sample values, e.g.:
+//
+//	p.AddStage(parse, pipeline.WithTypesOf("", &Event{}))
+//
+// A nil sample declares no type. Interface types can only be declared with
+// WithTypes, e.g. reflect.TypeOf((*fmt.Stringer)(nil)).Elem().
+func WithTypesOf(in, out interface{}) StageOption {
+	return WithTypes(reflect.TypeOf(in), reflect.TypeOf(out))
+}
+
+// bytesType is the type of the objects of the stages handling encoded data.
+var bytesType = reflect.TypeOf([]byte(nil))
+
+// Validate checks that consecutive stages fit together as far as their types
+// are declared, see WithTypes: the objects passed on by a stage must be of a
+// type the next stage takes. A mismatch otherwise only shows when running the
+// pipeline, as every object failing or being dropped by the next stage.
+// Validate doesn't run the pipeline, and skips the pairs of stages whose
+// types aren't both declared; the stages encoding, compressing and
+// decompressing data declare theirs. The error lists all the mismatches
+// found.
+func (p *Pipeline) Validate() error {
+	var problems []string
+	stages := p.stageList()
+	for i := 1; i < len(stages); i++ {
+		prev, s := stages[i-1], stages[i]
+		if prev.outType == nil || s.inType == nil || canTake(s.inType, prev.outType) {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("stage %s passes on %v but stage %s takes %v",
+			prev.name, prev.outType, s.name, s.inType))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("pipeline: invalid stages: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// canTake tells whether a stage taking objects of type in may ever be passed
+// an object of type out. Objects declared as interfaces can hold any type
+// implementing them.
+func canTake(in, out reflect.Type) bool {
+	switch {
+	case in.Kind() == reflect.Interface:
+		return out.Kind() == reflect.Interface || out.Implements(in)
+	case out.Kind() == reflect.Interface:
+		return in.Implements(out)
+	}
+	return in == out
+}
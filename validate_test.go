@@ -0,0 +1,21 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"reflect"
+	"strconv"
+)
+
+func ExamplePipeline_Validate() {
+	p := pipeline.New()
+	p.AddStage(func(inObj interface{}) interface{} {
+		return strconv.Itoa(inObj.(int))
+	}, pipeline.WithName("format"), pipeline.WithTypesOf(0, ""))
+	p.AddStage(squareStage, pipeline.WithName("square"), pipeline.WithTypesOf(0, 0))
+	p.AddMarshalStage(pipeline.DefaultCodec(), 1, pipeline.WithName("encode"))
+	p.AddStage(printStage, pipeline.WithName("print"), pipeline.WithTypes(reflect.TypeOf((*fmt.Stringer)(nil)).Elem(), nil))
+	fmt.Println(p.Validate())
+
+	// Output: pipeline: invalid stages: stage format passes on string but stage square takes int; stage encode passes on []uint8 but stage print takes fmt.Stringer
+}
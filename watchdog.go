@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Stall describes the stage most likely blocking a pipeline that stopped
+// making progress, as found by StartWatchdog.
+type Stall struct {
+	Stage    string
+	Duration time.Duration // since the pipeline last made progress
+	InFlight uint64        // objects held by the stalled stages
+
+	// Stacks holds the stack traces of the goroutines of the stage, which
+	// usually show what they are waiting for, if profiling is enabled, see
+	// SetProfiling.
+	Stacks string
+}
+
+func (s Stall) String() string {
+	return fmt.Sprintf("pipeline: stage %s stalled for %v with %d objects in flight", s.Stage, s.Duration, s.InFlight)
+}
+
+// StartWatchdog checks that the stages of the pipeline make progress until
+// the returned function is called. Once the stages holding objects, i.e.
+// with pending input, have all been stuck for period, onStall is called with
+// the stage most likely blocking them: the last stuck stage or, if profiling
+// is enabled and shows its goroutines all waiting for the next stage to take
+// their objects, the next stage, see SetProfiling. The stall is also
+// published as an EventStageStalled on the EventBus of the pipeline. If
+// onStall is nil, the stall is logged with the stack traces of the goroutines
+// of the stage.
+//
+// A stall is reported once; the watchdog reports the next one after the
+// pipeline made progress again.
+func (p *Pipeline) StartWatchdog(period time.Duration, onStall func(Stall)) (stop func()) {
+	if onStall == nil {
+		onStall = func(s Stall) {
+			log.Printf("%v:\n%s", s, s.Stacks)
+		}
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		clock := clockOrSystem(p.clock)
+		timer := clock.NewTimer(period / 4)
+		defer timer.Stop()
+
+		var progress []uint64
+		lastProgress := clock.Now()
+		reported := false
+		for {
+			select {
+			case <-timer.C():
+				timer.Reset(period / 4)
+			case <-done:
+				return
+			}
+
+			stats := p.Stats()
+			if len(progress) != len(stats.Stages) {
+				progress = make([]uint64, len(stats.Stages))
+			}
+			moved := false
+			last := -1      // last stage holding objects
+			var held uint64 // objects held
+			for i, s := range stats.Stages {
+				if n := s.Out + s.Dropped + s.Errors; n != progress[i] {
+					progress[i], moved = n, true
+				}
+				if s.InFlight() > 0 {
+					last = i
+					held += s.InFlight()
+				}
+			}
+			if moved || last < 0 {
+				lastProgress, reported = clock.Now(), false
+				continue
+			}
+			if reported || clock.Now().Sub(lastProgress) < period {
+				continue
+			}
+
+			reported = true
+			stall := p.stall(last, held)
+			stall.Duration = clock.Now().Sub(lastProgress)
+			onStall(stall)
+			p.events.Publish(Event{Type: EventStageStalled, Stage: stall.Stage, Object: stall, Message: stall.String()})
+		}
+	}()
+
+	return func() {
+		select {
+		case <-done:
+		default:
+			close(done)
+		}
+		<-stopped
+	}
+}
+
+// stall returns the Stall of the stage blocking the pipeline, given the last
+// stuck stage.
+func (p *Pipeline) stall(last int, held uint64) Stall {
+	stages := p.stageList()
+	var blocked, running int
+	workers.Lock()
+	for _, slot := range workers.byID {
+		if slot.owner == p && slot.stage == stages[last].name {
+			running++
+			if atomic.LoadInt32(&slot.phase) == sendingPhase {
+				blocked++
+			}
+		}
+	}
+	workers.Unlock()
+
+	stage := stages[last].name
+	if running > 0 && blocked == running && last+1 < len(stages) {
+		stage = stages[last+1].name
+	}
+
+	var ids []int64
+	workers.Lock()
+	for id, slot := range workers.byID {
+		if slot.owner == p && slot.stage == stage {
+			ids = append(ids, id)
+		}
+	}
+	workers.Unlock()
+	if len(ids) == 0 {
+		// the runs aren't profiled
+		return Stall{Stage: stage, InFlight: held}
+	}
+	var stacks bytes.Buffer
+	all := workerStacks()
+	for _, id := range ids {
+		if stack, ok := all[id]; ok {
+			stacks.Write(stack)
+			stacks.WriteString("\n\n")
+		}
+	}
+	return Stall{Stage: stage, InFlight: held, Stacks: stacks.String()}
+}
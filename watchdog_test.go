@@ -0,0 +1,39 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"strings"
+	"time"
+)
+
+func ExamplePipeline_StartWatchdog() {
+	p := pipeline.New()
+	p.SetProfiling(true)
+	p.AddStage(squareStage, pipeline.WithName("square"))
+	p.AddStage(printStage, pipeline.WithName("print"))
+	p.PauseStage("print") // say by mistake
+
+	stalls := make(chan pipeline.Stall, 1)
+	stop := p.StartWatchdog(40*time.Millisecond, func(s pipeline.Stall) {
+		stalls <- s
+	})
+	defer stop()
+
+	ch := make(chan interface{}, 2)
+	ch <- 2
+	ch <- 3
+	close(ch)
+	done := p.Run(ch)
+
+	stall := <-stalls
+	fmt.Println("stalled:", stall.Stage, stall.InFlight)
+	fmt.Println("stacks:", strings.Contains(stall.Stacks, "waitResumed"))
+	p.ResumeStage("print")
+	<-done
+
+	// Output: stalled: print 1
+	// stacks: true
+	// 4
+	// 9
+}
@@ -0,0 +1,126 @@
+package pipeline
+
+import (
+	"sort"
+	"time"
+)
+
+// EventTime configures a tumbling or sliding window stage to group objects by
+// the time their events happened, as carried by the objects, rather than by
+// the time they arrive in the stage.
+//
+// Events may arrive out of order. The stage tracks a watermark, the time up
+// to which it assumes it has seen all the events: MaxOutOfOrderness behind the
+// latest event time seen. A window fires once the watermark passes its end,
+// and is then kept for AllowedLateness, during which late events still go
+// into it and fire it again with the updated result. Events arriving later
+// than that are passed to Late, if set, and otherwise dropped.
+//
+// The watermark only advances as events arrive: the windows left open fire
+// when the input of the run is closed.
+type EventTime struct {
+	Timestamp         func(obj interface{}) time.Time
+	MaxOutOfOrderness time.Duration
+	AllowedLateness   time.Duration
+	Late              func(obj interface{})
+}
+
+// WithEventTime is a StageOption making a tumbling or sliding window stage
+// work in event time. Other stages are unaffected.
+func WithEventTime(eventTime EventTime) StageOption {
+	return func(s *stage) {
+		s.eventTime = &eventTime
+	}
+}
+
+// eventWindow is a window of an event time stage.
+type eventWindow struct {
+	openWindow
+	fired bool
+}
+
+// window groups the objects of inChan into the windows returned by assign
+// for their event times, in ascending order of start.
+func (et *EventTime) window(inChan <-chan interface{}, emitter *windowEmitter, size time.Duration, assign func(time.Time) []time.Time) {
+	defer close(emitter.outChan)
+
+	windows := map[int64]*eventWindow{} // by start in unix nanoseconds
+	var watermark time.Time
+	// advance fires the windows that ended before the watermark and forgets
+	// the ones past their allowed lateness, in order.
+	advance := func() {
+		var starts []int64
+		for start := range windows {
+			starts = append(starts, start)
+		}
+		sort.Slice(starts, func(i, j int) bool {
+			return starts[i] < starts[j]
+		})
+		for _, start := range starts {
+			w := windows[start]
+			if w.fired || w.End.After(watermark) {
+				continue
+			}
+			emitter.emit(w.Window)
+			w.fired = true
+		}
+		for _, start := range starts {
+			w := windows[start]
+			if w.End.Add(et.AllowedLateness).After(watermark) {
+				break
+			}
+			for _, env := range w.envelopes {
+				settle(env, nil)
+			}
+			delete(windows, start)
+		}
+	}
+
+	for obj := range inChan {
+		t := et.Timestamp(payload(obj))
+		starts := assign(t)
+		var last *eventWindow
+		var refire []*eventWindow
+		for _, start := range starts {
+			end := start.Add(size)
+			if !end.Add(et.AllowedLateness).After(watermark) {
+				continue
+			}
+			w, ok := windows[start.UnixNano()]
+			if !ok {
+				w = &eventWindow{openWindow: openWindow{Window: Window{Start: start, End: end}}}
+				windows[start.UnixNano()] = w
+			}
+			w.Objects = append(w.Objects, payload(obj))
+			if w.fired {
+				refire = append(refire, w)
+			}
+			last = w
+		}
+
+		switch {
+		case last != nil:
+			// the last window of an object is forgotten last
+			if env, ok := obj.(*Envelope); ok {
+				last.envelopes = append(last.envelopes, env)
+			}
+			for _, w := range refire {
+				emitter.emit(w.Window)
+			}
+		case len(starts) > 0 && et.Late != nil:
+			et.Late(payload(obj))
+			settle(obj, nil)
+		default:
+			settle(obj, nil)
+		}
+
+		if wm := t.Add(-et.MaxOutOfOrderness); wm.After(watermark) {
+			watermark = wm
+			advance()
+		}
+	}
+
+	// the input is closed: all the events were seen
+	watermark = time.Unix(1<<62, 0)
+	advance()
+}
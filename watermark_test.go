@@ -0,0 +1,49 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExampleWithEventTime() {
+	type event struct {
+		at time.Duration // since midnight
+	}
+	midnight := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var late []time.Duration
+	p := pipeline.New()
+	p.AddTumblingWindowStage(time.Minute, func(w pipeline.Window) interface{} {
+		var ats []time.Duration
+		for _, obj := range w.Objects {
+			ats = append(ats, obj.(event).at)
+		}
+		return fmt.Sprint(w.Start.Format("15:04"), " ", ats)
+	}, pipeline.WithEventTime(pipeline.EventTime{
+		Timestamp: func(obj interface{}) time.Time {
+			return midnight.Add(obj.(event).at)
+		},
+		AllowedLateness: time.Minute,
+		Late: func(obj interface{}) {
+			late = append(late, obj.(event).at)
+		},
+	}))
+	p.AddStage(printStage)
+
+	in := make(chan interface{}, 5)
+	in <- event{10 * time.Second}
+	in <- event{70 * time.Second}  // fires the first window
+	in <- event{20 * time.Second}  // late, fires the first window again
+	in <- event{130 * time.Second} // fires the second window
+	in <- event{30 * time.Second}  // too late
+	close(in)
+	<-p.Run(in)
+	fmt.Println("late:", late)
+
+	// Output: 00:00 [10s]
+	// 00:00 [10s 20s]
+	// 00:01 [1m10s]
+	// 00:02 [2m10s]
+	// late: [30s]
+}
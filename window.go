@@ -0,0 +1,329 @@
+package pipeline
+
+import (
+	"container/list"
+	"time"
+)
+
+// Window is a group of objects that went through a windowing stage within the
+// same time window, [Start, End).
+type Window struct {
+	Key     string // the key of session windows
+	Start   time.Time
+	End     time.Time
+	Objects []interface{}
+}
+
+// AddTumblingWindowStage adds a stage grouping objects into fixed,
+// non-overlapping windows of the given size, aligned on multiples of size
+// since the zero time (so one minute windows start on the minute). Windows are
+// in processing time, on the Clock of the pipeline, objects going into the
+// window in progress when they arrive in the stage, unless the WithEventTime
+// option is given.
+//
+// When a window closes, the stage emits aggregate(window), or the Window
+// itself if aggregate is nil. Empty windows and nil results are not emitted.
+// The window in progress is closed early when the input of the run is closed.
+//
+// The stage is a raw stage, so only the WithName and WithEventTime options
+// apply to it. The envelopes of the objects are acknowledged once the result
+// of their window was emitted, and the result doesn't carry an envelope.
+func (p *Pipeline) AddTumblingWindowStage(size time.Duration, aggregate func(Window) interface{}, opts ...StageOption) {
+	st := &stage{control: newStageControl(0)}
+	st.clocked = func(clock Clock, done <-chan struct{}) StageFn {
+		return func(inChan <-chan interface{}) (outChan chan interface{}) {
+			outChan = make(chan interface{})
+			emitter := &windowEmitter{outChan: outChan, aggregate: aggregate, done: done}
+			if st.eventTime != nil {
+				go st.eventTime.window(inChan, emitter, size, func(t time.Time) []time.Time {
+					return []time.Time{t.Truncate(size)}
+				})
+			} else {
+				go tumble(inChan, emitter, size, clock)
+			}
+			return
+		}
+	}
+	st.raw = st.clocked(SystemClock(), nil)
+	p.addStage(st, opts...)
+}
+
+func tumble(inChan <-chan interface{}, emitter *windowEmitter, size time.Duration, clock Clock) {
+	defer close(emitter.outChan)
+	timer := clock.NewTimer(size)
+	timer.Stop()
+	defer timer.Stop()
+
+	var current *openWindow
+	for {
+		var closed <-chan time.Time
+		if current != nil {
+			closed = timer.C()
+		}
+
+		select {
+		case obj, ok := <-inChan:
+			if !ok {
+				if current != nil {
+					emitter.close(current)
+				}
+				return
+			}
+			now := clock.Now()
+			if current != nil && !now.Before(current.End) {
+				emitter.close(current)
+				current = nil
+			}
+			if current == nil {
+				start := now.Truncate(size)
+				current = &openWindow{Window: Window{Start: start, End: start.Add(size)}}
+				resetTimer(timer, current.End.Sub(now))
+			}
+			current.add(obj)
+		case <-closed:
+			emitter.close(current)
+			current = nil
+		}
+	}
+}
+
+// openWindow is a window still receiving objects.
+type openWindow struct {
+	Window
+	envelopes []*Envelope
+}
+
+func (w *openWindow) add(obj interface{}) {
+	if env, ok := obj.(*Envelope); ok {
+		w.envelopes = append(w.envelopes, env)
+	}
+	w.Objects = append(w.Objects, payload(obj))
+}
+
+// windowEmitter sends the results of the windows that closed.
+type windowEmitter struct {
+	outChan   chan interface{}
+	aggregate func(Window) interface{}
+	done      <-chan struct{} // closed when the run is aborted
+}
+
+// emit emits the result of a window, unless it is empty. It returns false if
+// the run was aborted instead.
+func (e *windowEmitter) emit(w Window) bool {
+	if len(w.Objects) == 0 {
+		return true
+	}
+	var result interface{} = w
+	if e.aggregate != nil {
+		result = e.aggregate(w)
+	}
+	if result == nil {
+		return true
+	}
+	select {
+	case e.outChan <- result:
+		return true
+	case <-e.done:
+		return false
+	}
+}
+
+// close emits the result of a tumbling window and acknowledges the envelopes
+// of its objects, which are abandoned if the run was aborted.
+func (e *windowEmitter) close(w *openWindow) {
+	if !e.emit(w.Window) {
+		return
+	}
+	for _, env := range w.envelopes {
+		settle(env, nil)
+	}
+}
+
+// AddSlidingWindowStage adds a stage grouping objects into overlapping windows
+// of the given size starting every slide, e.g. the objects of the last five
+// minutes every thirty seconds. Windows start on multiples of slide since the
+// zero time and an object belongs to every window it arrived within, so to
+// size/slide windows. Windows are in processing time, on the Clock of the
+// pipeline, unless the WithEventTime option is given. A slide longer than size
+// leaves gaps between the windows; the objects arriving in them are not
+// emitted.
+//
+// Results are emitted as with AddTumblingWindowStage, in order of windows.
+// The objects are held until their last window closes, after which their
+// envelopes are acknowledged. When the input of the run is closed, the windows
+// holding objects are closed early.
+func (p *Pipeline) AddSlidingWindowStage(size, slide time.Duration, aggregate func(Window) interface{}, opts ...StageOption) {
+	st := &stage{control: newStageControl(0)}
+	st.clocked = func(clock Clock, done <-chan struct{}) StageFn {
+		return func(inChan <-chan interface{}) (outChan chan interface{}) {
+			outChan = make(chan interface{})
+			emitter := &windowEmitter{outChan: outChan, aggregate: aggregate, done: done}
+			if st.eventTime != nil {
+				go st.eventTime.window(inChan, emitter, size, func(t time.Time) (starts []time.Time) {
+					for start := firstSlidingStart(t, size, slide); !start.After(t); start = start.Add(slide) {
+						starts = append(starts, start)
+					}
+					return
+				})
+			} else {
+				go slideWindows(inChan, emitter, size, slide, clock)
+			}
+			return
+		}
+	}
+	st.raw = st.clocked(SystemClock(), nil)
+	p.addStage(st, opts...)
+}
+
+// firstSlidingStart returns the start of the first sliding window holding an
+// object at t.
+func firstSlidingStart(t time.Time, size, slide time.Duration) time.Time {
+	return t.Add(-size).Truncate(slide).Add(slide)
+}
+
+// windowedObject is an object held by a sliding window stage.
+type windowedObject struct {
+	obj     interface{}
+	arrived time.Time
+}
+
+func slideWindows(inChan <-chan interface{}, emitter *windowEmitter, size, slide time.Duration, clock Clock) {
+	defer close(emitter.outChan)
+	timer := clock.NewTimer(size)
+	timer.Stop()
+	defer timer.Stop()
+
+	var held []windowedObject // in order of arrival
+	var next time.Time        // start of the next window to close
+	closeNext := func() {
+		w := Window{Start: next, End: next.Add(size)}
+		for _, h := range held {
+			if h.arrived.Before(w.Start) {
+				continue
+			}
+			if !h.arrived.Before(w.End) {
+				break
+			}
+			w.Objects = append(w.Objects, payload(h.obj))
+		}
+		emitter.emit(w)
+
+		// the objects that arrived before the start of the next window
+		// are in no other window
+		next = next.Add(slide)
+		for len(held) > 0 && held[0].arrived.Before(next) {
+			settle(held[0].obj, nil)
+			held[0] = windowedObject{}
+			held = held[1:]
+		}
+		if len(held) > 0 {
+			if start := firstSlidingStart(held[0].arrived, size, slide); start.After(next) {
+				next = start
+			}
+		}
+	}
+
+	for {
+		var closed <-chan time.Time
+		if len(held) > 0 {
+			closed = timer.C()
+		}
+
+		select {
+		case obj, ok := <-inChan:
+			if !ok {
+				for len(held) > 0 {
+					closeNext()
+				}
+				return
+			}
+			now := clock.Now()
+			if len(held) == 0 {
+				next = firstSlidingStart(now, size, slide)
+				resetTimer(timer, next.Add(size).Sub(now))
+			}
+			held = append(held, windowedObject{obj: obj, arrived: now})
+		case <-closed:
+			closeNext()
+			if len(held) > 0 {
+				resetTimer(timer, next.Add(size).Sub(clock.Now()))
+			}
+		}
+	}
+}
+
+// AddSessionWindowStage adds a stage grouping the objects with the same key,
+// as returned by the key function, into sessions: a session starts with the
+// first object of its key and closes once no object with that key arrived for
+// gap. The window of a session starts when its first object arrived and ends
+// gap after its last one.
+//
+// Sessions are in processing time, on the Clock of the pipeline. Results are
+// emitted as with AddTumblingWindowStage, in the order in which sessions
+// close. When the input of the run is closed, the open sessions are closed
+// early.
+func (p *Pipeline) AddSessionWindowStage(key func(obj interface{}) string, gap time.Duration, aggregate func(Window) interface{}, opts ...StageOption) {
+	st := &stage{control: newStageControl(0)}
+	st.clocked = func(clock Clock, done <-chan struct{}) StageFn {
+		return func(inChan <-chan interface{}) (outChan chan interface{}) {
+			outChan = make(chan interface{})
+			go sessionize(inChan, &windowEmitter{outChan: outChan, aggregate: aggregate, done: done}, key, gap, clock)
+			return
+		}
+	}
+	st.raw = st.clocked(SystemClock(), nil)
+	p.addStage(st, opts...)
+}
+
+func sessionize(inChan <-chan interface{}, emitter *windowEmitter, key func(obj interface{}) string, gap time.Duration, clock Clock) {
+	defer close(emitter.outChan)
+	timer := clock.NewTimer(gap)
+	timer.Stop()
+	defer timer.Stop()
+
+	sessions := map[string]*list.Element{}
+	expiry := list.New() // of *openWindow, the first one closing first
+	closeFirst := func() {
+		w := expiry.Remove(expiry.Front()).(*openWindow)
+		delete(sessions, w.Key)
+		emitter.close(w)
+	}
+
+	for {
+		var closed <-chan time.Time
+		if expiry.Len() > 0 {
+			closed = timer.C()
+		}
+
+		select {
+		case obj, ok := <-inChan:
+			if !ok {
+				for expiry.Len() > 0 {
+					closeFirst()
+				}
+				return
+			}
+			now := clock.Now()
+			k := key(payload(obj))
+			e, ok := sessions[k]
+			if ok {
+				expiry.MoveToBack(e)
+			} else {
+				e = expiry.PushBack(&openWindow{Window: Window{Key: k, Start: now}})
+				sessions[k] = e
+			}
+			w := e.Value.(*openWindow)
+			w.End = now.Add(gap)
+			w.add(obj)
+			resetTimer(timer, expiry.Front().Value.(*openWindow).End.Sub(now))
+		case <-closed:
+			now := clock.Now()
+			for expiry.Len() > 0 && !now.Before(expiry.Front().Value.(*openWindow).End) {
+				closeFirst()
+			}
+			if expiry.Len() > 0 {
+				resetTimer(timer, expiry.Front().Value.(*openWindow).End.Sub(now))
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"time"
+)
+
+func ExamplePipeline_AddTumblingWindowStage() {
+	p := pipeline.New()
+	p.AddTumblingWindowStage(time.Hour, func(w pipeline.Window) interface{} {
+		sum := 0
+		for _, obj := range w.Objects {
+			sum += obj.(int)
+		}
+		return sum
+	})
+	p.AddStage(printStage)
+
+	in := make(chan interface{}, 4)
+	for i := 1; i <= 4; i++ {
+		in <- i
+	}
+	close(in)
+	<-p.Run(in)
+
+	// Output: 10
+}
+
+func ExamplePipeline_AddSlidingWindowStage() {
+	p := pipeline.New()
+	// every object is in two windows
+	p.AddSlidingWindowStage(time.Hour, 30*time.Minute, func(w pipeline.Window) interface{} {
+		return len(w.Objects)
+	})
+	p.AddStage(printStage)
+
+	in := make(chan interface{}, 3)
+	for i := 1; i <= 3; i++ {
+		in <- i
+	}
+	close(in)
+	<-p.Run(in)
+
+	// Output: 3
+	// 3
+}
+
+func ExamplePipeline_AddSessionWindowStage() {
+	type click struct {
+		user string
+		page string
+	}
+
+	p := pipeline.New()
+	p.AddSessionWindowStage(func(obj interface{}) string {
+		return obj.(click).user
+	}, 50*time.Millisecond, func(w pipeline.Window) interface{} {
+		var pages []string
+		for _, obj := range w.Objects {
+			pages = append(pages, obj.(click).page)
+		}
+		return fmt.Sprint(w.Key, " ", pages)
+	})
+	p.AddStage(printStage)
+
+	in := make(chan interface{})
+	go func() {
+		in <- click{"alice", "home"}
+		in <- click{"alice", "cart"}
+		time.Sleep(100 * time.Millisecond)
+		in <- click{"alice", "checkout"}
+		close(in)
+	}()
+	<-p.Run(in)
+
+	// Output: alice [home cart]
+	// alice [checkout]
+}
@@ -0,0 +1,48 @@
+package pipeline
+
+// Worker processes the objects of a stage within a single goroutine, so it
+// can keep state such as caches, counters or buffers without locking.
+type Worker interface {
+	Process(inObj interface{}) (outObj interface{}, err error)
+}
+
+// AddStageWithWorkers adds a fan-out stage where every goroutine processes
+// objects with its own Worker, made by calling newWorker when the goroutine
+// starts, including when the fan size grows with SetFanOut. It otherwise
+// behaves exactly like AddStageErr.
+func (p *Pipeline) AddStageWithWorkers(newWorker func() Worker, fanSize uint64, opts ...StageOption) {
+	p.addStage(&stage{newWorker: newWorker, control: newStageControl(fanSize)}, opts...)
+}
+
+// WeightedWorker is a Worker along with its share of the objects of a stage
+// added with AddWeightedStage.
+type WeightedWorker struct {
+	Worker Worker
+	Weight int // below 1 counts as 1
+}
+
+// AddWeightedStage adds a stage with one goroutine per worker, each getting a
+// share of the objects proportional to its weight, e.g. one worker per remote
+// region weighted by the capacity of the region. Objects are handed out in
+// smooth weighted round-robin order: next to a worker of weight 1, a worker of
+// weight 3 gets three objects out of four, interleaved rather than in bursts.
+//
+// As with WithDistribution, every goroutine holds one object besides the one
+// it processes. The fan size of the stage can't be changed and the stage
+// doesn't run on the WorkerPool of the pipeline. It otherwise behaves like
+// AddStageErr.
+func (p *Pipeline) AddWeightedStage(workers []WeightedWorker, opts ...StageOption) {
+	p.addStage(&stage{weighted: workers, control: newStageControl(uint64(len(workers)))}, opts...)
+}
+
+// weights returns the weights of the workers of a weighted stage.
+func (s *stage) weights() []int {
+	weights := make([]int, len(s.weighted))
+	for i, w := range s.weighted {
+		weights[i] = w.Weight
+		if weights[i] < 1 {
+			weights[i] = 1
+		}
+	}
+	return weights
+}
@@ -0,0 +1,70 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"sync/atomic"
+)
+
+// dedupWorker drops the objects it has already seen, without any lock since
+// every goroutine of the stage has its own cache.
+type dedupWorker struct {
+	seen map[interface{}]bool
+}
+
+func (w *dedupWorker) Process(inObj interface{}) (interface{}, error) {
+	if w.seen[inObj] {
+		return nil, nil
+	}
+	w.seen[inObj] = true
+	return inObj, nil
+}
+
+func ExamplePipeline_AddStageWithWorkers() {
+	p := pipeline.New()
+	p.AddStageWithWorkers(func() pipeline.Worker {
+		return &dedupWorker{seen: map[interface{}]bool{}}
+	}, 1)
+	p.AddStage(printStage)
+
+	in := make(chan interface{}, 4)
+	in <- "a"
+	in <- "b"
+	in <- "a"
+	in <- "c"
+	close(in)
+	<-p.Run(in)
+
+	// Output: a
+	// b
+	// c
+}
+
+// regionWorker sends objects to a remote region and counts them.
+type regionWorker struct {
+	sent int64
+}
+
+func (w *regionWorker) Process(inObj interface{}) (interface{}, error) {
+	atomic.AddInt64(&w.sent, 1)
+	return inObj, nil
+}
+
+func ExamplePipeline_AddWeightedStage() {
+	large, small := &regionWorker{}, &regionWorker{}
+	p := pipeline.New()
+	p.AddWeightedStage([]pipeline.WeightedWorker{
+		{Worker: large, Weight: 3},
+		{Worker: small, Weight: 1},
+	})
+
+	in := make(chan interface{}, 8)
+	for i := 0; i < 8; i++ {
+		in <- i
+	}
+	close(in)
+	<-p.Run(in)
+	fmt.Println(large.sent, small.sent)
+
+	// Output: 6 2
+}
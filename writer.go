@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// WriterSink is a Sink writing every object to an io.Writer, such as
+// os.Stdout or a file, optionally through a buffer. It is safe for concurrent
+// use, so it can be added with any fan size, and writes whole records.
+type WriterSink struct {
+	format func(obj interface{}) ([]byte, error)
+
+	// FlushInterval, if set, bounds how long written records stay in the
+	// buffer when the sink is buffered.
+	FlushInterval time.Duration
+
+	mu    sync.Mutex
+	w     io.Writer
+	buf   *bufio.Writer // nil if unbuffered
+	timer *time.Timer   // pending periodic flush
+}
+
+// NewWriterSink creates a WriterSink writing format(obj) for every object to
+// w. The formatted record is written as is, so it should end with its
+// delimiter, such as a newline. A nil format writes objects as with
+// fmt.Println.
+//
+// With a bufferSize above zero, records go through a buffer of that size,
+// which is written out when full, every FlushInterval if set, and when the
+// sink is flushed: WriterSink is Flushable, so Pipeline.Flush flushes it.
+func NewWriterSink(w io.Writer, format func(obj interface{}) ([]byte, error), bufferSize int) *WriterSink {
+	if format == nil {
+		format = formatLine
+	}
+	s := &WriterSink{format: format, w: w}
+	if bufferSize > 0 {
+		s.buf = bufio.NewWriterSize(w, bufferSize)
+		s.w = s.buf
+	}
+	return s
+}
+
+func formatLine(obj interface{}) ([]byte, error) {
+	return []byte(fmt.Sprintln(obj)), nil
+}
+
+// NewRecordSink creates a WriterSink writing []byte objects as length
+// delimited records, each one preceded by its length as a uvarint, as read
+// by NewRecordSource and protobuf's delimited readers. Other objects fail.
+// See NewWriterSink for bufferSize.
+func NewRecordSink(w io.Writer, bufferSize int) *WriterSink {
+	return NewWriterSink(w, formatDelimited, bufferSize)
+}
+
+func formatDelimited(obj interface{}) ([]byte, error) {
+	data, ok := obj.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("pipeline: can't write %T as a record", obj)
+	}
+	record := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(data))
+	n := binary.PutUvarint(record, uint64(len(data)))
+	return append(record[:n], data...), nil
+}
+
+// Write implements Sink.
+func (s *WriterSink) Write(obj interface{}) error {
+	record, err := s.format(obj)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err = s.w.Write(record); err != nil {
+		return err
+	}
+	if s.buf != nil && s.FlushInterval > 0 && s.timer == nil && s.buf.Buffered() > 0 {
+		s.timer = time.AfterFunc(s.FlushInterval, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.timer = nil
+			s.buf.Flush()
+		})
+	}
+	return nil
+}
+
+// Flush implements Flushable, writing out the buffered records.
+func (s *WriterSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buf == nil {
+		return nil
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	return s.buf.Flush()
+}
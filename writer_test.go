@@ -0,0 +1,32 @@
+package pipeline_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/hyfather/pipeline"
+	"os"
+)
+
+func ExampleNewWriterSink() {
+	sink := pipeline.NewWriterSink(os.Stdout, func(obj interface{}) ([]byte, error) {
+		return []byte(fmt.Sprintf("square: %d\n", obj)), nil
+	}, 4096)
+
+	p := pipeline.New()
+	p.AddStage(squareStage)
+	p.AddSink(sink, 1)
+	in := make(chan interface{}, 3)
+	in <- 1
+	in <- 2
+	in <- 3
+	close(in)
+	<-p.Run(in)
+
+	fmt.Println("flushing")
+	p.Flush(context.Background())
+
+	// Output: flushing
+	// square: 1
+	// square: 4
+	// square: 9
+}
@@ -0,0 +1,29 @@
+package pipeline
+
+// Pair holds the objects zipped together by Zip.
+type Pair struct {
+	First, Second interface{}
+}
+
+// Zip pairs the i-th object of a with the i-th object of b, e.g. the results
+// of two computations run in parallel over the same ordered source, and sends
+// the Pairs to the returned channel. The channel is closed as soon as either
+// input is closed; the objects left in the other one are not read.
+func Zip(a, b <-chan interface{}) (outChan chan interface{}) {
+	outChan = make(chan interface{})
+	go func() {
+		defer close(outChan)
+		for {
+			first, ok := <-a
+			if !ok {
+				return
+			}
+			second, ok := <-b
+			if !ok {
+				return
+			}
+			outChan <- Pair{First: first, Second: second}
+		}
+	}()
+	return
+}
@@ -0,0 +1,26 @@
+package pipeline_test
+
+import (
+	"fmt"
+	"github.com/hyfather/pipeline"
+)
+
+func ExampleZip() {
+	names := make(chan interface{}, 3)
+	for _, name := range []string{"one", "two", "three"} {
+		names <- name
+	}
+	close(names)
+	numbers := make(chan interface{}, 2)
+	numbers <- 1
+	numbers <- 2
+	close(numbers)
+
+	for obj := range pipeline.Zip(names, numbers) {
+		pair := obj.(pipeline.Pair)
+		fmt.Println(pair.First, pair.Second)
+	}
+
+	// Output: one 1
+	// two 2
+}